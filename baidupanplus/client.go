@@ -0,0 +1,156 @@
+package baidupanplus
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/trace"
+
+	openapi "github.com/S-zhi/baidupansdk/openxpanapi"
+)
+
+// Client 网盘客户端，绑定一份 Config 与底层的 openapi 客户端
+// 与包级别的 XxxWithConfig 系列函数不同，Client 上的方法均接收 context，便于调用方控制取消与超时
+type Client struct {
+	cfg    Config
+	api    *openapi.APIClient
+	logger *slog.Logger
+
+	// httpClient 下载缩略图等使用的 HTTP 客户端，不经过 API 请求限流
+	httpClient *http.Client
+	// dlinkClient 下载 dlink 使用的 HTTP 客户端，跟随跳转时保留 User-Agent
+	dlinkClient *http.Client
+
+	// 客户端级别的带宽限速器，由该客户端发起的所有传输共享
+	uploadLimiter   *RateLimiter
+	downloadLimiter *RateLimiter
+
+	// API 请求限流，作用于该客户端发出的所有 openapi 请求
+	governor *requestGovernor
+
+	metrics Metrics
+	tracer  trace.Tracer
+
+	// 查询到的会员类型，首次上传时查询
+	vipMu           sync.Mutex
+	detectedVipType *VipType
+
+	// 缩略图磁盘缓存，首次获取缩略图时打开
+	thumbOnce sync.Once
+	thumbs    *thumbCache
+}
+
+// NewClient 根据 Config 创建客户端
+func NewClient(cfg Config) *Client {
+	return newClient(cfg, newRequestGovernor(cfg.RequestLimits))
+}
+
+// packageGovernor 包级别函数共用的请求限流器
+// 包级别函数每次调用都会临时创建 Client，共用限流器才能约束它们之间的并发请求
+var packageGovernor = newRequestGovernor(nil)
+
+// packageClient 为包级别函数创建临时客户端
+func packageClient(cfg Config) *Client {
+	return newClient(cfg, packageGovernor)
+}
+
+func newClient(cfg Config, governor *requestGovernor) *Client {
+	httpClient := &http.Client{}
+	if cfg.HTTPClient != nil {
+		httpClient = cfg.HTTPClient
+	}
+	transport := httpClient.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	logger := redactLogger(cfg.Logger)
+	if logger == nil {
+		logger = packageLogger()
+	}
+	var metrics Metrics = nopMetrics{}
+	if cfg.Metrics != nil {
+		metrics = cfg.Metrics
+	}
+	tracer := newTracer(cfg.TracerProvider)
+	apiHTTPClient := *httpClient
+	apiHTTPClient.Transport = governor.transport(transport, logger, metrics, tracer)
+
+	apiCfg := openapi.NewConfiguration()
+	apiCfg.HTTPClient = &apiHTTPClient
+	if cfg.Metrics != nil {
+		apiCfg.CallObserver = callObserver{metrics: metrics}
+	}
+	applyServerURLs(apiCfg, cfg)
+	return &Client{
+		cfg:             cfg,
+		api:             openapi.NewAPIClient(apiCfg),
+		logger:          logger,
+		httpClient:      httpClient,
+		dlinkClient:     dlinkHTTPClient(httpClient),
+		uploadLimiter:   NewRateLimiter(cfg.UploadRateLimit),
+		downloadLimiter: NewRateLimiter(cfg.DownloadRateLimit),
+		governor:        governor,
+		metrics:         metrics,
+		tracer:          tracer,
+	}
+}
+
+// 百度网盘官方服务地址
+const (
+	defaultPanURL  = "https://pan.baidu.com"
+	defaultPCSURL  = "https://d.pcs.baidu.com"
+	defaultAuthURL = "https://openapi.baidu.com"
+)
+
+// applyServerURLs 将 openapi 配置中的官方服务地址替换为 Config 中指定的地址
+func applyServerURLs(apiCfg *openapi.Configuration, cfg Config) {
+	replacements := map[string]string{
+		defaultPanURL:  cfg.PanURL,
+		defaultPCSURL:  cfg.PCSURL,
+		defaultAuthURL: cfg.AuthURL,
+	}
+	replace := func(servers openapi.ServerConfigurations) {
+		for i := range servers {
+			if u := replacements[servers[i].URL]; u != "" {
+				servers[i].URL = strings.TrimSuffix(u, "/")
+			}
+		}
+	}
+	replace(apiCfg.Servers)
+	for _, servers := range apiCfg.OperationServers {
+		replace(servers)
+	}
+}
+
+// rewriteDlink 按 Config.DlinkURL 替换 dlink 的协议与主机
+func (c *Client) rewriteDlink(u *url.URL) error {
+	if c.cfg.DlinkURL == "" {
+		return nil
+	}
+	target, err := url.Parse(c.cfg.DlinkURL)
+	if err != nil {
+		return err
+	}
+	u.Scheme = target.Scheme
+	u.Host = target.Host
+	return nil
+}
+
+// DefaultClient 使用 NewBasicConfig / LoadConfigFromFile 初始化的全局配置创建客户端
+func DefaultClient() *Client {
+	return NewClient(config)
+}
+
+// Config 返回客户端使用的配置
+func (c *Client) Config() Config {
+	return c.cfg
+}
+
+// API 返回底层的 openapi 客户端，用于调用 SDK 尚未封装的接口
+// 通过它发出的请求同样受客户端请求限流约束
+func (c *Client) API() *openapi.APIClient {
+	return c.api
+}
@@ -0,0 +1,37 @@
+package baidupanplus
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/S-zhi/baidupansdk/baidupantest"
+)
+
+// newTestClient 启动模拟服务并创建指向它的客户端，会员类型固定为普通用户（分片 4MB）
+func newTestClient(t *testing.T) (*Client, *baidupantest.Server) {
+	t.Helper()
+	srv := baidupantest.NewServer()
+	t.Cleanup(srv.Close)
+	srv.SetAccessToken("token")
+	return NewClient(newTestConfig(srv)), srv
+}
+
+// newTestConfig 返回指向 srv 的客户端配置
+func newTestConfig(srv *baidupantest.Server) Config {
+	vip := VipTypeNormal
+	return Config{
+		AccessToken: "token",
+		HTTPClient:  srv.Client(),
+		VipType:     &vip,
+		Logger:      slog.New(slog.DiscardHandler),
+	}
+}
+
+// testData 生成 n 字节的确定性测试数据
+func testData(n int) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(i*7 + i/4096)
+	}
+	return data
+}
@@ -4,7 +4,12 @@ package baidupanplus
 import (
 	"encoding/json"
 	"fmt"
+	"log/slog"
+	"net/http"
 	"os"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 // OperateType 操作类型枚举
@@ -26,22 +31,61 @@ var defaultDownloadFileConfig DownloadFileConfig
 type Config struct {
 	AccessToken string      `json:"access_token"` // 访问令牌
 	Operate     OperateType `json:"operate"`      // 操作类型
-	IsSVIP      bool        `json:"is_svip"`      // 是否为超级会员
 	LogPath     string      `json:"log_path"`
+
+	// IsSVIP 是否为超级会员，仅在自动查询会员类型失败时作为估计
+	//
+	// Deprecated: 会员类型由 SDK 通过用户信息接口查询，需要指定时使用 VipType
+	IsSVIP bool `json:"is_svip"`
+	// VipType 指定会员类型，跳过自动查询，用于决定分片大小与单文件大小上限
+	VipType *VipType `json:"vip_type,omitempty"`
+
+	UploadRateLimit   int64 `json:"upload_rate_limit"`   // 上传带宽上限（字节/秒），0 表示不限速
+	DownloadRateLimit int64 `json:"download_rate_limit"` // 下载带宽上限（字节/秒），0 表示不限速
+
+	// RequestLimits 按接口族配置的 API 请求限流，未配置的接口族使用内置默认值
+	RequestLimits map[EndpointFamily]RequestLimit `json:"request_limits,omitempty"`
+
+	// 服务地址，为空时使用百度网盘官方地址，可指向代理、镜像或测试服务
+	PanURL   string `json:"pan_url,omitempty"`   // 文件、多媒体、用户信息接口，默认 https://pan.baidu.com
+	PCSURL   string `json:"pcs_url,omitempty"`   // 分片上传接口，默认 https://d.pcs.baidu.com
+	AuthURL  string `json:"auth_url,omitempty"`  // OAuth 授权接口，默认 https://openapi.baidu.com
+	DlinkURL string `json:"dlink_url,omitempty"` // 设置后下载时将 dlink 的协议与主机替换为该地址
+
+	// HTTPClient 发送 API 请求与下载文件使用的 HTTP 客户端，可设置超时、代理、TLS 等，为空时使用默认客户端
+	HTTPClient *http.Client `json:"-"`
+
+	// Logger 客户端使用的日志，为空时使用 SetLogger 设置的包级别日志，默认不输出
+	Logger *slog.Logger `json:"-"`
+
+	// Metrics 指标接收方，为空时不采集
+	Metrics Metrics `json:"-"`
+
+	// ThumbnailCacheDir 缩略图磁盘缓存目录，为空时不缓存；缓存只管理目录中 .thumb 后缀的文件
+	ThumbnailCacheDir string `json:"thumbnail_cache_dir,omitempty"`
+	// ThumbnailCacheSize 缩略图缓存容量（字节），超出时淘汰最久未使用的缩略图，默认 256MB
+	ThumbnailCacheSize int64 `json:"thumbnail_cache_size,omitempty"`
+
+	// TracerProvider 创建 OpenTelemetry span 使用的 TracerProvider，为空时使用 otel 全局 TracerProvider
+	TracerProvider trace.TracerProvider `json:"-"`
 }
 
 // UploadFileConfig 上传文件配置结构体
 type UploadFileConfig struct {
 	Config
-	LocalPath  string `json:"local_path"`  // 本地文件路径
-	RemotePath string `json:"remote_path"` // 远程文件路径
+	LocalPath        string        `json:"local_path"`  // 本地文件路径
+	RemotePath       string        `json:"remote_path"` // 远程文件路径
+	Progress         ProgressFunc  `json:"-"`           // 进度回调，可选
+	ProgressInterval time.Duration `json:"-"`           // 进度回调最小间隔，默认 500ms
 }
 
 // DownloadFileConfig 下载文件配置结构体
 type DownloadFileConfig struct {
 	Config
-	LocalPath  string `json:"local_path"`  // 本地文件路径
-	RemotePath string `json:"remote_path"` // 远程文件路径
+	LocalPath        string        `json:"local_path"`  // 本地文件路径
+	RemotePath       string        `json:"remote_path"` // 远程文件路径
+	Progress         ProgressFunc  `json:"-"`           // 进度回调，可选
+	ProgressInterval time.Duration `json:"-"`           // 进度回调最小间隔，默认 500ms
 }
 
 // QueryDirConfig 查询目录配置结构体
@@ -59,14 +103,22 @@ func NewBasicConfig(accessToken string, isSVIP bool, logPath string) {
 		IsSVIP:      isSVIP,
 		LogPath:     logPath,
 	}
-	Info("NewBasicConfig 创建成功: %v", config)
-	logInit()
+	if logPath != "" {
+		// 指定了日志路径时写入轮转日志文件，不修改标准库 log 的全局设置
+		l, err := NewFileLogger(logPath, slog.LevelInfo)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "baidupanplus: %v\n", err)
+		} else {
+			SetLogger(l)
+		}
+	}
+	packageLogger().Info("config initialized", "is_svip", isSVIP, "log_path", logPath)
 }
 
 // NewUploadFileConfig 实例化 UploadFileConfig
 func NewUploadFileConfig(localPath, remotePath string) UploadFileConfig {
 	if config.AccessToken == "" || config.Operate == "" {
-		Error("BaiduPanPlus Config is not initialized,NewUploadFileConfig Failed")
+		packageLogger().Error("BaiduPanPlus Config is not initialized", logKeyOp, "NewUploadFileConfig")
 		panic("BaiduPanPlus Config is not initialized,NewUploadFileConfig Failed")
 	}
 	defaultUploadFileConfig = UploadFileConfig{
@@ -81,7 +133,7 @@ func NewUploadFileConfig(localPath, remotePath string) UploadFileConfig {
 // NewDownloadFileConfig 实例化 DownloadFileConfig
 func NewDownloadFileConfig(localPath, remotePath string) DownloadFileConfig {
 	if config.AccessToken == "" || config.Operate == "" {
-		Error("BaiduPanPlus Config is not initialized,NewDownloadFileConfig Failed")
+		packageLogger().Error("BaiduPanPlus Config is not initialized", logKeyOp, "NewDownloadFileConfig")
 		panic("BaiduPanPlus Config is not initialized,NewDownloadFileConfig Failed")
 	}
 	defaultDownloadFileConfig = DownloadFileConfig{
@@ -96,7 +148,7 @@ func NewDownloadFileConfig(localPath, remotePath string) DownloadFileConfig {
 // NewQueryDirConfig 实例化 QueryDirConfig
 func NewQueryDirConfig(dir string, limit int) QueryDirConfig {
 	if config.AccessToken == "" || config.Operate == "" {
-		Error("BaiduPanPlus Config is not initialized,NewQueryDirConfig Failed")
+		packageLogger().Error("BaiduPanPlus Config is not initialized", logKeyOp, "NewQueryDirConfig")
 		panic("BaiduPanPlus Config is not initialized,NewQueryDirConfig Failed")
 	}
 	limit32 := int32(limit)
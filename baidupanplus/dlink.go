@@ -0,0 +1,110 @@
+package baidupanplus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// dlinkUserAgent 请求 dlink 必须携带的 User-Agent，否则服务端返回 403
+const dlinkUserAgent = "pan.baidu.com"
+
+// maxDlinkRedirects 请求 dlink 时最多跟随的跳转次数
+const maxDlinkRedirects = 10
+
+// dlinkRefreshMargin dlink 距估算的过期时间不足该时长时，在下次请求前提前刷新
+const dlinkRefreshMargin = 5 * time.Minute
+
+// errDlinkExpired dlink 请求返回 403，通常是 dlink 已过期
+var errDlinkExpired = errors.New("dlink expired or forbidden")
+
+// dlinkHTTPClient 返回请求 dlink 使用的 HTTP 客户端：dlink 会跳转到 CDN 等其它域名，跳转后的请求同样需要 User-Agent
+func dlinkHTTPClient(base *http.Client) *http.Client {
+	client := *base
+	checkRedirect := base.CheckRedirect
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		req.Header.Set("User-Agent", dlinkUserAgent)
+		if checkRedirect != nil {
+			return checkRedirect(req, via)
+		}
+		if len(via) >= maxDlinkRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxDlinkRedirects)
+		}
+		return nil
+	}
+	return &client
+}
+
+// dlinkSource 一个文件的 dlink 及其获取时间，即将过期或请求返回 403 时通过 filemetas 重新获取
+// 长时间排队或分段读取的下载因此不会因为 dlink 过期而失败。可被多个分段并发使用
+type dlinkSource struct {
+	client *Client
+	fsID   int64 // 为 0 时无法刷新，如包级别 DownloadFile 直接传入的 dlink
+	path   string
+
+	mu      sync.Mutex
+	dlink   string
+	expires time.Time
+}
+
+// newDlinkSource 由 filemetas 返回的元数据创建 dlinkSource，meta.Dlink 为空时在第一次请求前获取
+func (c *Client) newDlinkSource(meta *FileMeta) *dlinkSource {
+	return &dlinkSource{client: c, fsID: meta.FsId, path: meta.Path, dlink: meta.Dlink, expires: meta.DlinkExpires}
+}
+
+// open 从 offset 处请求 dlink，dlink 即将过期时先刷新，返回 403 时刷新后重试一次
+func (s *dlinkSource) open(ctx context.Context, offset int64) (*http.Response, error) {
+	dlink, err := s.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.getDlink(ctx, dlink, offset)
+	if !errors.Is(err, errDlinkExpired) || s.fsID == 0 {
+		return resp, err
+	}
+	s.client.logger.Warn("dlink 已失效，重新获取", logKeyOp, "download", logKeyPath, s.path, logKeyError, err)
+	s.client.metrics.IncRetry("download")
+	if dlink, err = s.refresh(ctx, dlink); err != nil {
+		return nil, err
+	}
+	return s.client.getDlink(ctx, dlink, offset)
+}
+
+// current 返回当前的 dlink，尚未获取或即将过期时先获取
+func (s *dlinkSource) current(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	dlink, expires := s.dlink, s.expires
+	s.mu.Unlock()
+	if s.fsID != 0 && dlink == "" {
+		return s.refresh(ctx, "")
+	}
+	if s.fsID == 0 || expires.IsZero() || time.Until(expires) > dlinkRefreshMargin {
+		return dlink, nil
+	}
+	return s.refresh(ctx, dlink)
+}
+
+// refresh 重新获取 dlink；其它分段已用新的 dlink 替换 stale 时直接返回新值
+func (s *dlinkSource) refresh(ctx context.Context, stale string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dlink != stale {
+		return s.dlink, nil
+	}
+	metas, err := s.client.FileMetas(ctx, []int64{s.fsID}, FileMetasOptions{Dlink: true})
+	if err != nil {
+		return "", err
+	}
+	if len(metas) == 0 {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, s.path)
+	}
+	if metas[0].Dlink == "" {
+		return "", fmt.Errorf("dlink not found")
+	}
+	s.dlink, s.expires = metas[0].Dlink, metas[0].DlinkExpires
+	s.client.logger.Debug("已刷新 dlink", logKeyOp, "download", logKeyPath, s.path)
+	return s.dlink, nil
+}
@@ -0,0 +1,92 @@
+package baidupanplus
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/S-zhi/baidupansdk/baidupantest"
+)
+
+func TestDlinkExpiresMidTransfer(t *testing.T) {
+	data := testData(3<<20 + 11)
+	tests := []struct {
+		name string
+		read func(t *testing.T, c *Client) ([]byte, error)
+	}{
+		{"DownloadTo", func(t *testing.T, c *Client) ([]byte, error) {
+			var buf bytes.Buffer
+			_, err := c.DownloadTo(context.Background(), "/apps/test/a.bin", &buf)
+			return buf.Bytes(), err
+		}},
+		{"DownloadFile", func(t *testing.T, c *Client) ([]byte, error) {
+			local := filepath.Join(t.TempDir(), "a.bin")
+			if err := c.DownloadFile(context.Background(), "/apps/test/a.bin", local); err != nil {
+				return nil, err
+			}
+			return os.ReadFile(local)
+		}},
+		{"resumable", func(t *testing.T, c *Client) ([]byte, error) {
+			local := filepath.Join(t.TempDir(), "a.bin")
+			var cp downloadCheckpoint
+			if _, err := c.downloadResumable(context.Background(), "/apps/test/a.bin", local, DownloadOptions{}, &cp, func() error { return nil }); err != nil {
+				return nil, err
+			}
+			return os.ReadFile(local)
+		}},
+		{"Open", func(t *testing.T, c *Client) ([]byte, error) {
+			f, err := c.Open(context.Background(), "/apps/test/a.bin")
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+			return io.ReadAll(f)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, srv := newTestClient(t)
+			srv.AddFile("/apps/test/a.bin", data)
+			// dlink 的有效期短于续传前的等待时间：第一次请求输出 1MB 后断开，续传时 dlink 已过期
+			srv.SetDlinkTTL(streamResumeDelay / 2)
+			srv.InjectFault(baidupantest.OpDownload, baidupantest.Fault{DropAfter: 1 << 20, Times: 1})
+
+			got, err := tt.read(t, c)
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Fatalf("content differs: got %d bytes, want %d", len(got), len(data))
+			}
+			// 首次获取 dlink 一次，续传时收到 403 后刷新一次
+			if n := srv.Requests(baidupantest.OpFileMetas); n != 2 {
+				t.Errorf("filemetas requests = %d, want 2", n)
+			}
+		})
+	}
+}
+
+func TestDlinkRefreshFailureStopsResume(t *testing.T) {
+	c, srv := newTestClient(t)
+	srv.AddFile("/apps/test/a.bin", testData(2<<20))
+	srv.SetDlinkTTL(time.Millisecond)
+	f, err := c.Open(context.Background(), "/apps/test/a.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	time.Sleep(10 * time.Millisecond)
+	// 刷新 dlink 的请求一直失败时，读取在有限次续传后返回错误
+	srv.InjectFault(baidupantest.OpFileMetas, baidupantest.Fault{Errno: 31066})
+
+	if _, err := io.ReadAll(f); err == nil {
+		t.Fatal("read succeeded although the dlink could not be refreshed")
+	}
+	if n := srv.Requests(baidupantest.OpDownload); n > 1+maxStreamResumes {
+		t.Errorf("download requests = %d, want at most %d", n, 1+maxStreamResumes)
+	}
+}
@@ -0,0 +1,118 @@
+package baidupanplus
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LocalConflictPolicy 下载时本地路径已存在的处理策略
+type LocalConflictPolicy int
+
+const (
+	LocalConflictFail      LocalConflictPolicy = iota // 返回满足 errors.Is(err, fs.ErrExist) 的错误
+	LocalConflictOverwrite                            // 下载完成后替换本地文件
+	LocalConflictSkip                                 // 不下载，保留本地文件
+	LocalConflictRename                               // 写入 name (1).ext 等不冲突的新路径
+)
+
+// DownloadOptions DownloadFileWithOptions 的选项
+type DownloadOptions struct {
+	// OnConflict 本地路径已存在时的处理策略，默认 LocalConflictFail
+	OnConflict LocalConflictPolicy
+
+	// SkipIfSame 本地文件与远程文件大小相同且修改时间等于服务端修改时间时跳过下载，不满足时按 OnConflict 处理
+	// 下载写入的文件会保留服务端修改时间，因此可用于重复下载同一目录
+	SkipIfSame bool
+
+	// Fsync 重命名为目标路径前将数据同步到磁盘
+	Fsync bool
+}
+
+// DownloadResult 下载结果
+type DownloadResult struct {
+	LocalPath string // 实际写入的本地路径，OnConflict 为 LocalConflictRename 时可能与请求的路径不同
+	Size      int64
+	Skipped   bool // 本地文件已存在且未下载
+}
+
+// tempFilePattern 下载中的临时文件名，与目标文件位于同一目录，下载成功后重命名为目标文件
+const tempFilePattern = ".%s.baidupan-*.tmp"
+
+// writeFileAtomic 将 write 写入的内容先写到 localPath 同目录的临时文件，成功后重命名为 localPath
+// 失败时删除临时文件，localPath 原有内容不受影响；mtime 非零时设置为写入文件的修改时间
+func writeFileAtomic(localPath string, fsync bool, mtime time.Time, write func(w io.Writer) error) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(localPath), fmt.Sprintf(tempFilePattern, filepath.Base(localPath)))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	if err := write(tmp); err != nil {
+		return err
+	}
+	if fsync {
+		if err := tmp.Sync(); err != nil {
+			return err
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return commitTempFile(tmp.Name(), localPath, mtime)
+}
+
+// commitTempFile 设置临时文件的权限与修改时间后重命名为 localPath
+func commitTempFile(tmpPath string, localPath string, mtime time.Time) error {
+	// CreateTemp 创建的文件权限为 0600，覆盖时沿用原文件的权限
+	perm := os.FileMode(0644)
+	if st, err := os.Stat(localPath); err == nil {
+		perm = st.Mode().Perm()
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return err
+	}
+	if !mtime.IsZero() {
+		if err := os.Chtimes(tmpPath, mtime, mtime); err != nil {
+			return err
+		}
+	}
+	return os.Rename(tmpPath, localPath)
+}
+
+// serverMtime 返回文件的服务端修改时间，未知时为零值
+func serverMtime(meta *FileMeta) time.Time {
+	if meta.ServerMtime <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(meta.ServerMtime, 0)
+}
+
+// sameLocalFile 本地文件是否与远程文件大小相同且修改时间一致
+func sameLocalFile(st fs.FileInfo, meta *FileMeta) bool {
+	return st.Mode().IsRegular() && st.Size() == meta.Size && st.ModTime().Unix() == meta.ServerMtime
+}
+
+// freeLocalPath 为已存在的 localPath 生成不冲突的新路径：name (1).ext、name (2).ext ……
+func freeLocalPath(localPath string) (string, error) {
+	ext := filepath.Ext(localPath)
+	base := strings.TrimSuffix(localPath, ext)
+	for i := 1; ; i++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, i, ext)
+		if _, err := os.Lstat(candidate); errors.Is(err, fs.ErrNotExist) {
+			return candidate, nil
+		} else if err != nil {
+			return "", err
+		}
+	}
+}
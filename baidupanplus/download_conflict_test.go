@@ -0,0 +1,179 @@
+package baidupanplus
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/S-zhi/baidupansdk/baidupantest"
+)
+
+func TestDownloadConflictPolicies(t *testing.T) {
+	data := testData(1<<20 + 5)
+	old := []byte("local content")
+	tests := []struct {
+		name      string
+		opts      DownloadOptions
+		wantErr   error
+		wantPath  string // 相对临时目录
+		skipped   bool
+		original  []byte // 下载后 a.bin 的内容
+		downloads int
+	}{
+		{"fail", DownloadOptions{}, fs.ErrExist, "", false, old, 0},
+		{"overwrite", DownloadOptions{OnConflict: LocalConflictOverwrite, Fsync: true}, nil, "a.bin", false, data, 1},
+		{"skip", DownloadOptions{OnConflict: LocalConflictSkip}, nil, "a.bin", true, old, 0},
+		{"rename", DownloadOptions{OnConflict: LocalConflictRename}, nil, "a (2).bin", false, old, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, srv := newTestClient(t)
+			srv.AddFile("/apps/test/a.bin", data)
+			dir := t.TempDir()
+			local := filepath.Join(dir, "a.bin")
+			if err := os.WriteFile(local, old, 0600); err != nil {
+				t.Fatal(err)
+			}
+			// name (1).ext 已被占用时使用下一个编号
+			if err := os.WriteFile(filepath.Join(dir, "a (1).bin"), nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := c.DownloadFileWithOptions(context.Background(), "/apps/test/a.bin", local, tt.opts)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if res.LocalPath != filepath.Join(dir, tt.wantPath) || res.Skipped != tt.skipped {
+					t.Errorf("result = %+v, want path %s, skipped %v", res, tt.wantPath, tt.skipped)
+				}
+				if got, _ := os.ReadFile(res.LocalPath); !tt.skipped && !bytes.Equal(got, data) {
+					t.Errorf("%s has %d bytes, want the remote content", res.LocalPath, len(got))
+				}
+			}
+			if got, _ := os.ReadFile(local); !bytes.Equal(got, tt.original) {
+				t.Errorf("a.bin has %d bytes after download, want %d", len(got), len(tt.original))
+			}
+			if n := srv.Requests(baidupantest.OpDownload); n != tt.downloads {
+				t.Errorf("download requests = %d, want %d", n, tt.downloads)
+			}
+			// 跳过时不查询远程文件
+			if tt.skipped && srv.Requests(baidupantest.OpFileMetas) != 0 {
+				t.Error("skip policy queried the remote file")
+			}
+		})
+	}
+}
+
+func TestDownloadPreservesModeAndMtime(t *testing.T) {
+	c, srv := newTestClient(t)
+	srv.AddFile("/apps/test/a.bin", testData(1000))
+	remote, err := c.Stat(context.Background(), "/apps/test/a.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+
+	fresh := filepath.Join(dir, "fresh.bin")
+	if _, err := c.DownloadFileWithOptions(context.Background(), "/apps/test/a.bin", fresh, DownloadOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	st, err := os.Stat(fresh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Mode().Perm() != 0644 || st.ModTime().Unix() != remote.ServerMtime {
+		t.Errorf("new file mode %v mtime %d, want 0644 and %d", st.Mode().Perm(), st.ModTime().Unix(), remote.ServerMtime)
+	}
+
+	// 覆盖时沿用原文件的权限
+	existing := filepath.Join(dir, "existing.bin")
+	if err := os.WriteFile(existing, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DownloadFile(context.Background(), "/apps/test/a.bin", existing); err != nil {
+		t.Fatal(err)
+	}
+	if st, err := os.Stat(existing); err != nil || st.Mode().Perm() != 0600 {
+		t.Errorf("overwritten file mode = %v (%v), want 0600", st.Mode().Perm(), err)
+	}
+}
+
+func TestDownloadSkipIfSame(t *testing.T) {
+	c, srv := newTestClient(t)
+	srv.AddFile("/apps/test/a.bin", testData(1000))
+	local := filepath.Join(t.TempDir(), "a.bin")
+	ctx := context.Background()
+	opts := DownloadOptions{OnConflict: LocalConflictOverwrite, SkipIfSame: true}
+
+	// 首次下载写入服务端修改时间，再次下载时大小与修改时间一致，跳过
+	if res, err := c.DownloadFileWithOptions(ctx, "/apps/test/a.bin", local, opts); err != nil || res.Skipped {
+		t.Fatalf("first download = %+v, %v", res, err)
+	}
+	res, err := c.DownloadFileWithOptions(ctx, "/apps/test/a.bin", local, opts)
+	if err != nil || !res.Skipped || res.Size != 1000 {
+		t.Fatalf("second download = %+v, %v, want skipped", res, err)
+	}
+	if n := srv.Requests(baidupantest.OpDownload); n != 1 {
+		t.Errorf("download requests = %d, want 1", n)
+	}
+
+	// 修改时间不同时按 OnConflict 处理
+	if err := os.Chtimes(local, time.Unix(1, 0), time.Unix(1, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.DownloadFileWithOptions(ctx, "/apps/test/a.bin", local, DownloadOptions{SkipIfSame: true}); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("error = %v, want fs.ErrExist", err)
+	}
+	if res, err := c.DownloadFileWithOptions(ctx, "/apps/test/a.bin", local, opts); err != nil || res.Skipped {
+		t.Errorf("download with changed mtime = %+v, %v, want downloaded", res, err)
+	}
+
+	// 大小不同时同样重新下载
+	if err := os.WriteFile(local, []byte("short"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	remote, _ := c.Stat(ctx, "/apps/test/a.bin")
+	mtime := time.Unix(remote.ServerMtime, 0)
+	if err := os.Chtimes(local, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if res, err := c.DownloadFileWithOptions(ctx, "/apps/test/a.bin", local, opts); err != nil || res.Skipped || res.Size != 1000 {
+		t.Errorf("download with changed size = %+v, %v, want downloaded", res, err)
+	}
+	if n := srv.Requests(baidupantest.OpDownload); n != 3 {
+		t.Errorf("download requests = %d, want 3", n)
+	}
+}
+
+func TestDownloadOverwriteFailureKeepsLocalFile(t *testing.T) {
+	c, srv := newTestClient(t)
+	srv.AddFile("/apps/test/a.bin", testData(1<<20))
+	srv.InjectFault(baidupantest.OpDownload, baidupantest.Fault{DropConnection: true})
+	dir := t.TempDir()
+	local := filepath.Join(dir, "a.bin")
+	old := []byte("local content")
+	if err := os.WriteFile(local, old, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.DownloadFileWithOptions(context.Background(), "/apps/test/a.bin", local, DownloadOptions{OnConflict: LocalConflictOverwrite}); err == nil {
+		t.Fatal("download succeeded despite dropped connections")
+	}
+	if got, _ := os.ReadFile(local); !bytes.Equal(got, old) {
+		t.Errorf("failed download replaced the local file with %d bytes", len(got))
+	}
+	// 不留下临时文件
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("download left %d entries in the directory", len(entries))
+	}
+}
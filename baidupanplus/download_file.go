@@ -1,75 +1,36 @@
 package baidupanplus
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"net/http"
 	"net/url"
 	"os"
 	"path"
 	"strconv"
-)
-
-// FileMeta 百度网盘文件元数据结构
-type FileMeta struct {
-	FsId     int64  `json:"fs_id"`
-	Path     string `json:"path"`
-	Filename string `json:"server_filename"`
-	Size     int64  `json:"size"`
-	Dlink    string `json:"dlink"`
-}
-
-// FileMetasResponse 文件详情响应
-type FileMetasResponse struct {
-	Errno     int32       `json:"errno"`
-	List      []FileMeta  `json:"list"`
-	RequestId interface{} `json:"request_id"`
-}
-
-// GetFileMetas 获取文件详情（包含 dlink）
-func GetFileMetas(accessToken string, fsids []int64) (*FileMetasResponse, error) {
-	fsidsByte, _ := json.Marshal(fsids)
-	fsidsStr := string(fsidsByte)
-
-	apiXpanmetasRequest := client.MultimediafileApi.Xpanmultimediafilemetas(ctx).
-		AccessToken(accessToken).
-		Fsids(fsidsStr).
-		Dlink("1") // 必须设置为 "1" 才会返回下载链接
+	"time"
 
-	jsonStr, _, err := client.MultimediafileApi.XpanmultimediafilemetasExecute(apiXpanmetasRequest)
-	if err != nil {
-		Error(fmt.Sprintf("Failed to execute Xpanmultimediafilemetas: %v", err))
-		return nil, err
-	}
-
-	var metasResp FileMetasResponse
-	err = json.Unmarshal([]byte(jsonStr), &metasResp)
-	if err != nil {
-		return nil, err
-	}
-
-	if metasResp.Errno != 0 {
-		return nil, fmt.Errorf("get file metas failed with errno: %d", metasResp.Errno)
-	}
-
-	return &metasResp, nil
-}
+	"github.com/S-zhi/baidupansdk/internal/redact"
+)
 
 // findFileFsIdByPath 根据路径查找文件的fs_id（支持分页查找）
-func findFileFsIdByPath(accessToken string, dir string, filename string) (int64, error) {
+func (c *Client) findFileFsIdByPath(ctx context.Context, dir string, filename string) (int64, error) {
 	start := 0
 	limit := 1000
 
 	for {
 		// 调用 SDK 的 list 接口
-		apiReq := client.FileinfoApi.Xpanfilelist(ctx).
-			AccessToken(accessToken).
+		apiReq := c.api.FileinfoApi.Xpanfilelist(ctx).
+			AccessToken(c.cfg.AccessToken).
 			Dir(dir).
 			Start(strconv.Itoa(start)).
 			Limit(int32(limit))
 
-		jsonStr, _, err := client.FileinfoApi.XpanfilelistExecute(apiReq)
+		jsonStr, _, err := c.api.FileinfoApi.XpanfilelistExecute(apiReq)
 		if err != nil {
 			return 0, fmt.Errorf("execute list api failed: %v", err)
 		}
@@ -81,7 +42,7 @@ func findFileFsIdByPath(accessToken string, dir string, filename string) (int64,
 		}
 
 		if fileListResp.Errno != 0 {
-			return 0, fmt.Errorf("get file list failed with errno: %d", fileListResp.Errno)
+			return 0, newErrnoError("get file list", fileListResp.Errno)
 		}
 
 		// 遍历查找
@@ -99,131 +60,248 @@ func findFileFsIdByPath(accessToken string, dir string, filename string) (int64,
 		start += limit
 	}
 
-	return 0, fmt.Errorf("file not found: %s/%s", dir, filename)
+	return 0, fmt.Errorf("%w: %s", ErrNotFound, path.Join(dir, filename))
+}
+
+// resolveFile 根据远程路径获取文件元数据（包含 dlink）
+func (c *Client) resolveFile(ctx context.Context, remotePath string) (*FileMeta, error) {
+	// 1. 获取文件所在目录和文件名
+	dir := path.Dir(remotePath)
+	filename := path.Base(remotePath)
+
+	// 2. 查找文件获取 fs_id
+	targetFsId, err := c.findFileFsIdByPath(ctx, dir, filename)
+	if err != nil {
+		c.logger.Error("查找文件失败", logKeyOp, "download", logKeyPath, remotePath, logKeyError, err)
+		return nil, err
+	}
+
+	// 3. 获取文件详情（获取dlink）
+	metasResp, err := c.getFileMetas(ctx, []int64{targetFsId})
+	if err != nil {
+		c.logger.Error("获取文件详情失败", logKeyOp, "download", logKeyPath, remotePath, logKeyError, err)
+		return nil, err
+	}
+
+	if len(metasResp.List) == 0 {
+		c.logger.Error("未获取到文件元数据", logKeyOp, "download", logKeyPath, remotePath)
+		return nil, fmt.Errorf("no file meta data found")
+	}
+
+	if metasResp.List[0].Dlink == "" {
+		c.logger.Error("未获取到下载链接", logKeyOp, "download", logKeyPath, remotePath)
+		return nil, fmt.Errorf("dlink not found")
+	}
+	return &metasResp.List[0], nil
 }
 
 // DownloadFileWithConfig 使用DownloadFileConfig配置下载文件
 func DownloadFileWithConfig(config DownloadFileConfig) error {
 	// 验证配置参数
 	if config.AccessToken == "" {
-		Error("AccessToken不能为空")
+		packageLogger().Error("AccessToken不能为空", logKeyOp, "download")
 		return fmt.Errorf("access token is required")
 	}
 	if config.RemotePath == "" {
-		Error("RemotePath不能为空")
+		packageLogger().Error("RemotePath不能为空", logKeyOp, "download")
 		return fmt.Errorf("remote path is required")
 	}
 	if config.LocalPath == "" {
-		Error("LocalPath不能为空")
+		packageLogger().Error("LocalPath不能为空", logKeyOp, "download")
 		return fmt.Errorf("local path is required")
 	}
 
-	Info("开始下载文件: remote=%s, local=%s", config.RemotePath, config.LocalPath)
+	downloadCtx := WithProgress(ctx, config.Progress, config.ProgressInterval)
+	return packageClient(config.Config).DownloadFile(downloadCtx, config.RemotePath, config.LocalPath)
+}
 
-	// 1. 获取文件所在目录和文件名
-	dir := path.Dir(config.RemotePath)
-	filename := path.Base(config.RemotePath)
+// DownloadFile 下载远程文件 remotePath 到本地 localPath，localPath 已存在时覆盖
+// 通过 WithProgress 设置的进度回调会收到下载与校验阶段的进度
+func (c *Client) DownloadFile(ctx context.Context, remotePath string, localPath string) error {
+	_, err := c.DownloadFileWithOptions(ctx, remotePath, localPath, DownloadOptions{OnConflict: LocalConflictOverwrite})
+	return err
+}
 
-	// 2. 查找文件获取 fs_id
-	targetFsId, err := findFileFsIdByPath(config.AccessToken, dir, filename)
-	if err != nil {
-		Error("查找文件失败: %v", err)
-		return err
+// DownloadFileWithOptions 按 opts 下载远程文件 remotePath 到本地 localPath
+// 数据先写入同目录的临时文件，下载完整后才重命名为目标文件，失败时不会留下不完整的文件；写入的文件保留服务端修改时间
+func (c *Client) DownloadFileWithOptions(ctx context.Context, remotePath string, localPath string, opts DownloadOptions) (_ *DownloadResult, err error) {
+	ctx, span := c.startSpan(ctx, "DownloadFile", attrRemotePath.String(remotePath), attrLocalPath.String(localPath))
+	defer func() { endSpan(span, err) }()
+
+	c.logger.Info("开始下载文件", logKeyOp, "download", logKeyPath, remotePath, logKeyLocalPath, localPath)
+
+	// 1. 处理本地冲突，根据路径获取文件详情（获取dlink）
+	meta, localPath, skipped, err := c.prepareDownload(ctx, remotePath, localPath, opts)
+	if err != nil || skipped != nil {
+		return skipped, err
 	}
 
-	// 3. 获取文件详情（获取dlink）
-	metasResp, err := GetFileMetas(config.AccessToken, []int64{targetFsId})
+	// 2. 下载文件
+	written, err := c.downloadFile(ctx, remotePath, c.newDlinkSource(meta), localPath, opts.Fsync, serverMtime(meta))
 	if err != nil {
-		Error("获取文件详情失败: %v", err)
-		return err
+		c.logger.Error("下载文件失败", logKeyOp, "download", logKeyPath, remotePath, logKeyError, err)
+		return nil, err
 	}
 
-	if len(metasResp.List) == 0 {
-		Error("未获取到文件元数据")
-		return fmt.Errorf("no file meta data found")
-	}
+	c.logger.Info("下载流程完成", logKeyOp, "download", logKeyPath, remotePath)
+	return &DownloadResult{LocalPath: localPath, Size: written}, nil
+}
 
-	dlink := metasResp.List[0].Dlink
-	if dlink == "" {
-		Error("未获取到下载链接")
-		return fmt.Errorf("dlink not found")
+// prepareDownload 按 opts.OnConflict 处理已存在的本地文件并获取远程文件元数据
+// 返回实际要写入的本地路径；需要跳过下载时 skipped 非 nil
+func (c *Client) prepareDownload(ctx context.Context, remotePath string, localPath string, opts DownloadOptions) (meta *FileMeta, target string, skipped *DownloadResult, err error) {
+	local, err := os.Stat(localPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, "", nil, err
+	}
+	if local != nil && opts.OnConflict == LocalConflictSkip {
+		return nil, "", c.skipDownload(remotePath, localPath, local.Size()), nil
 	}
 
-	// 4. 下载文件
-	err = DownloadFile(config.AccessToken, dlink, config.LocalPath)
+	meta, err = c.resolveFile(ctx, remotePath)
 	if err != nil {
-		Error("下载文件失败: %v", err)
-		return err
+		return nil, "", nil, err
+	}
+
+	if local != nil {
+		switch {
+		case opts.SkipIfSame && sameLocalFile(local, meta):
+			return nil, "", c.skipDownload(remotePath, localPath, local.Size()), nil
+		case opts.OnConflict == LocalConflictFail:
+			return nil, "", nil, fmt.Errorf("download %s: %w: %s", remotePath, fs.ErrExist, localPath)
+		case opts.OnConflict == LocalConflictRename:
+			if localPath, err = freeLocalPath(localPath); err != nil {
+				return nil, "", nil, err
+			}
+		}
 	}
+	return meta, localPath, nil, nil
+}
 
-	Info("下载流程完成")
-	return nil
+// skipDownload 记录因本地文件已存在而跳过的下载
+func (c *Client) skipDownload(remotePath string, localPath string, size int64) *DownloadResult {
+	c.logger.Info("本地文件已存在，跳过下载", logKeyOp, "download", logKeyPath, remotePath, logKeyLocalPath, localPath)
+	return &DownloadResult{LocalPath: localPath, Size: size, Skipped: true}
 }
 
 // DownloadFile 下载文件
 func DownloadFile(accessToken string, dlink string, localPath string) error {
+	c := packageClient(Config{AccessToken: accessToken})
+	_, err := c.downloadFile(ctx, "", &dlinkSource{client: c, dlink: dlink}, localPath, false, time.Time{})
+	return err
+}
+
+// downloadFile 通过 dlink 下载文件到 localPath，经临时文件原子写入，mtime 非零时设置为文件的修改时间
+func (c *Client) downloadFile(ctx context.Context, remotePath string, src *dlinkSource, localPath string, fsync bool, mtime time.Time) (int64, error) {
+	c.logger.Debug("开始写入文件", logKeyOp, "download", logKeyLocalPath, localPath)
+	var written int64
+	err := writeFileAtomic(localPath, fsync, mtime, func(w io.Writer) error {
+		var err error
+		written, err = c.copyDlink(ctx, remotePath, src, w)
+		return err
+	})
+	if err != nil {
+		c.logger.Error("写入本地文件失败", logKeyOp, "download", logKeyLocalPath, localPath, logKeyError, err)
+		return written, err
+	}
+
+	c.logger.Info("文件下载成功", logKeyOp, "download", logKeyPath, remotePath, logKeyLocalPath, localPath, logKeySize, written)
+	return written, nil
+}
+
+// copyDlink 将 dlink 的内容完整写入 w，并校验写入长度与响应长度一致，连接中断时从已写入的位置续传
+func (c *Client) copyDlink(ctx context.Context, remotePath string, src *dlinkSource, w io.Writer) (int64, error) {
+	defer c.trackTransfer(DirectionDownload)()
+	progress := progressFromContext(ctx)
+	stream := newDlinkStream(ctx, src, 0, -1)
+	if err := stream.open(); err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err := stream.Close(); err != nil {
+			c.logger.Warn("关闭响应体失败", logKeyOp, "download", logKeyPath, remotePath, logKeyError, err)
+		}
+	}()
+
+	// 使用 io.Copy 流式写入，避免内存溢出；连接中断时 stream 从已写入的位置续传
+	progress.start(PhaseDownloading, remotePath, stream.size)
+	written, err := io.Copy(w, progress.reader(stream))
+	if err != nil {
+		c.logger.Error("写入数据失败", logKeyOp, "download", logKeyPath, remotePath, logKeySize, written, logKeyError, err)
+		return written, err
+	}
+
+	progress.start(PhaseVerifying, remotePath, stream.size)
+	if stream.size >= 0 && written != stream.size {
+		return written, fmt.Errorf("incomplete download: expected %d bytes, got %d", stream.size, written)
+	}
+	progress.done()
+	return written, nil
+}
+
+// getDlink 请求 dlink，offset > 0 时以 Range 方式从 offset 处开始读取
+// 返回的响应状态已校验为 200/206，Body 已施加限速，调用方负责关闭 Body；返回 403 时错误满足 errors.Is(err, errDlinkExpired)
+// 记录的 span 只覆盖到收到响应头为止，不包含读取 Body 的时间
+func (c *Client) getDlink(ctx context.Context, dlink string, offset int64) (_ *http.Response, err error) {
+	ctx, span := c.startSpan(ctx, "dlink", attrOffset.Int64(offset))
+	defer func() { endSpan(span, err) }()
+
 	// 解析 dlink URL
 	u, err := url.Parse(dlink)
 	if err != nil {
-		Error("解析dlink失败: %v", err)
-		return err
+		err = redact.Error(err)
+		c.logger.Error("解析dlink失败", logKeyOp, "download", logKeyError, err)
+		return nil, err
+	}
+	if err := c.rewriteDlink(u); err != nil {
+		return nil, fmt.Errorf("invalid dlink url: %w", redact.Error(err))
 	}
 
 	// 百度网盘下载必须携带 User-Agent: pan.baidu.com
 	// 并且 access_token 需要作为 query 参数传递
 	q := u.Query()
-	q.Set("access_token", accessToken)
+	q.Set("access_token", c.cfg.AccessToken)
 	u.RawQuery = q.Encode()
 
-	req, err := http.NewRequest("GET", u.String(), nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
 	if err != nil {
-		Error("创建HTTP请求失败: %v", err)
-		return err
+		c.logger.Error("创建HTTP请求失败", logKeyOp, "download", logKeyError, err)
+		return nil, err
+	}
+	req.Header.Set("User-Agent", dlinkUserAgent)
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
 	}
-	req.Header.Set("User-Agent", "pan.baidu.com")
 
-	Info("发送下载请求到: %s", u.String())
+	c.logger.Debug("发送下载请求", logKeyOp, "download", "url", u.String(), "offset", offset)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.dlinkClient.Do(req)
 	if err != nil {
-		Error("HTTP请求失败: %v", err)
-		return err
+		err = redact.Error(err)
+		c.logger.Error("HTTP请求失败", logKeyOp, "download", logKeyError, err)
+		return nil, err
 	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			Error("关闭响应体失败: %v", err)
-		}
-	}(resp.Body)
+	span.SetAttributes(attrHTTPStatus.Int(resp.StatusCode), attrSize.Int64(resp.ContentLength))
 
-	if resp.StatusCode != http.StatusOK {
-		Error("下载失败，状态码: %s", resp.Status)
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
 		// 尝试读取body看是否有错误信息
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		Error("错误响应: %s", string(bodyBytes))
-		return fmt.Errorf("download failed with status: %s", resp.Status)
-	}
-
-	out, err := os.Create(localPath)
-	if err != nil {
-		Error("创建本地文件失败: %v", err)
-		return err
-	}
-	defer func(out *os.File) {
-		err := out.Close()
-		if err != nil {
-			Error("关闭本地文件失败: %v", err)
+		_ = resp.Body.Close()
+		c.logger.Error("下载失败", logKeyOp, "download", logKeyStatus, resp.StatusCode, "body", string(bodyBytes))
+		if resp.StatusCode == http.StatusForbidden {
+			return nil, fmt.Errorf("%w: download failed with status: %s", errDlinkExpired, resp.Status)
 		}
-	}(out)
-
-	Info("开始写入文件到: %s", localPath)
-	// 使用 io.Copy 流式写入，避免内存溢出
-	written, err := io.Copy(out, resp.Body)
-	if err != nil {
-		Error("写入文件失败: %v", err)
-		return err
+		return nil, fmt.Errorf("download failed with status: %s", resp.Status)
 	}
-
-	Info("文件下载成功: %s, 大小: %d bytes", localPath, written)
-	return nil
+	if offset > 0 && resp.StatusCode != http.StatusPartialContent {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("server ignored range request, status: %s", resp.Status)
+	}
+	// 对下载数据施加客户端级别与单次传输级别的限速，并统计下载字节数
+	body := limitReadCloser(ctx, resp.Body, c.rateLimitWaitObserver(DirectionDownload), c.downloadLimiter, rateLimiterFromContext(ctx))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{c.meterReader(DirectionDownload, body), body}
+	return resp, nil
 }
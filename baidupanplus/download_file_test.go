@@ -0,0 +1,123 @@
+package baidupanplus
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/S-zhi/baidupansdk/baidupantest"
+)
+
+func TestDownloadFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		redirect bool
+	}{
+		{"direct", false},
+		{"redirect", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, srv := newTestClient(t)
+			srv.SetDlinkRedirect(tt.redirect)
+			data := testData(5<<20 + 3)
+			srv.AddFile("/apps/test/a.bin", data)
+			local := filepath.Join(t.TempDir(), "a.bin")
+
+			if err := c.DownloadFile(context.Background(), "/apps/test/a.bin", local); err != nil {
+				t.Fatalf("download: %v", err)
+			}
+			got, err := os.ReadFile(local)
+			if err != nil || !bytes.Equal(got, data) {
+				t.Fatalf("local content differs (%d bytes, err %v)", len(got), err)
+			}
+		})
+	}
+}
+
+func TestDownloadFileFaults(t *testing.T) {
+	tests := []struct {
+		name  string
+		op    baidupantest.Op
+		fault baidupantest.Fault
+		errno int
+	}{
+		{"filemetas errno", baidupantest.OpFileMetas, baidupantest.Fault{Errno: 31066}, 31066},
+		{"server error", baidupantest.OpDownload, baidupantest.Fault{StatusCode: http.StatusInternalServerError}, 0},
+		{"dropped connection", baidupantest.OpDownload, baidupantest.Fault{DropConnection: true}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, srv := newTestClient(t)
+			srv.AddFile("/apps/test/a.bin", testData(1<<20))
+			srv.InjectFault(tt.op, tt.fault)
+			dir := t.TempDir()
+
+			err := c.DownloadFile(context.Background(), "/apps/test/a.bin", filepath.Join(dir, "a.bin"))
+			if err == nil {
+				t.Fatal("download succeeded despite injected fault")
+			}
+			var errnoErr *ErrnoError
+			if tt.errno != 0 && (!errors.As(err, &errnoErr) || errnoErr.Errno != tt.errno) {
+				t.Errorf("error = %v, want errno %d", err, tt.errno)
+			}
+			// 失败的下载不留下目标文件或临时文件
+			if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+				t.Errorf("download left %d files behind, first %s", len(entries), entries[0].Name())
+			}
+		})
+	}
+}
+
+func TestOpenRefreshesExpiredDlink(t *testing.T) {
+	c, srv := newTestClient(t)
+	data := testData(1 << 20)
+	srv.AddFile("/apps/test/a.bin", data)
+	srv.SetDlinkTTL(100 * time.Millisecond)
+
+	f, err := c.Open(context.Background(), "/apps/test/a.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := io.ReadFull(f, make([]byte, 10)); err != nil {
+		t.Fatalf("first read: %v", err)
+	}
+	metas := srv.Requests(baidupantest.OpFileMetas)
+
+	// 等待 dlink 过期，回到开头重新读取时会发起新的请求
+	time.Sleep(200 * time.Millisecond)
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(f)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("read after expiry: %d bytes, err %v", len(got), err)
+	}
+	if n := srv.Requests(baidupantest.OpFileMetas); n != metas+1 {
+		t.Errorf("filemetas requests = %d, want %d (one refresh)", n, metas+1)
+	}
+}
+
+func TestCopyDlinkExpiredWithoutFsID(t *testing.T) {
+	c, srv := newTestClient(t)
+	srv.AddFile("/apps/test/a.bin", testData(100))
+	srv.SetDlinkTTL(time.Millisecond)
+	meta, err := c.resolveFile(context.Background(), "/apps/test/a.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	// 直接传入的 dlink 没有 fs_id，无法刷新，应返回过期错误而不是重试
+	_, err = c.copyDlink(context.Background(), "", &dlinkSource{client: c, dlink: meta.Dlink}, io.Discard)
+	if !errors.Is(err, errDlinkExpired) {
+		t.Fatalf("error = %v, want errDlinkExpired", err)
+	}
+}
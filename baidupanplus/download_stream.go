@@ -0,0 +1,305 @@
+package baidupanplus
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// defaultReadahead Open 返回的读取器默认预读缓冲大小
+const defaultReadahead = 4 * 1024 * 1024
+
+// maxStreamResumes 下载连接中断后，连续没有读到数据的续传请求次数上限
+const maxStreamResumes = 3
+
+// streamResumeDelay 第 n 次续传前等待 n 倍的该时长
+const streamResumeDelay = 200 * time.Millisecond
+
+// DownloadTo 将远程文件内容流式写入 w，不经过本地磁盘
+// 返回写入的字节数
+func (c *Client) DownloadTo(ctx context.Context, remotePath string, w io.Writer) (_ int64, err error) {
+	ctx, span := c.startSpan(ctx, "DownloadTo", attrRemotePath.String(remotePath))
+	defer func() { endSpan(span, err) }()
+
+	meta, err := c.resolveFile(ctx, remotePath)
+	if err != nil {
+		return 0, err
+	}
+
+	written, err := c.copyDlink(ctx, remotePath, c.newDlinkSource(meta), w)
+	if err != nil {
+		return written, err
+	}
+	c.logger.Info("流式下载完成", logKeyOp, "download", logKeyPath, remotePath, logKeySize, written)
+	return written, nil
+}
+
+// Open 以只读方式打开远程文件，返回支持 Seek 的读取器
+// 读取通过 dlink 的 Range 请求完成：顺序读取复用同一个连接并带预读缓冲，
+// Seek 到缓冲区之外时才重新发起请求，dlink 过期时自动重新获取。返回的读取器不是并发安全的
+func (c *Client) Open(ctx context.Context, remotePath string) (_ io.ReadSeekCloser, err error) {
+	spanCtx, span := c.startSpan(ctx, "Open", attrRemotePath.String(remotePath))
+	defer func() { endSpan(span, err) }()
+
+	// span 只覆盖路径解析，之后的读取由各次 dlink 请求各自记录 span
+	meta, err := c.resolveFile(spanCtx, remotePath)
+	if err != nil {
+		return nil, err
+	}
+	return c.openMeta(ctx, meta), nil
+}
+
+// OpenByFsID 按 fs_id 打开远程文件，行为与 Open 相同
+// 已通过 List / Stat 得到 FileInfo 时使用，可省去按路径查找文件的请求
+func (c *Client) OpenByFsID(ctx context.Context, fsID int64) (_ io.ReadSeekCloser, err error) {
+	spanCtx, span := c.startSpan(ctx, "Open", attrFsID.Int64(fsID))
+	defer func() { endSpan(span, err) }()
+
+	metasResp, err := c.getFileMetas(spanCtx, []int64{fsID})
+	if err != nil {
+		return nil, err
+	}
+	if len(metasResp.List) == 0 {
+		return nil, fmt.Errorf("%w: fs_id %d", ErrNotFound, fsID)
+	}
+	meta := &metasResp.List[0]
+	if meta.Isdir == isdirDir {
+		return nil, fmt.Errorf("%s is a directory", meta.Path)
+	}
+	if meta.Dlink == "" {
+		return nil, fmt.Errorf("dlink not found")
+	}
+	return c.openMeta(ctx, meta), nil
+}
+
+// OpenFileInfo 打开 List / Stat 返回的文件，行为与 Open 相同
+// 打开时不发起请求，dlink 在第一次读取时才获取，适合只需要元数据或可能不读取内容的场景
+func (c *Client) OpenFileInfo(ctx context.Context, info *FileInfo) (io.ReadSeekCloser, error) {
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", info.Path)
+	}
+	return c.openMeta(ctx, &FileMeta{FsId: info.FsId, Path: info.Path, Size: info.Size}), nil
+}
+
+// openMeta 基于 meta 创建读取器，meta.Dlink 为空时在第一次读取时获取
+func (c *Client) openMeta(ctx context.Context, meta *FileMeta) *remoteFile {
+	progress := progressFromContext(ctx)
+	progress.start(PhaseDownloading, meta.Path, meta.Size)
+	return &remoteFile{
+		untrack:   c.trackTransfer(DirectionDownload),
+		ctx:       ctx,
+		progress:  progress,
+		client:    c,
+		src:       c.newDlinkSource(meta),
+		size:      meta.Size,
+		readahead: defaultReadahead,
+	}
+}
+
+// remoteFile 基于 dlink Range 请求的 io.ReadSeekCloser 实现
+type remoteFile struct {
+	ctx       context.Context
+	client    *Client
+	progress  *progressTracker
+	src       *dlinkSource
+	size      int64
+	readahead int
+
+	pos    int64 // 调用方视角的当前读取位置
+	body   *dlinkStream
+	buf    *bufio.Reader
+	bufPos int64 // buf 下一个可读字节对应的文件偏移
+	closed bool
+
+	untrack func() // 结束活跃传输计数
+}
+
+var errFileClosed = errors.New("remote file already closed")
+
+// Read 实现 io.Reader
+func (f *remoteFile) Read(p []byte) (int, error) {
+	if f.closed {
+		return 0, errFileClosed
+	}
+	if f.pos >= f.size {
+		return 0, io.EOF
+	}
+	if err := f.ensureStream(); err != nil {
+		return 0, err
+	}
+	if remain := f.size - f.pos; int64(len(p)) > remain {
+		p = p[:remain]
+	}
+	// 连接中断时 body 已从当前位置续传，这里只会收到续传失败的错误
+	n, err := f.buf.Read(p)
+	f.pos += int64(n)
+	f.progress.add(int64(n))
+	f.bufPos = f.pos
+	if errors.Is(err, io.EOF) && f.pos < f.size {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// Seek 实现 io.Seeker
+func (f *remoteFile) Seek(offset int64, whence int) (int64, error) {
+	if f.closed {
+		return 0, errFileClosed
+	}
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = f.pos + offset
+	case io.SeekEnd:
+		abs = f.size + offset
+	default:
+		return 0, fmt.Errorf("invalid whence: %d", whence)
+	}
+	if abs < 0 {
+		return 0, fmt.Errorf("negative position: %d", abs)
+	}
+	f.pos = abs
+	return abs, nil
+}
+
+// Close 实现 io.Closer
+func (f *remoteFile) Close() error {
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+	f.untrack()
+	return f.dropStream()
+}
+
+// ensureStream 保证存在一个从 f.pos 开始读取的流
+// 目标位置位于当前流前方且距离不超过预读窗口时直接跳过中间数据，否则重新发起 Range 请求
+func (f *remoteFile) ensureStream() error {
+	if f.buf != nil {
+		if f.pos == f.bufPos {
+			return nil
+		}
+		if skip := f.pos - f.bufPos; skip > 0 && skip <= int64(f.readahead) {
+			if _, err := f.buf.Discard(int(skip)); err == nil {
+				f.bufPos = f.pos
+				return nil
+			}
+		}
+		f.dropStream()
+	}
+
+	body := newDlinkStream(f.ctx, f.src, f.pos, f.size)
+	if err := body.open(); err != nil {
+		return err
+	}
+	f.body = body
+	f.buf = bufio.NewReaderSize(body, f.readahead)
+	f.bufPos = f.pos
+	return nil
+}
+
+// dropStream 关闭当前连接
+func (f *remoteFile) dropStream() error {
+	if f.body == nil {
+		return nil
+	}
+	err := f.body.Close()
+	f.body = nil
+	f.buf = nil
+	return err
+}
+
+// dlinkStream 从 offset 处开始顺序读取 dlink 的内容
+// 连接提前断开或读取出错时，从已读到的位置重新发起 Range 请求继续读取；
+// 续传请求连续 maxStreamResumes 次没有读到数据时返回错误
+type dlinkStream struct {
+	ctx    context.Context
+	src    *dlinkSource
+	offset int64 // 下一个要读取的字节
+	size   int64 // 内容总长度，未知时为 -1，第一次请求后按响应长度确定
+
+	body    io.ReadCloser
+	resumes int
+}
+
+// newDlinkStream 创建从 offset 处开始读取的 dlinkStream，第一次读取时才发起请求
+func newDlinkStream(ctx context.Context, src *dlinkSource, offset int64, size int64) *dlinkStream {
+	return &dlinkStream{ctx: ctx, src: src, offset: offset, size: size}
+}
+
+// open 从当前位置发起请求，已有连接时不做任何事
+func (s *dlinkStream) open() error {
+	if s.body != nil {
+		return nil
+	}
+	resp, err := s.src.open(s.ctx, s.offset)
+	if err != nil {
+		return err
+	}
+	s.body = resp.Body
+	if s.size < 0 && resp.ContentLength >= 0 {
+		s.size = s.offset + resp.ContentLength
+	}
+	return nil
+}
+
+// Read 实现 io.Reader
+func (s *dlinkStream) Read(p []byte) (int, error) {
+	for {
+		if s.size >= 0 && s.offset >= s.size {
+			s.Close()
+			return 0, io.EOF
+		}
+		if err := s.open(); err != nil {
+			if s.resumes == 0 || !s.resume(err) {
+				return 0, err
+			}
+			continue
+		}
+		n, err := s.body.Read(p)
+		s.offset += int64(n)
+		if n > 0 {
+			s.resumes = 0
+		}
+		if err == nil || (errors.Is(err, io.EOF) && (s.size < 0 || s.offset >= s.size)) {
+			return n, err
+		}
+		s.Close()
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		if !s.resume(err) {
+			return n, err
+		}
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+// resume 判断读取错误后能否续传，可以时等待后返回 true，下次读取从 s.offset 重新发起请求
+func (s *dlinkStream) resume(err error) bool {
+	if s.ctx.Err() != nil || s.resumes >= maxStreamResumes {
+		return false
+	}
+	s.resumes++
+	client := s.src.client
+	client.logger.Warn("下载连接中断，从当前位置续传", logKeyOp, "download", logKeyPath, s.src.path, "offset", s.offset, "attempt", s.resumes, logKeyError, err)
+	client.metrics.IncRetry("download")
+	return sleepContext(s.ctx, time.Duration(s.resumes)*streamResumeDelay) == nil
+}
+
+// Close 关闭当前连接
+func (s *dlinkStream) Close() error {
+	if s.body == nil {
+		return nil
+	}
+	err := s.body.Close()
+	s.body = nil
+	return err
+}
@@ -0,0 +1,100 @@
+package baidupanplus
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/S-zhi/baidupansdk/baidupantest"
+)
+
+func TestDownloadResumesAfterDroppedConnection(t *testing.T) {
+	data := testData(3<<20 + 17)
+	tests := []struct {
+		name string
+		read func(c *Client) ([]byte, error)
+	}{
+		{"DownloadTo", func(c *Client) ([]byte, error) {
+			var buf bytes.Buffer
+			_, err := c.DownloadTo(context.Background(), "/apps/test/a.bin", &buf)
+			return buf.Bytes(), err
+		}},
+		{"Open", func(c *Client) ([]byte, error) {
+			f, err := c.Open(context.Background(), "/apps/test/a.bin")
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+			return io.ReadAll(f)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, srv := newTestClient(t)
+			srv.AddFile("/apps/test/a.bin", data)
+			// 前两次请求各输出 1MB 后断开，之后的续传请求正常完成
+			srv.InjectFault(baidupantest.OpDownload, baidupantest.Fault{DropAfter: 1 << 20, Times: 2})
+
+			got, err := tt.read(c)
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Fatalf("content differs: got %d bytes, want %d", len(got), len(data))
+			}
+			if n := srv.Requests(baidupantest.OpDownload); n != 3 {
+				t.Errorf("download requests = %d, want 3", n)
+			}
+		})
+	}
+}
+
+func TestDownloadGivesUpAfterRepeatedFailures(t *testing.T) {
+	c, srv := newTestClient(t)
+	srv.AddFile("/apps/test/a.bin", testData(2<<20))
+	srv.InjectFault(baidupantest.OpDownload, baidupantest.Fault{DropAfter: 1 << 20, Times: 1})
+	srv.InjectFault(baidupantest.OpDownload, baidupantest.Fault{DropConnection: true})
+
+	n, err := c.DownloadTo(context.Background(), "/apps/test/a.bin", io.Discard)
+	if err == nil {
+		t.Fatal("download succeeded although every resume failed")
+	}
+	if n != 1<<20 {
+		t.Errorf("written = %d, want %d", n, 1<<20)
+	}
+	if got, want := srv.Requests(baidupantest.OpDownload), 1+maxStreamResumes; got != want {
+		t.Errorf("download requests = %d, want %d", got, want)
+	}
+}
+
+func TestOpenSeek(t *testing.T) {
+	c, srv := newTestClient(t)
+	data := testData(1 << 20)
+	srv.AddFile("/apps/test/a.bin", data)
+	f, err := c.Open(context.Background(), "/apps/test/a.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	for _, off := range []int64{1000, 10, 900000} {
+		if _, err := f.Seek(off, io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, 100)
+		if _, err := io.ReadFull(f, buf); err != nil {
+			t.Fatalf("read at %d: %v", off, err)
+		}
+		if !bytes.Equal(buf, data[off:off+100]) {
+			t.Errorf("content at %d differs", off)
+		}
+	}
+	if _, err := f.Seek(-5, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	rest, err := io.ReadAll(f)
+	if err != nil || !bytes.Equal(rest, data[len(data)-5:]) {
+		t.Errorf("tail = %v, %v", rest, err)
+	}
+}
@@ -0,0 +1,91 @@
+package baidupanplus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+)
+
+// ErrNotFound 远程文件或目录不存在
+var ErrNotFound = errors.New("file not found")
+
+// ErrnoError 接口返回了非 0 的 errno
+type ErrnoError struct {
+	Op    string // 出错的操作，如 "precreate"、"get file list"
+	Errno int
+}
+
+// Error 实现 error 接口
+func (e *ErrnoError) Error() string {
+	return fmt.Sprintf("%s failed with errno: %d", e.Op, e.Errno)
+}
+
+// newErrnoError 创建 ErrnoError
+func newErrnoError(op string, errno int32) error {
+	return &ErrnoError{Op: op, Errno: int(errno)}
+}
+
+// ErrorCategory errno 的分类，便于调用方按类别处理错误
+type ErrorCategory string
+
+const (
+	CategoryNone        ErrorCategory = ""             // 没有错误
+	CategoryAuth        ErrorCategory = "auth"         // 令牌无效或过期
+	CategoryPermission  ErrorCategory = "permission"   // 无访问权限
+	CategoryNotFound    ErrorCategory = "not_found"    // 文件或目录不存在
+	CategoryExists      ErrorCategory = "exists"       // 文件或目录已存在
+	CategoryInvalid     ErrorCategory = "invalid"      // 参数错误
+	CategoryQuota       ErrorCategory = "quota"        // 空间不足
+	CategoryRateLimited ErrorCategory = "rate_limited" // 命中接口频控
+	CategoryCanceled    ErrorCategory = "canceled"     // 调用方取消或超时
+	CategoryUnknown     ErrorCategory = "unknown"      // 其它错误
+)
+
+// errnoCategories 常见 errno 与分类的对应关系
+// 参考百度网盘开放平台错误码文档
+var errnoCategories = map[int]ErrorCategory{
+	-6:    CategoryAuth,
+	111:   CategoryAuth,
+	-7:    CategoryInvalid,
+	2:     CategoryInvalid,
+	31023: CategoryInvalid,
+	-8:    CategoryExists,
+	-9:    CategoryNotFound,
+	31066: CategoryNotFound,
+	31190: CategoryNotFound,
+	-10:   CategoryQuota,
+	31112: CategoryQuota,
+	31024: CategoryPermission,
+	-5:    CategoryPermission,
+	31034: CategoryRateLimited,
+}
+
+// CategoryOf 返回错误的分类
+func CategoryOf(err error) ErrorCategory {
+	if err == nil {
+		return CategoryNone
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return CategoryCanceled
+	}
+	if errors.Is(err, ErrNotFound) || errors.Is(err, ErrNoThumbnail) || errors.Is(err, ErrJobNotFound) {
+		return CategoryNotFound
+	}
+	if errors.Is(err, ErrInsufficientQuota) {
+		return CategoryQuota
+	}
+	if errors.Is(err, ErrFileTooLarge) {
+		return CategoryInvalid
+	}
+	if errors.Is(err, fs.ErrExist) {
+		return CategoryExists
+	}
+	var errnoErr *ErrnoError
+	if errors.As(err, &errnoErr) {
+		if category, ok := errnoCategories[errnoErr.Errno]; ok {
+			return category
+		}
+	}
+	return CategoryUnknown
+}
@@ -0,0 +1,228 @@
+package baidupanplus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"iter"
+)
+
+// FileCategory 文件分类，对应 FileInfo.Category
+type FileCategory int32
+
+const (
+	FileCategoryVideo    FileCategory = 1 // 视频
+	FileCategoryAudio    FileCategory = 2 // 音频
+	FileCategoryImage    FileCategory = 3 // 图片
+	FileCategoryDocument FileCategory = 4 // 文档
+	FileCategoryApp      FileCategory = 5 // 应用
+	FileCategoryOther    FileCategory = 6 // 其他
+	FileCategoryTorrent  FileCategory = 7 // 种子
+)
+
+// ListOrder 列表排序字段
+type ListOrder string
+
+const (
+	OrderByName ListOrder = "name" // 按文件名
+	OrderByTime ListOrder = "time" // 按修改时间
+	OrderBySize ListOrder = "size" // 按大小
+)
+
+// categoryPageSize 分类列表接口单页条数上限
+const categoryPageSize = 1000
+
+// CategoryListOptions ListDocuments / ListImages / ListAll 的选项
+type CategoryListOptions struct {
+	Dir       string    // 起始目录，默认 /
+	Recursive bool      // 是否包含子目录
+	Order     ListOrder // 排序字段，默认由服务端决定
+	Desc      bool      // 是否降序
+	PageSize  int       // 单页条数，默认且最大 1000
+
+	// Categories 仅对 ListAll 生效：只返回这些分类的文件，为空时返回全部文件与目录
+	Categories []FileCategory
+}
+
+func (o CategoryListOptions) dir() string {
+	if o.Dir == "" {
+		return "/"
+	}
+	return o.Dir
+}
+
+func (o CategoryListOptions) pageSize() int {
+	if o.PageSize <= 0 || o.PageSize > categoryPageSize {
+		return categoryPageSize
+	}
+	return o.PageSize
+}
+
+func (o CategoryListOptions) desc() string {
+	if o.Desc {
+		return "1"
+	}
+	return "0"
+}
+
+func (o CategoryListOptions) recursion() string {
+	if o.Recursive {
+		return "1"
+	}
+	return "0"
+}
+
+// categoryListResponse doclist / imagelist 接口响应
+type categoryListResponse struct {
+	Errno int32      `json:"errno"`
+	Info  []FileInfo `json:"info"`
+}
+
+// listAllResponse listall 接口响应
+type listAllResponse struct {
+	Errno   int32      `json:"errno"`
+	List    []FileInfo `json:"list"`
+	Cursor  int        `json:"cursor"`
+	HasMore int32      `json:"has_more"`
+}
+
+// ListDocuments 按页遍历 opts.Dir 下的文档，由服务端按分类筛选，无需遍历整个目录树
+// 出错时产生一次非 nil 的 error 后结束遍历
+func (c *Client) ListDocuments(ctx context.Context, opts CategoryListOptions) iter.Seq2[FileInfo, error] {
+	return c.categoryPages(ctx, "doclist", opts, func(page, num int) (string, error) {
+		req := c.api.FileinfoApi.Xpanfiledoclist(ctx).
+			AccessToken(c.cfg.AccessToken).
+			ParentPath(opts.dir()).
+			Recursion(opts.recursion()).
+			Page(int32(page)).
+			Num(int32(num)).
+			Desc(opts.desc()).
+			Web("1")
+		if opts.Order != "" {
+			req = req.Order(string(opts.Order))
+		}
+		jsonStr, _, err := c.api.FileinfoApi.XpanfiledoclistExecute(req)
+		return jsonStr, err
+	})
+}
+
+// ListImages 按页遍历 opts.Dir 下的图片，返回的 FileInfo 带有缩略图地址
+// 出错时产生一次非 nil 的 error 后结束遍历
+func (c *Client) ListImages(ctx context.Context, opts CategoryListOptions) iter.Seq2[FileInfo, error] {
+	return c.categoryPages(ctx, "imagelist", opts, func(page, num int) (string, error) {
+		req := c.api.FileinfoApi.Xpanfileimagelist(ctx).
+			AccessToken(c.cfg.AccessToken).
+			ParentPath(opts.dir()).
+			Recursion(opts.recursion()).
+			Page(int32(page)).
+			Num(int32(num)).
+			Desc(opts.desc()).
+			Web("1")
+		if opts.Order != "" {
+			req = req.Order(string(opts.Order))
+		}
+		jsonStr, _, err := c.api.FileinfoApi.XpanfileimagelistExecute(req)
+		return jsonStr, err
+	})
+}
+
+// categoryPages 从第 1 页开始请求 fetch，直到某页不足 num 条
+func (c *Client) categoryPages(ctx context.Context, op string, opts CategoryListOptions, fetch func(page, num int) (string, error)) iter.Seq2[FileInfo, error] {
+	return func(yield func(FileInfo, error) bool) {
+		num := opts.pageSize()
+		for page := 1; ; page++ {
+			if err := ctx.Err(); err != nil {
+				yield(FileInfo{}, err)
+				return
+			}
+			jsonStr, err := fetch(page, num)
+			if err != nil {
+				c.logger.Error("failed to execute "+op, logKeyOp, op, logKeyPath, opts.dir(), logKeyError, err)
+				yield(FileInfo{}, fmt.Errorf("execute %s api failed: %w", op, err))
+				return
+			}
+			var resp categoryListResponse
+			if err := json.Unmarshal([]byte(jsonStr), &resp); err != nil {
+				yield(FileInfo{}, fmt.Errorf("unmarshal %s response failed: %w", op, err))
+				return
+			}
+			if resp.Errno != 0 {
+				yield(FileInfo{}, newErrnoError(op, resp.Errno))
+				return
+			}
+			for _, info := range resp.Info {
+				if !yield(info, nil) {
+					return
+				}
+			}
+			if len(resp.Info) < num {
+				return
+			}
+		}
+	}
+}
+
+// ListAll 通过 listall 接口按页遍历 opts.Dir，opts.Categories 非空时只返回这些分类的文件
+// listall 接口本身不支持按分类筛选，筛选在客户端进行；文档与图片优先使用 ListDocuments / ListImages
+// 出错时产生一次非 nil 的 error 后结束遍历
+func (c *Client) ListAll(ctx context.Context, opts CategoryListOptions) iter.Seq2[FileInfo, error] {
+	wanted := make(map[FileCategory]bool, len(opts.Categories))
+	for _, category := range opts.Categories {
+		wanted[category] = true
+	}
+	return func(yield func(FileInfo, error) bool) {
+		num := opts.pageSize()
+		recursion := int32(0)
+		if opts.Recursive {
+			recursion = 1
+		}
+		desc := int32(0)
+		if opts.Desc {
+			desc = 1
+		}
+		for start := 0; ; {
+			if err := ctx.Err(); err != nil {
+				yield(FileInfo{}, err)
+				return
+			}
+			req := c.api.MultimediafileApi.Xpanfilelistall(ctx).
+				AccessToken(c.cfg.AccessToken).
+				Path(opts.dir()).
+				Recursion(recursion).
+				Start(int32(start)).
+				Limit(int32(num)).
+				Desc(desc).
+				Web("1")
+			if opts.Order != "" {
+				req = req.Order(string(opts.Order))
+			}
+			jsonStr, _, err := c.api.MultimediafileApi.XpanfilelistallExecute(req)
+			if err != nil {
+				c.logger.Error("failed to execute listall", logKeyOp, "listall", logKeyPath, opts.dir(), logKeyError, err)
+				yield(FileInfo{}, fmt.Errorf("execute listall api failed: %w", err))
+				return
+			}
+			var resp listAllResponse
+			if err := json.Unmarshal([]byte(jsonStr), &resp); err != nil {
+				yield(FileInfo{}, fmt.Errorf("unmarshal listall response failed: %w", err))
+				return
+			}
+			if resp.Errno != 0 {
+				yield(FileInfo{}, newErrnoError("listall", resp.Errno))
+				return
+			}
+			for _, info := range resp.List {
+				if len(wanted) > 0 && (info.IsDir() || !wanted[FileCategory(info.Category)]) {
+					continue
+				}
+				if !yield(info, nil) {
+					return
+				}
+			}
+			if resp.HasMore == 0 || resp.Cursor <= start {
+				return
+			}
+			start = resp.Cursor
+		}
+	}
+}
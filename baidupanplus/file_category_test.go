@@ -0,0 +1,141 @@
+package baidupanplus
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"slices"
+	"testing"
+
+	"github.com/S-zhi/baidupansdk/baidupantest"
+)
+
+// collectPaths 收集遍历结果的路径，遍历出错时终止测试
+func collectPaths(t *testing.T, seq iter.Seq2[FileInfo, error]) []string {
+	t.Helper()
+	var paths []string
+	for info, err := range seq {
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, info.Path)
+	}
+	return paths
+}
+
+// addCategoryFiles 写入各分类的文件
+func addCategoryFiles(srv *baidupantest.Server) {
+	for _, p := range []string{
+		"/apps/test/a.txt", "/apps/test/b.PDF", "/apps/test/c.docx",
+		"/apps/test/d.jpg", "/apps/test/e.png",
+		"/apps/test/f.mp4", "/apps/test/g.mp3", "/apps/test/h.bin",
+		"/apps/test/sub/i.xlsx", "/apps/test/sub/j.gif",
+	} {
+		srv.AddFile(p, []byte(p))
+	}
+	srv.AddDir("/apps/test/empty.txt")
+}
+
+func TestListDocuments(t *testing.T) {
+	c, srv := newTestClient(t)
+	addCategoryFiles(srv)
+	ctx := context.Background()
+
+	got := collectPaths(t, c.ListDocuments(ctx, CategoryListOptions{Dir: "/apps/test"}))
+	want := []string{"/apps/test/a.txt", "/apps/test/b.PDF", "/apps/test/c.docx"}
+	if !slices.Equal(got, want) {
+		t.Errorf("ListDocuments = %v, want %v", got, want)
+	}
+
+	// 递归、降序，每页 2 条时 4 个文档需要请求 3 页
+	got = collectPaths(t, c.ListDocuments(ctx, CategoryListOptions{Dir: "/apps/test", Recursive: true, Order: OrderByName, Desc: true, PageSize: 2}))
+	want = []string{"/apps/test/sub/i.xlsx", "/apps/test/c.docx", "/apps/test/b.PDF", "/apps/test/a.txt"}
+	if !slices.Equal(got, want) {
+		t.Errorf("recursive ListDocuments = %v, want %v", got, want)
+	}
+	if n := srv.Requests(baidupantest.OpDocList); n != 4 {
+		t.Errorf("doclist requests = %d, want 4", n)
+	}
+
+	// 提前结束遍历时不再请求后续页
+	for range c.ListDocuments(ctx, CategoryListOptions{Dir: "/apps/test", PageSize: 1}) {
+		break
+	}
+	if n := srv.Requests(baidupantest.OpDocList); n != 5 {
+		t.Errorf("doclist requests after break = %d, want 5", n)
+	}
+}
+
+func TestListImages(t *testing.T) {
+	c, srv := newTestClient(t)
+	addCategoryFiles(srv)
+
+	got := collectPaths(t, c.ListImages(context.Background(), CategoryListOptions{Dir: "/apps/test", Recursive: true}))
+	want := []string{"/apps/test/d.jpg", "/apps/test/e.png", "/apps/test/sub/j.gif"}
+	if !slices.Equal(got, want) {
+		t.Errorf("ListImages = %v, want %v", got, want)
+	}
+}
+
+func TestListAllCategories(t *testing.T) {
+	c, srv := newTestClient(t)
+	addCategoryFiles(srv)
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		opts CategoryListOptions
+		want []string
+	}{
+		{"all entries", CategoryListOptions{Dir: "/apps/test", PageSize: 3}, []string{
+			"/apps/test/a.txt", "/apps/test/b.PDF", "/apps/test/c.docx", "/apps/test/d.jpg", "/apps/test/e.png",
+			"/apps/test/empty.txt", "/apps/test/f.mp4", "/apps/test/g.mp3", "/apps/test/h.bin", "/apps/test/sub",
+		}},
+		{"media", CategoryListOptions{Dir: "/apps/test", Recursive: true, PageSize: 3, Categories: []FileCategory{FileCategoryVideo, FileCategoryAudio}}, []string{
+			"/apps/test/f.mp4", "/apps/test/g.mp3",
+		}},
+		{"other excludes directories", CategoryListOptions{Dir: "/apps/test", Recursive: true, Categories: []FileCategory{FileCategoryOther}}, []string{
+			"/apps/test/h.bin",
+		}},
+		{"documents", CategoryListOptions{Dir: "/apps/test", Recursive: true, Categories: []FileCategory{FileCategoryDocument}}, []string{
+			"/apps/test/a.txt", "/apps/test/b.PDF", "/apps/test/c.docx", "/apps/test/sub/i.xlsx",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := collectPaths(t, c.ListAll(ctx, tt.opts))
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ListAll = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	for info, err := range c.ListAll(ctx, CategoryListOptions{Dir: "/apps/test", Categories: []FileCategory{FileCategoryImage}}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if FileCategory(info.Category) != FileCategoryImage {
+			t.Errorf("%s has category %d", info.Path, info.Category)
+		}
+	}
+}
+
+func TestCategoryListErrors(t *testing.T) {
+	c, _ := newTestClient(t)
+	opts := CategoryListOptions{Dir: "/apps/missing"}
+	for name, seq := range map[string]iter.Seq2[FileInfo, error]{
+		"ListDocuments": c.ListDocuments(context.Background(), opts),
+		"ListImages":    c.ListImages(context.Background(), opts),
+		"ListAll":       c.ListAll(context.Background(), opts),
+	} {
+		var errs []error
+		for _, err := range seq {
+			errs = append(errs, err)
+		}
+		var errnoErr *ErrnoError
+		if len(errs) != 1 || !errors.As(errs[0], &errnoErr) || CategoryOf(errs[0]) != CategoryNotFound {
+			t.Errorf("%s yielded %v, want a single not-found error", name, errs)
+		}
+	}
+}
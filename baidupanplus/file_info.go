@@ -0,0 +1,165 @@
+package baidupanplus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"time"
+)
+
+// listPageSize 列表接口单页条数上限
+const listPageSize = 1000
+
+// FileInfo 网盘文件或目录信息
+type FileInfo struct {
+	FsId           int64   `json:"fs_id"`
+	Path           string  `json:"path"`
+	ServerFilename string  `json:"server_filename"`
+	Size           int64   `json:"size"`
+	Isdir          int32   `json:"isdir"`    // 1 表示目录，0 表示文件
+	Md5            string  `json:"md5"`      // 云端哈希，开放平台文档注明不是文件内容的真实 MD5；目录为空
+	Category       int32   `json:"category"` // 文件分类：1 视频、2 音频、3 图片、4 文档、5 应用、6 其他、7 种子
+	ServerCtime    int64   `json:"server_ctime"`
+	ServerMtime    int64   `json:"server_mtime"`
+	LocalCtime     int64   `json:"local_ctime"`
+	LocalMtime     int64   `json:"local_mtime"`
+	Thumbs         *Thumbs `json:"thumbs,omitempty"` // 缩略图地址，仅图片等分类列表返回
+}
+
+// IsDir 是否为目录
+func (f FileInfo) IsDir() bool {
+	return f.Isdir == 1
+}
+
+// ModTime 服务端修改时间
+func (f FileInfo) ModTime() time.Time {
+	return time.Unix(f.ServerMtime, 0)
+}
+
+// fileInfoListResponse list / search 接口响应
+type fileInfoListResponse struct {
+	Errno   int32      `json:"errno"`
+	List    []FileInfo `json:"list"`
+	HasMore int32      `json:"has_more"`
+}
+
+// List 列出目录 dir 下的全部文件与子目录（自动分页）
+func (c *Client) List(ctx context.Context, dir string) (_ []FileInfo, err error) {
+	ctx, span := c.startSpan(ctx, "List", attrRemotePath.String(dir))
+	defer func() { endSpan(span, err) }()
+
+	var all []FileInfo
+	for start := 0; ; start += listPageSize {
+		apiReq := c.api.FileinfoApi.Xpanfilelist(ctx).
+			AccessToken(c.cfg.AccessToken).
+			Dir(dir).
+			Start(strconv.Itoa(start)).
+			Limit(listPageSize)
+
+		jsonStr, _, err := c.api.FileinfoApi.XpanfilelistExecute(apiReq)
+		if err != nil {
+			return nil, fmt.Errorf("execute list api failed: %w", err)
+		}
+
+		var resp fileInfoListResponse
+		if err := json.Unmarshal([]byte(jsonStr), &resp); err != nil {
+			return nil, fmt.Errorf("unmarshal list response failed: %w", err)
+		}
+		if resp.Errno != 0 {
+			return nil, newErrnoError("get file list", resp.Errno)
+		}
+
+		all = append(all, resp.List...)
+		if len(resp.List) < listPageSize {
+			return all, nil
+		}
+	}
+}
+
+// Walk 递归遍历 dir，对每个文件与子目录调用 fn（不包含 dir 本身）
+// fn 返回错误时停止遍历并返回该错误
+func (c *Client) Walk(ctx context.Context, dir string, fn func(info FileInfo) error) error {
+	entries, err := c.List(ctx, dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := fn(entry); err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if err := c.Walk(ctx, entry.Path, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Stat 获取远程路径的文件或目录信息，不存在时返回的错误满足 errors.Is(err, ErrNotFound)
+func (c *Client) Stat(ctx context.Context, remotePath string) (_ *FileInfo, err error) {
+	ctx, span := c.startSpan(ctx, "Stat", attrRemotePath.String(remotePath))
+	defer func() { endSpan(span, err) }()
+
+	remotePath = path.Clean("/" + remotePath)
+	if remotePath == "/" {
+		return &FileInfo{Path: "/", ServerFilename: "/", Isdir: 1}, nil
+	}
+
+	entries, err := c.List(ctx, path.Dir(remotePath))
+	if err != nil {
+		if CategoryOf(err) == CategoryNotFound {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, remotePath)
+		}
+		return nil, err
+	}
+	name := path.Base(remotePath)
+	for i := range entries {
+		if entries[i].ServerFilename == name {
+			return &entries[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNotFound, remotePath)
+}
+
+// Search 在目录 dir 下按关键字搜索文件，recursive 为 true 时包含子目录
+func (c *Client) Search(ctx context.Context, key string, dir string, recursive bool) (_ []FileInfo, err error) {
+	ctx, span := c.startSpan(ctx, "Search", attrRemotePath.String(dir))
+	defer func() { endSpan(span, err) }()
+
+	recursion := "0"
+	if recursive {
+		recursion = "1"
+	}
+
+	var all []FileInfo
+	for page := 1; ; page++ {
+		apiReq := c.api.FileinfoApi.Xpanfilesearch(ctx).
+			AccessToken(c.cfg.AccessToken).
+			Key(key).
+			Dir(dir).
+			Recursion(recursion).
+			Page(strconv.Itoa(page)).
+			Num(strconv.Itoa(listPageSize))
+
+		jsonStr, _, err := c.api.FileinfoApi.XpanfilesearchExecute(apiReq)
+		if err != nil {
+			return nil, fmt.Errorf("execute search api failed: %w", err)
+		}
+
+		var resp fileInfoListResponse
+		if err := json.Unmarshal([]byte(jsonStr), &resp); err != nil {
+			return nil, fmt.Errorf("unmarshal search response failed: %w", err)
+		}
+		if resp.Errno != 0 {
+			return nil, newErrnoError("search", resp.Errno)
+		}
+
+		all = append(all, resp.List...)
+		if resp.HasMore == 0 || len(resp.List) == 0 {
+			return all, nil
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package baidupanplus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// OnDup 目标已存在时文件管理操作的处理策略
+type OnDup string
+
+const (
+	OnDupFail      OnDup = "fail"      // 直接返回失败
+	OnDupNewCopy   OnDup = "newcopy"   // 重命名后保存
+	OnDupOverwrite OnDup = "overwrite" // 覆盖
+	OnDupSkip      OnDup = "skip"      // 跳过
+)
+
+// fileManagerSync 文件管理操作的 async 参数：同步执行
+const fileManagerSync = 0
+
+// fileManagerResponse 文件管理接口响应
+type fileManagerResponse struct {
+	Errno int32 `json:"errno"`
+	Info  []struct {
+		Errno int32  `json:"errno"`
+		Path  string `json:"path"`
+	} `json:"info"`
+}
+
+// moveItem copy / move 的 filelist 元素
+type moveItem struct {
+	Path    string `json:"path"`
+	Dest    string `json:"dest"`
+	Newname string `json:"newname"`
+	Ondup   OnDup  `json:"ondup,omitempty"`
+}
+
+// renameItem rename 的 filelist 元素
+type renameItem struct {
+	Path    string `json:"path"`
+	Newname string `json:"newname"`
+}
+
+// Copy 复制 src 到目录 destDir 下，命名为 newName
+func (c *Client) Copy(ctx context.Context, src string, destDir string, newName string, ondup OnDup) (err error) {
+	ctx, span := c.startSpan(ctx, "Copy", attrRemotePath.String(src))
+	defer func() { endSpan(span, err) }()
+
+	filelist, _ := json.Marshal([]moveItem{{Path: src, Dest: destDir, Newname: newName, Ondup: ondup}})
+	req := c.api.FilemanagerApi.Filemanagercopy(ctx).
+		AccessToken(c.cfg.AccessToken).
+		Async(fileManagerSync).
+		Filelist(string(filelist))
+	if ondup != "" {
+		req = req.Ondup(string(ondup))
+	}
+	resp, err := c.api.FilemanagerApi.FilemanagercopyExecute(req)
+	return c.checkFileManagerResponse("copy", resp, err)
+}
+
+// Move 移动 src 到目录 destDir 下，命名为 newName
+func (c *Client) Move(ctx context.Context, src string, destDir string, newName string, ondup OnDup) (err error) {
+	ctx, span := c.startSpan(ctx, "Move", attrRemotePath.String(src))
+	defer func() { endSpan(span, err) }()
+
+	filelist, _ := json.Marshal([]moveItem{{Path: src, Dest: destDir, Newname: newName, Ondup: ondup}})
+	req := c.api.FilemanagerApi.Filemanagermove(ctx).
+		AccessToken(c.cfg.AccessToken).
+		Async(fileManagerSync).
+		Filelist(string(filelist))
+	if ondup != "" {
+		req = req.Ondup(string(ondup))
+	}
+	resp, err := c.api.FilemanagerApi.FilemanagermoveExecute(req)
+	return c.checkFileManagerResponse("move", resp, err)
+}
+
+// Rename 将 remotePath 重命名为同目录下的 newName
+func (c *Client) Rename(ctx context.Context, remotePath string, newName string) (err error) {
+	ctx, span := c.startSpan(ctx, "Rename", attrRemotePath.String(remotePath))
+	defer func() { endSpan(span, err) }()
+
+	filelist, _ := json.Marshal([]renameItem{{Path: remotePath, Newname: newName}})
+	req := c.api.FilemanagerApi.Filemanagerrename(ctx).
+		AccessToken(c.cfg.AccessToken).
+		Async(fileManagerSync).
+		Filelist(string(filelist))
+	resp, err := c.api.FilemanagerApi.FilemanagerrenameExecute(req)
+	return c.checkFileManagerResponse("rename", resp, err)
+}
+
+// Remove 删除一个或多个文件或目录（目录会被递归删除）
+func (c *Client) Remove(ctx context.Context, remotePaths ...string) (err error) {
+	ctx, span := c.startSpan(ctx, "Remove", attrRemotePath.StringSlice(remotePaths))
+	defer func() { endSpan(span, err) }()
+
+	if len(remotePaths) == 0 {
+		return nil
+	}
+	filelist, _ := json.Marshal(remotePaths)
+	req := c.api.FilemanagerApi.Filemanagerdelete(ctx).
+		AccessToken(c.cfg.AccessToken).
+		Async(fileManagerSync).
+		Filelist(string(filelist))
+	resp, err := c.api.FilemanagerApi.FilemanagerdeleteExecute(req)
+	return c.checkFileManagerResponse("delete", resp, err)
+}
+
+// checkFileManagerResponse 解析文件管理接口的响应
+// 整体 errno 非 0 时优先返回具体条目的 errno，便于区分不存在、已存在等情况
+func (c *Client) checkFileManagerResponse(op string, resp *http.Response, err error) error {
+	if err != nil {
+		c.logger.Error("failed to execute filemanager", logKeyOp, op, logKeyError, err)
+		return err
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var fmResp fileManagerResponse
+	if err := json.Unmarshal(body, &fmResp); err != nil {
+		return fmt.Errorf("unmarshal %s response failed: %w", op, err)
+	}
+	if fmResp.Errno == 0 {
+		return nil
+	}
+	for _, item := range fmResp.Info {
+		if item.Errno != 0 {
+			return fmt.Errorf("%s %s: %w", op, item.Path, newErrnoError(op, item.Errno))
+		}
+	}
+	return newErrnoError(op, fmResp.Errno)
+}
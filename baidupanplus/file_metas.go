@@ -0,0 +1,248 @@
+package baidupanplus
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metasBatchSize filemetas 接口单次请求的 fs_id 数量上限
+const metasBatchSize = 100
+
+// dlinkTTL dlink 的有效期
+const dlinkTTL = 8 * time.Hour
+
+// FileMeta 百度网盘文件元数据结构
+type FileMeta struct {
+	FsId        int64  `json:"fs_id"`
+	Path        string `json:"path"`
+	Filename    string `json:"server_filename"`
+	Size        int64  `json:"size"`
+	Isdir       int32  `json:"isdir"`    // 1 表示目录，0 表示文件
+	Md5         string `json:"md5"`      // 云端哈希，不是文件内容的真实 MD5；目录为空
+	Category    int32  `json:"category"` // 文件分类：1 视频、2 音频、3 图片、4 文档、5 应用、6 其他、7 种子
+	ServerCtime int64  `json:"server_ctime"`
+	ServerMtime int64  `json:"server_mtime"`
+	LocalCtime  int64  `json:"local_ctime"`
+	LocalMtime  int64  `json:"local_mtime"`
+	DateTaken   int64  `json:"date_taken,omitempty"` // 图片拍摄时间，需要 Extra
+
+	Dlink string `json:"dlink"`
+	// DlinkFetched 获取 dlink 的时间，DlinkExpires 为按获取时间加有效期估算的过期时间，未获取 dlink 时均为零值
+	DlinkFetched time.Time `json:"-"`
+	DlinkExpires time.Time `json:"-"`
+
+	Thumbs    *Thumbs    `json:"thumbs,omitempty"`     // 缩略图地址，需要 Thumb
+	MediaInfo *MediaInfo `json:"media_info,omitempty"` // 音视频信息，需要 NeedMedia
+}
+
+// IsDir 是否为目录
+func (m FileMeta) IsDir() bool {
+	return m.Isdir == 1
+}
+
+// Thumbs 不同尺寸的缩略图地址
+type Thumbs struct {
+	Icon string `json:"icon"`
+	URL1 string `json:"url1"`
+	URL2 string `json:"url2"`
+	URL3 string `json:"url3"`
+}
+
+// MediaInfo 音视频信息
+// 接口中的数值字段可能是数字或字符串，解析时统一转换，原始字段保留在 Raw 中
+type MediaInfo struct {
+	Duration   int64  // 时长（秒）
+	Width      int64  // 视频宽度
+	Height     int64  // 视频高度
+	Resolution string // 分辨率描述，如 width:1920,height:1080
+	Raw        map[string]json.RawMessage
+}
+
+// UnmarshalJSON 实现 json.Unmarshaler
+func (m *MediaInfo) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &m.Raw); err != nil {
+		return err
+	}
+	m.Duration = lenientInt(m.Raw["duration"])
+	m.Width = lenientInt(m.Raw["width"])
+	m.Height = lenientInt(m.Raw["height"])
+	_ = json.Unmarshal(m.Raw["resolution"], &m.Resolution)
+	return nil
+}
+
+// MarshalJSON 实现 json.Marshaler，输出原始字段
+func (m MediaInfo) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.Raw)
+}
+
+// lenientInt 解析数字或数字字符串，无法解析时返回 0
+func lenientInt(raw json.RawMessage) int64 {
+	s := strings.Trim(string(raw), `"`)
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return int64(f)
+	}
+	return 0
+}
+
+// FileMetasResponse 文件详情响应
+type FileMetasResponse struct {
+	Errno     int32       `json:"errno"`
+	List      []FileMeta  `json:"list"`
+	RequestId interface{} `json:"request_id"`
+}
+
+// FileMetasOptions 查询文件详情的选项
+type FileMetasOptions struct {
+	Dlink     bool // 返回下载链接
+	Thumb     bool // 返回缩略图地址
+	Extra     bool // 返回图片拍摄时间等额外信息
+	NeedMedia bool // 返回音视频信息
+
+	// Concurrency 同时进行的请求数，fs_id 超过单次上限时分批请求，小于等于 1 时逐批串行
+	Concurrency int
+}
+
+// GetFileMetas 获取文件详情（包含 dlink）
+func GetFileMetas(accessToken string, fsids []int64) (*FileMetasResponse, error) {
+	return packageClient(Config{AccessToken: accessToken}).getFileMetas(ctx, fsids)
+}
+
+// getFileMetas 获取文件详情（包含 dlink）
+func (c *Client) getFileMetas(ctx context.Context, fsids []int64) (*FileMetasResponse, error) {
+	metas, err := c.FileMetas(ctx, fsids, FileMetasOptions{Dlink: true})
+	if err != nil {
+		return nil, err
+	}
+	return &FileMetasResponse{List: metas}, nil
+}
+
+// FileMetas 查询 fsids 对应的文件详情
+// fs_id 超过单次请求上限时自动分批，结果按 fsids 的顺序返回，不存在的 fs_id 被跳过
+func (c *Client) FileMetas(ctx context.Context, fsids []int64, opts FileMetasOptions) ([]FileMeta, error) {
+	var batches [][]int64
+	for start := 0; start < len(fsids); start += metasBatchSize {
+		end := min(start+metasBatchSize, len(fsids))
+		batches = append(batches, fsids[start:end])
+	}
+
+	results := make([][]FileMeta, len(batches))
+	err := runConcurrently(ctx, len(batches), opts.Concurrency, func(ctx context.Context, i int) error {
+		metas, err := c.fileMetasBatch(ctx, batches[i], opts)
+		results[i] = metas
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int64]FileMeta, len(fsids))
+	for _, metas := range results {
+		for _, meta := range metas {
+			byID[meta.FsId] = meta
+		}
+	}
+	merged := make([]FileMeta, 0, len(byID))
+	for _, id := range fsids {
+		if meta, ok := byID[id]; ok {
+			merged = append(merged, meta)
+		}
+	}
+	return merged, nil
+}
+
+// fileMetasBatch 请求一批不超过 metasBatchSize 个 fs_id 的文件详情
+func (c *Client) fileMetasBatch(ctx context.Context, fsids []int64, opts FileMetasOptions) ([]FileMeta, error) {
+	fsidsByte, _ := json.Marshal(fsids)
+	req := c.api.MultimediafileApi.Xpanmultimediafilemetas(ctx).
+		AccessToken(c.cfg.AccessToken).
+		Fsids(string(fsidsByte))
+	if opts.Dlink {
+		req = req.Dlink("1")
+	}
+	if opts.Thumb {
+		req = req.Thumb("1")
+	}
+	if opts.Extra {
+		req = req.Extra("1")
+	}
+	if opts.NeedMedia {
+		req = req.Needmedia(1)
+	}
+
+	fetched := time.Now()
+	jsonStr, _, err := c.api.MultimediafileApi.XpanmultimediafilemetasExecute(req)
+	if err != nil {
+		c.logger.Error("failed to execute filemetas", logKeyOp, "filemetas", logKeyError, err)
+		return nil, err
+	}
+
+	var metasResp FileMetasResponse
+	if err := json.Unmarshal([]byte(jsonStr), &metasResp); err != nil {
+		return nil, err
+	}
+	if metasResp.Errno != 0 {
+		c.logger.Error("get file metas failed", logKeyOp, "filemetas", logKeyErrno, metasResp.Errno, logKeyRequestID, metasResp.RequestId)
+		return nil, newErrnoError("get file metas", metasResp.Errno)
+	}
+	for i := range metasResp.List {
+		if metasResp.List[i].Dlink != "" {
+			metasResp.List[i].DlinkFetched = fetched
+			metasResp.List[i].DlinkExpires = fetched.Add(dlinkTTL)
+		}
+	}
+	return metasResp.List, nil
+}
+
+// runConcurrently 以不超过 concurrency 的并发度对 0..n-1 调用 fn
+// 任一调用出错时取消其余调用，返回第一个错误
+func runConcurrently(ctx context.Context, n int, concurrency int, fn func(ctx context.Context, i int) error) error {
+	if concurrency <= 1 || n <= 1 {
+		for i := 0; i < n; i++ {
+			if err := fn(ctx, i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	sem := make(chan struct{}, concurrency)
+	for i := 0; i < n; i++ {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := fn(ctx, i); err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(i)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,72 @@
+package baidupanplus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/S-zhi/baidupansdk/baidupantest"
+)
+
+func TestFileMetasBatching(t *testing.T) {
+	for _, concurrency := range []int{0, 4} {
+		t.Run(fmt.Sprintf("concurrency %d", concurrency), func(t *testing.T) {
+			c, srv := newTestClient(t)
+			// 250 个文件倒序查询，中间夹一个不存在的 fs_id，共 251 个分 3 批
+			var fsids []int64
+			paths := map[int64]string{}
+			for i := 0; i < 250; i++ {
+				p := fmt.Sprintf("/apps/test/f%03d.txt", i)
+				id := srv.AddFile(p, []byte(p))
+				fsids = append([]int64{id}, fsids...)
+				paths[id] = p
+			}
+			fsids = append(fsids[:120], append([]int64{1 << 40}, fsids[120:]...)...)
+
+			metas, err := c.FileMetas(context.Background(), fsids, FileMetasOptions{Dlink: true, Concurrency: concurrency})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n := srv.Requests(baidupantest.OpFileMetas); n != 3 {
+				t.Errorf("filemetas requests = %d, want 3", n)
+			}
+			if len(metas) != 250 {
+				t.Fatalf("got %d metas, want 250", len(metas))
+			}
+			// 结果按 fsids 的顺序返回，跳过不存在的 fs_id
+			i := 0
+			for _, id := range fsids {
+				if _, ok := paths[id]; !ok {
+					continue
+				}
+				m := metas[i]
+				if m.FsId != id || m.Path != paths[id] {
+					t.Fatalf("metas[%d] = %d %s, want %d %s", i, m.FsId, m.Path, id, paths[id])
+				}
+				if m.Dlink == "" || m.DlinkExpires.Sub(m.DlinkFetched) != dlinkTTL {
+					t.Errorf("metas[%d] dlink = %q, expires %v after fetch", i, m.Dlink, m.DlinkExpires.Sub(m.DlinkFetched))
+				}
+				i++
+			}
+		})
+	}
+}
+
+func TestFileMetasBatchError(t *testing.T) {
+	c, srv := newTestClient(t)
+	var fsids []int64
+	for i := 0; i < 150; i++ {
+		fsids = append(fsids, srv.AddFile(fmt.Sprintf("/apps/test/f%03d.txt", i), nil))
+	}
+	srv.InjectFault(baidupantest.OpFileMetas, baidupantest.Fault{Errno: 31066, Times: 1})
+
+	metas, err := c.FileMetas(context.Background(), fsids, FileMetasOptions{Concurrency: 2})
+	var errnoErr *ErrnoError
+	if !errors.As(err, &errnoErr) || errnoErr.Errno != 31066 {
+		t.Fatalf("error = %v, want errno 31066", err)
+	}
+	if metas != nil {
+		t.Errorf("got %d metas from a failed query", len(metas))
+	}
+}
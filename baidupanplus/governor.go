@@ -0,0 +1,413 @@
+package baidupanplus
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// EndpointFamily 接口族，请求限流按接口族分别配置
+type EndpointFamily string
+
+const (
+	FamilyList        EndpointFamily = "list"        // list / listall / search / doclist / imagelist
+	FamilyMetas       EndpointFamily = "metas"       // filemetas
+	FamilyFilemanager EndpointFamily = "filemanager" // copy / move / rename / delete
+	FamilyUpload      EndpointFamily = "upload"      // precreate / superfile2 / create
+	FamilyUser        EndpointFamily = "user"        // quota / uinfo
+	FamilyAuth        EndpointFamily = "auth"        // oauth
+	FamilyOther       EndpointFamily = "other"       // 其它接口
+)
+
+// errnoRateLimited 百度网盘接口频控错误码
+const errnoRateLimited = 31034
+
+// RequestLimit 单个接口族的请求限流配置
+type RequestLimit struct {
+	RequestsPerSecond float64 `json:"requests_per_second"` // 每秒请求数上限，<= 0 表示不限
+	MaxInFlight       int     `json:"max_in_flight"`       // 同时进行的请求数上限，<= 0 表示不限
+}
+
+// defaultRequestLimits 未在 Config.RequestLimits 中配置的接口族使用的默认限流
+var defaultRequestLimits = map[EndpointFamily]RequestLimit{
+	FamilyList:        {RequestsPerSecond: 5, MaxInFlight: 4},
+	FamilyMetas:       {RequestsPerSecond: 5, MaxInFlight: 4},
+	FamilyFilemanager: {RequestsPerSecond: 2, MaxInFlight: 2},
+	FamilyUpload:      {RequestsPerSecond: 10, MaxInFlight: 8},
+	FamilyUser:        {RequestsPerSecond: 2, MaxInFlight: 2},
+	FamilyAuth:        {RequestsPerSecond: 1, MaxInFlight: 1},
+	FamilyOther:       {RequestsPerSecond: 5, MaxInFlight: 4},
+}
+
+const (
+	// adaptiveBackoffFactor 命中频控后速率的缩减比例
+	adaptiveBackoffFactor = 0.5
+	// adaptiveMinRatio 自适应降速的下限（相对于配置速率）
+	adaptiveMinRatio = 0.1
+	// adaptiveRecoverStep 每次成功请求恢复的速率（相对于配置速率）
+	adaptiveRecoverStep = 0.05
+	// adaptiveRecoverDelay 命中频控后多久开始恢复速率
+	adaptiveRecoverDelay = 10 * time.Second
+)
+
+// familyOf 根据请求 URL 判断接口族
+func familyOf(u *url.URL) EndpointFamily {
+	method := u.Query().Get("method")
+	switch {
+	case strings.HasPrefix(u.Path, "/oauth/"):
+		return FamilyAuth
+	case u.Path == "/api/quota" || method == "uinfo":
+		return FamilyUser
+	case strings.HasPrefix(u.Path, "/rest/2.0/pcs/superfile2"):
+		return FamilyUpload
+	}
+	switch method {
+	case "list", "listall", "search", "doclist", "imagelist":
+		return FamilyList
+	case "filemetas":
+		return FamilyMetas
+	case "filemanager":
+		return FamilyFilemanager
+	case "precreate", "create", "upload":
+		return FamilyUpload
+	}
+	return FamilyOther
+}
+
+// requestGovernor 按接口族限制 API 请求的速率与并发数
+type requestGovernor struct {
+	mu    sync.Mutex
+	gates map[EndpointFamily]*requestGate
+}
+
+func newRequestGovernor(limits map[EndpointFamily]RequestLimit) *requestGovernor {
+	g := &requestGovernor{gates: make(map[EndpointFamily]*requestGate)}
+	for family, limit := range defaultRequestLimits {
+		g.gates[family] = newRequestGate(limit)
+	}
+	for family, limit := range limits {
+		g.gates[family] = newRequestGate(limit)
+	}
+	return g
+}
+
+// gate 返回接口族对应的闸门，未配置的接口族使用 FamilyOther 的配置
+func (g *requestGovernor) gate(family EndpointFamily) *requestGate {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	gate, ok := g.gates[family]
+	if !ok {
+		gate = newRequestGate(g.gates[FamilyOther].config())
+		g.gates[family] = gate
+	}
+	return gate
+}
+
+// setLimit 运行时调整某个接口族的限流
+func (g *requestGovernor) setLimit(family EndpointFamily, limit RequestLimit) {
+	g.gate(family).setLimit(limit)
+}
+
+// transport 返回在 base 之前执行限流的 http.RoundTripper，请求结果输出到 logger，限流等待时间记录到 metrics，
+// 每个请求在 tracer 中记录一个 span
+func (g *requestGovernor) transport(base http.RoundTripper, logger *slog.Logger, metrics Metrics, tracer trace.Tracer) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &governedTransport{base: base, governor: g, logger: logger, metrics: metrics, tracer: tracer}
+}
+
+// requestGate 单个接口族的限流闸门
+// 并发槽位按 FIFO 顺序分配，拿到槽位的请求再按顺序预约发送时间，保证排队调用方的公平性
+type requestGate struct {
+	mu          sync.Mutex
+	limit       RequestLimit
+	rate        float64 // 当前生效的速率，命中频控后自适应降低
+	inFlight    int
+	waiters     []chan struct{}
+	next        time.Time // 下一个请求允许发送的时间
+	lastPenalty time.Time
+}
+
+func newRequestGate(limit RequestLimit) *requestGate {
+	return &requestGate{limit: limit, rate: limit.RequestsPerSecond}
+}
+
+func (g *requestGate) config() RequestLimit {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.limit
+}
+
+func (g *requestGate) setLimit(limit RequestLimit) {
+	g.mu.Lock()
+	g.limit = limit
+	g.rate = limit.RequestsPerSecond
+	// 放宽并发上限后唤醒排队中的请求
+	for len(g.waiters) > 0 && (limit.MaxInFlight <= 0 || g.inFlight < limit.MaxInFlight) {
+		g.grantLocked()
+	}
+	g.mu.Unlock()
+}
+
+// acquire 等待并发槽位与速率令牌，成功后调用方必须调用 release
+func (g *requestGate) acquire(ctx context.Context) error {
+	g.mu.Lock()
+	if len(g.waiters) == 0 && (g.limit.MaxInFlight <= 0 || g.inFlight < g.limit.MaxInFlight) {
+		g.inFlight++
+	} else {
+		ch := make(chan struct{})
+		g.waiters = append(g.waiters, ch)
+		g.mu.Unlock()
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			g.mu.Lock()
+			for i, w := range g.waiters {
+				if w == ch {
+					g.waiters = append(g.waiters[:i], g.waiters[i+1:]...)
+					g.mu.Unlock()
+					return ctx.Err()
+				}
+			}
+			// 取消与分配同时发生，槽位已经到手，归还后返回
+			g.mu.Unlock()
+			g.release()
+			return ctx.Err()
+		}
+		g.mu.Lock()
+	}
+
+	var wait time.Duration
+	if g.rate > 0 {
+		now := time.Now()
+		if g.next.Before(now) {
+			g.next = now
+		}
+		wait = g.next.Sub(now)
+		g.next = g.next.Add(time.Duration(float64(time.Second) / g.rate))
+	}
+	g.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		g.release()
+		return ctx.Err()
+	}
+}
+
+// release 归还并发槽位，有排队请求时直接转交给队首
+func (g *requestGate) release() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.inFlight--
+	if len(g.waiters) > 0 && (g.limit.MaxInFlight <= 0 || g.inFlight < g.limit.MaxInFlight) {
+		g.grantLocked()
+	}
+}
+
+// grantLocked 把一个并发槽位分配给队首请求，调用方需持有锁
+func (g *requestGate) grantLocked() {
+	ch := g.waiters[0]
+	g.waiters = g.waiters[1:]
+	g.inFlight++
+	close(ch)
+}
+
+// observe 根据请求结果自适应调整速率：命中频控时降速，之后随成功请求逐步恢复，返回调整后的速率
+func (g *requestGate) observe(rateLimited bool) float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	configured := g.limit.RequestsPerSecond
+	if configured <= 0 {
+		return g.rate
+	}
+	now := time.Now()
+	if rateLimited {
+		g.rate *= adaptiveBackoffFactor
+		if floor := configured * adaptiveMinRatio; g.rate < floor {
+			g.rate = floor
+		}
+		g.lastPenalty = now
+		// 推迟下一次发送，给服务端冷却时间
+		if pause := now.Add(time.Duration(float64(time.Second) / g.rate)); g.next.Before(pause) {
+			g.next = pause
+		}
+		return g.rate
+	}
+	if g.rate < configured && now.Sub(g.lastPenalty) > adaptiveRecoverDelay {
+		g.rate += configured * adaptiveRecoverStep
+		if g.rate > configured {
+			g.rate = configured
+		}
+	}
+	return g.rate
+}
+
+// governedTransport 在发送请求前执行限流，并根据响应中的 errno 调整速率
+type governedTransport struct {
+	base     http.RoundTripper
+	governor *requestGovernor
+	logger   *slog.Logger
+	metrics  Metrics
+	tracer   trace.Tracer
+}
+
+func (t *governedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	family := familyOf(req.URL)
+	op := req.URL.Query().Get("method")
+	if op == "" {
+		op = string(family)
+	}
+	endpoint := endpointOf(req.URL)
+	_, span := t.tracer.Start(req.Context(), "baidupan.api "+endpoint,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrEndpoint.String(endpoint)))
+	defer span.End()
+
+	gate := t.governor.gate(family)
+	waitStart := time.Now()
+	if err := gate.acquire(req.Context()); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	defer gate.release()
+	t.metrics.ObserveRateLimitWait(string(family), time.Since(waitStart))
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		t.logger.Warn("api request failed", logKeyOp, op, "family", family, logKeyError, err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	result, err := inspectResponse(resp)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	span.SetAttributes(attrHTTPStatus.Int(resp.StatusCode), attrErrno.Int(result.errno), attrRequestID.String(result.requestID))
+	if result.errno != 0 || resp.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, fmt.Sprintf("status %d, errno %d", resp.StatusCode, result.errno))
+	}
+	rate := gate.observe(result.rateLimited)
+	t.logger.Debug("api request", logKeyOp, op, "family", family, logKeyStatus, resp.StatusCode,
+		logKeyErrno, result.errno, logKeyRequestID, result.requestID, "duration", time.Since(start))
+	if result.rateLimited {
+		t.logger.Warn("api rate limited, backing off", logKeyOp, op, "family", family,
+			logKeyRequestID, result.requestID, "requests_per_second", rate)
+	}
+	return resp, nil
+}
+
+// apiResult 从 API 响应中解析出的公共字段
+type apiResult struct {
+	errno       int
+	requestID   string
+	rateLimited bool
+}
+
+// maxInspectBytes 解析公共字段时最多读入内存的响应体字节数，更长的响应（如大目录列表）不解析，原样流式返回
+const maxInspectBytes = 1 << 20
+
+// inspectedBody 已解析过公共字段的响应体，callObserver 从中取得解析结果而不必再次读取
+type inspectedBody struct {
+	io.Reader
+	io.Closer
+	result apiResult
+}
+
+// inspectResponse 解析 JSON 响应中的 errno 与 request_id 并判断是否为频控错误
+// 只读取 Content-Type 为 JSON 且不超过 maxInspectBytes 的响应，读取后 resp.Body 被替换为 *inspectedBody，
+// 已读取的部分在前、未读取的部分在后，调用方仍能读到完整的响应体
+func inspectResponse(resp *http.Response) (apiResult, error) {
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return apiResult{rateLimited: true}, nil
+	}
+	if !isJSONContentType(resp.Header.Get("Content-Type")) || resp.ContentLength > maxInspectBytes {
+		return apiResult{}, nil
+	}
+	head, err := io.ReadAll(io.LimitReader(resp.Body, maxInspectBytes+1))
+	if err != nil {
+		_ = resp.Body.Close()
+		return apiResult{}, err
+	}
+	var result apiResult
+	if len(head) <= maxInspectBytes {
+		result = parseAPIResult(head)
+	}
+	resp.Body = &inspectedBody{Reader: io.MultiReader(bytes.NewReader(head), resp.Body), Closer: resp.Body, result: result}
+	return result, nil
+}
+
+// inspectedResult 返回 inspectResponse 对 resp 的解析结果，未解析过时 ok 为 false
+func inspectedResult(resp *http.Response) (_ apiResult, ok bool) {
+	body, ok := resp.Body.(*inspectedBody)
+	if !ok {
+		return apiResult{}, false
+	}
+	return body.result, true
+}
+
+// isJSONContentType 判断 Content-Type 是否为 JSON，缺省时按 JSON 处理
+func isJSONContentType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || mediaType == "text/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// parseAPIResult 从 JSON 响应体中取出 errno（或 error_code）与 request_id
+func parseAPIResult(body []byte) apiResult {
+	var fields struct {
+		Errno     json.RawMessage `json:"errno"`
+		ErrorCode json.RawMessage `json:"error_code"`
+		RequestID json.RawMessage `json:"request_id"`
+	}
+	var result apiResult
+	if json.Unmarshal(body, &fields) != nil {
+		return result
+	}
+	result.requestID = strings.Trim(string(fields.RequestID), `"`)
+	errno := fields.Errno
+	if len(errno) == 0 {
+		errno = fields.ErrorCode
+	}
+	if len(errno) > 0 {
+		result.errno, _ = strconv.Atoi(strings.Trim(string(errno), `"`))
+	}
+	result.rateLimited = result.errno == errnoRateLimited
+	return result
+}
+
+// SetRequestLimit 运行时调整某个接口族的请求限流
+func (c *Client) SetRequestLimit(family EndpointFamily, limit RequestLimit) {
+	c.governor.setLimit(family, limit)
+}
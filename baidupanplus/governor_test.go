@@ -0,0 +1,131 @@
+package baidupanplus
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/S-zhi/baidupansdk/baidupantest"
+)
+
+func TestInspectResponse(t *testing.T) {
+	large := `{"errno":31034,"list":"` + strings.Repeat("x", maxInspectBytes) + `"}`
+	tests := []struct {
+		name          string
+		status        int
+		contentType   string
+		contentLength int64
+		body          string
+		want          apiResult
+		inspected     bool
+	}{
+		{"errno", 200, "application/json; charset=UTF-8", -1, `{"errno":31034,"request_id":123}`, apiResult{errno: 31034, requestID: "123", rateLimited: true}, true},
+		{"error code", 400, "application/json", -1, `{"error_code":31363,"request_id":"abc"}`, apiResult{errno: 31363, requestID: "abc"}, true},
+		{"no content type", 200, "", -1, `{"errno":0}`, apiResult{}, true},
+		{"too many requests", 429, "application/json", -1, `{}`, apiResult{rateLimited: true}, false},
+		{"not json", 200, "application/octet-stream", -1, `{"errno":31034}`, apiResult{}, false},
+		{"declared too long", 200, "application/json", int64(len(large)), large, apiResult{}, false},
+		{"streamed too long", 200, "application/json", -1, large, apiResult{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{
+				StatusCode:    tt.status,
+				Header:        http.Header{},
+				ContentLength: tt.contentLength,
+				Body:          io.NopCloser(strings.NewReader(tt.body)),
+			}
+			if tt.contentType != "" {
+				resp.Header.Set("Content-Type", tt.contentType)
+			}
+			got, err := inspectResponse(resp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("result = %+v, want %+v", got, tt.want)
+			}
+			if _, ok := inspectedResult(resp); ok != tt.inspected {
+				t.Errorf("inspected = %v, want %v", ok, tt.inspected)
+			}
+			// 无论是否解析，调用方都能读到完整的响应体
+			body, err := io.ReadAll(resp.Body)
+			if err != nil || !bytes.Equal(body, []byte(tt.body)) {
+				t.Errorf("body after inspection differs (%d bytes, err %v)", len(body), err)
+			}
+		})
+	}
+}
+
+// recordingMetrics 记录 ObserveRequest 与 IncRetry 的调用
+type recordingMetrics struct {
+	nopMetrics
+
+	mu       sync.Mutex
+	requests []observedRequest
+	retries  map[string]int
+}
+
+type observedRequest struct {
+	endpoint string
+	status   int
+	errno    int
+}
+
+func (m *recordingMetrics) ObserveRequest(endpoint string, status int, errno int, _ time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests = append(m.requests, observedRequest{endpoint, status, errno})
+}
+
+func (m *recordingMetrics) IncRetry(endpoint string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.retries == nil {
+		m.retries = map[string]int{}
+	}
+	m.retries[endpoint]++
+}
+
+func TestRateLimitErrnoReachesMetricsAndGovernor(t *testing.T) {
+	_, srv := newTestClient(t)
+	metrics := &recordingMetrics{}
+	cfg := newTestConfig(srv)
+	cfg.Metrics = metrics
+	cfg.RequestLimits = map[EndpointFamily]RequestLimit{FamilyList: {RequestsPerSecond: 50}}
+	c := NewClient(cfg)
+	srv.AddDir("/apps/test")
+	srv.InjectFault(baidupantest.OpList, baidupantest.Fault{Errno: errnoRateLimited, Times: 1})
+
+	_, err := c.List(context.Background(), "/apps/test")
+	if CategoryOf(err) != CategoryRateLimited {
+		t.Fatalf("List error = %v, want rate limited", err)
+	}
+	gate := c.governor.gate(FamilyList)
+	gate.mu.Lock()
+	rate := gate.rate
+	gate.mu.Unlock()
+	if rate >= 50 {
+		t.Errorf("list rate after errno 31034 = %v, want below 50", rate)
+	}
+	if _, err := c.List(context.Background(), "/apps/test"); err != nil {
+		t.Fatalf("List after fault: %v", err)
+	}
+
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	want := []observedRequest{{"list", 200, errnoRateLimited}, {"list", 200, 0}}
+	if len(metrics.requests) != len(want) {
+		t.Fatalf("observed %+v, want %+v", metrics.requests, want)
+	}
+	for i := range want {
+		if metrics.requests[i] != want[i] {
+			t.Errorf("request %d = %+v, want %+v", i, metrics.requests[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,248 @@
+// Package httpgw 将网盘文件以普通 HTTP 的方式提供给内部工具
+//
+// URL 路径映射为 Config.Root 下的网盘路径：目录返回 JSON 或 HTML 列表，
+// 文件经 dlink 转发并支持 Range、If-Modified-Since 与 ETag（取自文件 MD5）。
+// access token 与 dlink 只在服务端使用，不会出现在响应中
+//
+//	h := httpgw.New(client, httpgw.Config{Root: "/apps/media"})
+//	http.Handle("/media/", http.StripPrefix("/media", h))
+package httpgw
+
+import (
+	"encoding/json"
+	"errors"
+	"html/template"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/S-zhi/baidupansdk/baidupanplus"
+)
+
+// Config 网关配置
+type Config struct {
+	// Root 映射到 URL 根路径的网盘目录，默认为 "/"
+	Root string
+	// Logger 记录请求失败的原因，为空时不记录
+	Logger *slog.Logger
+}
+
+// Handler 实现 http.Handler，仅支持 GET 与 HEAD
+type Handler struct {
+	client *baidupanplus.Client
+	root   string
+	logger *slog.Logger
+}
+
+var _ http.Handler = (*Handler)(nil)
+
+// New 创建网关，所有请求都通过 client 访问网盘
+func New(client *baidupanplus.Client, cfg Config) *Handler {
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	return &Handler{
+		client: client,
+		root:   path.Clean("/" + cfg.Root),
+		logger: logger,
+	}
+}
+
+// ServeHTTP 实现 http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	urlPath := r.URL.Path
+	if !strings.HasPrefix(urlPath, "/") {
+		urlPath = "/" + urlPath
+	}
+	name := path.Clean(urlPath)
+	remotePath := path.Join(h.root, name)
+
+	info, err := h.client.Stat(r.Context(), remotePath)
+	if err != nil {
+		h.fail(w, r, remotePath, err)
+		return
+	}
+
+	// 与 http.FileServer 一致：目录以 "/" 结尾，文件不以 "/" 结尾，保证列表中的相对链接正确
+	if info.IsDir() {
+		if name != "/" && !strings.HasSuffix(urlPath, "/") {
+			localRedirect(w, r, path.Base(name)+"/")
+			return
+		}
+		h.serveDir(w, r, name, remotePath)
+		return
+	}
+	if strings.HasSuffix(urlPath, "/") {
+		localRedirect(w, r, "../"+path.Base(name))
+		return
+	}
+	h.serveFile(w, r, info)
+}
+
+// serveFile 转发文件内容，Range 与条件请求由 http.ServeContent 处理
+func (h *Handler) serveFile(w http.ResponseWriter, r *http.Request, info *baidupanplus.FileInfo) {
+	header := w.Header()
+	if info.Md5 != "" {
+		header.Set("ETag", `"`+info.Md5+`"`)
+	}
+	// 显式设置类型，避免 ServeContent 为嗅探内容类型而读取数据
+	ctype := mime.TypeByExtension(path.Ext(info.ServerFilename))
+	if ctype == "" {
+		ctype = "application/octet-stream"
+	}
+	header.Set("Content-Type", ctype)
+
+	// dlink 在第一次读取时才获取，命中 304 或 HEAD 请求时不会访问文件内容
+	rc, err := h.client.OpenFileInfo(r.Context(), info)
+	if err != nil {
+		h.fail(w, r, info.Path, err)
+		return
+	}
+	f := &readErrFile{ReadSeekCloser: rc}
+	defer f.Close()
+	var modtime time.Time
+	if info.ServerMtime > 0 {
+		modtime = info.ModTime()
+	}
+	http.ServeContent(w, r, info.ServerFilename, modtime, f)
+	if f.err != nil {
+		h.logger.Error("读取文件失败", "path", info.Path, "error", f.err)
+	}
+}
+
+// entry 目录列表中的一项
+type entry struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	IsDir   bool      `json:"is_dir"`
+	Md5     string    `json:"md5,omitempty"`
+	ModTime time.Time `json:"mtime"`
+}
+
+// listing 目录列表的 JSON 格式
+type listing struct {
+	Path    string  `json:"path"`
+	Entries []entry `json:"entries"`
+}
+
+// serveDir 返回目录列表：请求带 ?format=json 或 Accept 只接受 JSON 时返回 JSON，否则返回 HTML
+func (h *Handler) serveDir(w http.ResponseWriter, r *http.Request, name string, remotePath string) {
+	files, err := h.client.List(r.Context(), remotePath)
+	if err != nil {
+		h.fail(w, r, remotePath, err)
+		return
+	}
+	l := listing{Path: name, Entries: make([]entry, 0, len(files))}
+	for _, f := range files {
+		l.Entries = append(l.Entries, entry{
+			Name:    f.ServerFilename,
+			Size:    f.Size,
+			IsDir:   f.IsDir(),
+			Md5:     f.Md5,
+			ModTime: f.ModTime(),
+		})
+	}
+
+	if wantsJSON(r) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if r.Method == http.MethodHead {
+			return
+		}
+		_ = json.NewEncoder(w).Encode(l)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if r.Method == http.MethodHead {
+		return
+	}
+	if err := listingTemplate.Execute(w, l); err != nil {
+		h.logger.Error("渲染目录列表失败", "path", remotePath, "error", err)
+	}
+}
+
+// wantsJSON 判断客户端是否要求 JSON 格式的目录列表
+func wantsJSON(r *http.Request) bool {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format == "json"
+	}
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, "application/json") && !strings.Contains(accept, "text/html")
+}
+
+var listingTemplate = template.Must(template.New("listing").Funcs(template.FuncMap{
+	"href": func(e entry) string {
+		if e.IsDir {
+			return url.PathEscape(e.Name) + "/"
+		}
+		return url.PathEscape(e.Name)
+	},
+}).Parse(`<!doctype html>
+<html>
+<head><meta charset="utf-8"><title>{{.Path}}</title></head>
+<body>
+<h1>{{.Path}}</h1>
+<table>
+<tr><th>名称</th><th>大小</th><th>修改时间</th></tr>
+{{if ne .Path "/"}}<tr><td><a href="../">../</a></td><td></td><td></td></tr>
+{{end}}{{range .Entries}}<tr><td><a href="{{href .}}">{{.Name}}{{if .IsDir}}/{{end}}</a></td><td>{{if not .IsDir}}{{.Size}}{{end}}</td><td>{{.ModTime.Format "2006-01-02 15:04:05"}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// fail 按错误类别返回状态码，响应中不包含错误详情
+func (h *Handler) fail(w http.ResponseWriter, r *http.Request, remotePath string, err error) {
+	var code int
+	switch baidupanplus.CategoryOf(err) {
+	case baidupanplus.CategoryNotFound:
+		code = http.StatusNotFound
+	case baidupanplus.CategoryPermission:
+		code = http.StatusForbidden
+	case baidupanplus.CategoryRateLimited:
+		code = http.StatusServiceUnavailable
+	case baidupanplus.CategoryCanceled:
+		// 客户端已断开，无需响应
+		return
+	default:
+		code = http.StatusBadGateway
+	}
+	if code != http.StatusNotFound {
+		h.logger.Error("请求网盘失败", "method", r.Method, "path", remotePath, "error", err)
+	}
+	http.Error(w, http.StatusText(code), code)
+}
+
+// localRedirect 相对于当前路径重定向，在 http.StripPrefix 下同样有效
+func localRedirect(w http.ResponseWriter, r *http.Request, target string) {
+	if q := r.URL.RawQuery; q != "" {
+		target += "?" + q
+	}
+	w.Header().Set("Location", target)
+	w.WriteHeader(http.StatusMovedPermanently)
+}
+
+// readErrFile 记录读取时遇到的第一个错误，ServeContent 写出部分响应后无法再返回错误，只能记录
+type readErrFile struct {
+	io.ReadSeekCloser
+	err error
+}
+
+// Read 实现 io.Reader
+func (f *readErrFile) Read(p []byte) (int, error) {
+	n, err := f.ReadSeekCloser.Read(p)
+	if err != nil && !errors.Is(err, io.EOF) && f.err == nil {
+		f.err = err
+	}
+	return n, err
+}
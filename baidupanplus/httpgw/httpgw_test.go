@@ -0,0 +1,310 @@
+package httpgw
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/S-zhi/baidupansdk/baidupanplus"
+	"github.com/S-zhi/baidupansdk/baidupantest"
+)
+
+// testData 生成 n 字节的确定性测试数据
+func testData(n int) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(i*7 + i/4096)
+	}
+	return data
+}
+
+// newTestGateway 启动模拟网盘服务，并将网关挂载到 /media/，映射到 /apps/media
+func newTestGateway(t *testing.T) (*httptest.Server, *Handler, *baidupantest.Server) {
+	t.Helper()
+	srv := baidupantest.NewServer()
+	t.Cleanup(srv.Close)
+	srv.SetAccessToken("token")
+	srv.AddDir("/apps/media")
+	client := baidupanplus.NewClient(baidupanplus.Config{
+		AccessToken: "token",
+		HTTPClient:  srv.Client(),
+		Logger:      slog.New(slog.DiscardHandler),
+	})
+	h := New(client, Config{Root: "/apps/media"})
+	mux := http.NewServeMux()
+	mux.Handle("/media/", http.StripPrefix("/media", h))
+	gw := httptest.NewServer(mux)
+	t.Cleanup(gw.Close)
+	return gw, h, srv
+}
+
+// get 发送请求并读取响应体，不跟随重定向
+func get(t *testing.T, method string, url string, header map[string]string) (*http.Response, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, body
+}
+
+func TestServeFile(t *testing.T) {
+	gw, _, srv := newTestGateway(t)
+	data := testData(100000)
+	srv.AddFile("/apps/media/movie.mp4", data)
+
+	resp, body := get(t, http.MethodGet, gw.URL+"/media/movie.mp4", nil)
+	if resp.StatusCode != http.StatusOK || !bytes.Equal(body, data) {
+		t.Fatalf("GET = %d with %d bytes", resp.StatusCode, len(body))
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "video/mp4" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if resp.Header.Get("ETag") == "" || resp.Header.Get("Last-Modified") == "" || resp.Header.Get("Accept-Ranges") != "bytes" {
+		t.Errorf("headers = %v", resp.Header)
+	}
+	// access token 与 dlink 不出现在响应中
+	for k, v := range resp.Header {
+		if s := strings.Join(v, ","); strings.Contains(s, "token") || strings.Contains(s, srv.URL) {
+			t.Errorf("header %s leaks %q", k, s)
+		}
+	}
+
+	t.Run("range", func(t *testing.T) {
+		tests := []struct {
+			rng          string
+			contentRange string
+			want         []byte
+		}{
+			{"bytes=100-199", "bytes 100-199/100000", data[100:200]},
+			{"bytes=99990-", "bytes 99990-99999/100000", data[99990:]},
+			{"bytes=-10", "bytes 99990-99999/100000", data[99990:]},
+		}
+		for _, tt := range tests {
+			resp, body := get(t, http.MethodGet, gw.URL+"/media/movie.mp4", map[string]string{"Range": tt.rng})
+			if resp.StatusCode != http.StatusPartialContent || resp.Header.Get("Content-Range") != tt.contentRange || !bytes.Equal(body, tt.want) {
+				t.Errorf("Range %s = %d %q with %d bytes", tt.rng, resp.StatusCode, resp.Header.Get("Content-Range"), len(body))
+			}
+		}
+		resp, _ := get(t, http.MethodGet, gw.URL+"/media/movie.mp4", map[string]string{"Range": "bytes=200000-"})
+		if resp.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+			t.Errorf("unsatisfiable range = %d, want 416", resp.StatusCode)
+		}
+	})
+
+	t.Run("dlink redirect", func(t *testing.T) {
+		srv.SetDlinkRedirect(true)
+		defer srv.SetDlinkRedirect(false)
+		resp, body := get(t, http.MethodGet, gw.URL+"/media/movie.mp4", map[string]string{"Range": "bytes=5000-5999"})
+		if resp.StatusCode != http.StatusPartialContent || !bytes.Equal(body, data[5000:6000]) {
+			t.Errorf("GET through redirecting dlink = %d with %d bytes", resp.StatusCode, len(body))
+		}
+		resp, body = get(t, http.MethodGet, gw.URL+"/media/movie.mp4", nil)
+		if resp.StatusCode != http.StatusOK || !bytes.Equal(body, data) {
+			t.Errorf("full GET through redirecting dlink = %d with %d bytes", resp.StatusCode, len(body))
+		}
+	})
+}
+
+func TestConditionalRequests(t *testing.T) {
+	gw, _, srv := newTestGateway(t)
+	srv.AddFile("/apps/media/a.txt", []byte("hello"))
+	resp, _ := get(t, http.MethodGet, gw.URL+"/media/a.txt", nil)
+	etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	downloads, metas := srv.Requests(baidupantest.OpDownload), srv.Requests(baidupantest.OpFileMetas)
+
+	tests := []struct {
+		name   string
+		header map[string]string
+		status int
+	}{
+		{"etag match", map[string]string{"If-None-Match": etag}, http.StatusNotModified},
+		{"weak etag list", map[string]string{"If-None-Match": `"other", W/` + etag}, http.StatusNotModified},
+		{"not modified since", map[string]string{"If-Modified-Since": lastModified}, http.StatusNotModified},
+		{"etag mismatch", map[string]string{"If-None-Match": `"other"`}, http.StatusOK},
+		{"modified since", map[string]string{"If-Modified-Since": "Mon, 02 Jan 2006 15:04:05 GMT"}, http.StatusOK},
+		{"etag takes precedence", map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": lastModified}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := srv.Requests(baidupantest.OpDownload)
+			resp, body := get(t, http.MethodGet, gw.URL+"/media/a.txt", tt.header)
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if tt.status == http.StatusNotModified {
+				// 304 不读取文件内容，也不获取 dlink
+				if len(body) != 0 || srv.Requests(baidupantest.OpDownload) != before {
+					t.Errorf("304 response transferred %d bytes", len(body))
+				}
+			} else if string(body) != "hello" {
+				t.Errorf("body = %q", body)
+			}
+		})
+	}
+	if n := srv.Requests(baidupantest.OpDownload) - downloads; n != 3 {
+		t.Errorf("download requests = %d, want 3", n)
+	}
+	if n := srv.Requests(baidupantest.OpFileMetas) - metas; n != 3 {
+		t.Errorf("filemetas requests = %d, want 3", n)
+	}
+}
+
+func TestHead(t *testing.T) {
+	gw, _, srv := newTestGateway(t)
+	srv.AddFile("/apps/media/a.bin", testData(1234))
+	srv.AddDir("/apps/media/dir")
+
+	resp, body := get(t, http.MethodHead, gw.URL+"/media/a.bin", nil)
+	if resp.StatusCode != http.StatusOK || resp.ContentLength != 1234 || len(body) != 0 {
+		t.Errorf("HEAD file = %d, length %d, body %d bytes", resp.StatusCode, resp.ContentLength, len(body))
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/octet-stream" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if srv.Requests(baidupantest.OpDownload) != 0 || srv.Requests(baidupantest.OpFileMetas) != 0 {
+		t.Error("HEAD fetched the file content")
+	}
+
+	resp, body = get(t, http.MethodHead, gw.URL+"/media/dir/?format=json", nil)
+	if resp.StatusCode != http.StatusOK || len(body) != 0 || !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		t.Errorf("HEAD dir = %d %q with %d bytes", resp.StatusCode, resp.Header.Get("Content-Type"), len(body))
+	}
+
+	resp, _ = get(t, http.MethodPost, gw.URL+"/media/a.bin", nil)
+	if resp.StatusCode != http.StatusMethodNotAllowed || resp.Header.Get("Allow") != "GET, HEAD" {
+		t.Errorf("POST = %d, Allow %q", resp.StatusCode, resp.Header.Get("Allow"))
+	}
+}
+
+func TestDirectoryListing(t *testing.T) {
+	gw, _, srv := newTestGateway(t)
+	srv.AddFile("/apps/media/dir/a b.txt", []byte("hello"))
+	srv.AddFile("/apps/media/dir/<x>.txt", []byte("x"))
+	srv.AddDir("/apps/media/dir/sub")
+
+	// 目录不以 / 结尾、文件以 / 结尾时重定向
+	resp, _ := get(t, http.MethodGet, gw.URL+"/media/dir?format=json", nil)
+	if resp.StatusCode != http.StatusMovedPermanently || resp.Header.Get("Location") != "dir/?format=json" {
+		t.Errorf("dir without slash = %d to %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	resp, _ = get(t, http.MethodGet, gw.URL+"/media/dir/a%20b.txt/", nil)
+	if resp.StatusCode != http.StatusMovedPermanently || resp.Header.Get("Location") != "../a b.txt" {
+		t.Errorf("file with slash = %d to %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+
+	for _, header := range []map[string]string{nil, {"Accept": "application/json"}} {
+		url := gw.URL + "/media/dir/"
+		if header == nil {
+			url += "?format=json"
+		}
+		resp, body := get(t, http.MethodGet, url, header)
+		if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+			t.Fatalf("JSON listing = %d %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+		var l listing
+		if err := json.Unmarshal(body, &l); err != nil {
+			t.Fatal(err)
+		}
+		got := map[string]entry{}
+		for _, e := range l.Entries {
+			got[e.Name] = e
+		}
+		if l.Path != "/dir" || len(l.Entries) != 3 || got["a b.txt"].Size != 5 || got["a b.txt"].Md5 == "" || !got["sub"].IsDir || got["a b.txt"].ModTime.IsZero() {
+			t.Errorf("JSON listing = %+v", l)
+		}
+	}
+
+	for _, header := range []map[string]string{nil, {"Accept": "text/html,application/json"}} {
+		resp, body := get(t, http.MethodGet, gw.URL+"/media/dir/", header)
+		html := string(body)
+		if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+			t.Fatalf("HTML listing = %d %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+		for _, want := range []string{`href="../"`, `href="a%20b.txt"`, `href="sub/"`, `&lt;x&gt;.txt`} {
+			if !strings.Contains(html, want) {
+				t.Errorf("HTML listing lacks %s:\n%s", want, html)
+			}
+		}
+		if strings.Contains(html, "<x>") {
+			t.Error("HTML listing does not escape file names")
+		}
+	}
+
+	// 根目录没有上级链接
+	_, body := get(t, http.MethodGet, gw.URL+"/media/", nil)
+	if strings.Contains(string(body), `href="../"`) || !strings.Contains(string(body), `href="dir/"`) {
+		t.Errorf("root listing:\n%s", body)
+	}
+}
+
+func TestPathTraversal(t *testing.T) {
+	_, h, srv := newTestGateway(t)
+	srv.AddFile("/apps/secret.txt", []byte("secret"))
+	srv.AddFile("/secret.txt", []byte("secret"))
+	srv.AddFile("/apps/media/secret.txt", []byte("public"))
+
+	// URL 路径先规范化再拼接到 Root 下，.. 无法越过 Root
+	for _, p := range []string{"/../secret.txt", "/../../secret.txt", "/x/../../secret.txt", "../secret.txt", "/../media/../../secret.txt"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.URL.Path = p
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if body := rec.Body.String(); rec.Code != http.StatusOK || body != "public" {
+			t.Errorf("%s = %d %q, want the file inside the root", p, rec.Code, body)
+		}
+	}
+	// 编码后的 .. 解码后同样被规范化
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/%2e%2e/%2e%2e/secret.txt", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "public" {
+		t.Errorf("encoded traversal = %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestErrorStatus(t *testing.T) {
+	gw, _, srv := newTestGateway(t)
+	srv.AddFile("/apps/media/a.txt", []byte("hello"))
+
+	resp, body := get(t, http.MethodGet, gw.URL+"/media/missing.txt", nil)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("missing file = %d", resp.StatusCode)
+	}
+	tests := []struct {
+		errno  int
+		status int
+	}{
+		{-5, http.StatusForbidden},
+		{-6, http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		srv.InjectFault(baidupantest.OpList, baidupantest.Fault{Errno: tt.errno, Times: 1})
+		resp, body = get(t, http.MethodGet, gw.URL+"/media/a.txt", nil)
+		if resp.StatusCode != tt.status {
+			t.Errorf("errno %d = %d, want %d", tt.errno, resp.StatusCode, tt.status)
+		}
+		// 响应中不包含错误详情
+		if strings.Contains(string(body), fmt.Sprint(tt.errno)) || strings.Contains(string(body), "token") {
+			t.Errorf("error body leaks details: %q", body)
+		}
+	}
+}
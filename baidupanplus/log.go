@@ -1,38 +1,72 @@
 package baidupanplus
 
 import (
+	"context"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
-	"runtime"
+	"sync/atomic"
 
 	"gopkg.in/natefinch/lumberjack.v2"
 )
 
-func logInit() {
-	// 创建日志目录
-	if err := ensureLogDir(config.LogPath); err != nil {
-		fmt.Printf("failed to create log directory: %v\n", err)
-		return
+// SDK 日志统一使用的字段名
+const (
+	logKeyOp        = "op"          // 操作，如 upload、download、precreate
+	logKeyPath      = "remote_path" // 网盘路径
+	logKeyLocalPath = "local_path"  // 本地路径
+	logKeyPartSeq   = "part_seq"    // 分片序号
+	logKeyRequestID = "request_id"  // 接口返回的 request_id
+	logKeyErrno     = "errno"
+	logKeySize      = "size"
+	logKeyStatus    = "status"
+	logKeyError     = "error"
+)
+
+// discardLogger 默认的日志，丢弃全部输出
+var discardLogger = slog.New(slog.DiscardHandler)
+
+// packageLoggerValue 包级别函数与未指定 Config.Logger 的客户端使用的日志
+var packageLoggerValue atomic.Pointer[slog.Logger]
+
+// SetLogger 设置包级别函数及之后创建的、未指定 Config.Logger 的客户端使用的日志，nil 表示不输出日志
+// SDK 只通过传入的 Logger 输出日志，不会修改标准库 log 的全局设置，输出前会屏蔽令牌、密钥与签名
+func SetLogger(l *slog.Logger) {
+	packageLoggerValue.Store(redactLogger(l))
+}
+
+// packageLogger 返回包级别日志，未设置时为静默日志
+func packageLogger() *slog.Logger {
+	if l := packageLoggerValue.Load(); l != nil {
+		return l
 	}
-	log.SetFlags(log.LstdFlags) // 使用标准时间戳 + 文件名行号
+	return discardLogger
+}
 
-	// 使用 lumberjack 实现日志轮转
-	lumberjackLogger := &lumberjack.Logger{
-		Filename:   config.LogPath,
+// NewRotatingFileWriter 返回按大小轮转的日志文件，可作为 slog.Handler 的输出：
+// 单个文件 100MB，保留 3 个备份、28 天，旧文件压缩
+func NewRotatingFileWriter(path string) (io.WriteCloser, error) {
+	if err := ensureLogDir(path); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	return &lumberjack.Logger{
+		Filename:   path,
 		MaxSize:    100, // MB
 		MaxBackups: 3,
 		MaxAge:     28, // days
 		Compress:   true,
-	}
+	}, nil
+}
 
-	// 同时输出到控制台和文件轮转
-	mw := io.MultiWriter(os.Stdout, lumberjackLogger)
-	log.SetOutput(mw)
-	log.SetPrefix("[INFO] ") // 设置默认日志前缀
-	log.Println("日志系统初始化成功")
+// NewFileLogger 返回写入轮转日志文件的文本格式 Logger
+func NewFileLogger(path string, level slog.Level) (*slog.Logger, error) {
+	w, err := NewRotatingFileWriter(path)
+	if err != nil {
+		return nil, err
+	}
+	return slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{Level: level})), nil
 }
 
 // ensureLogDir 创建日志文件所需的目录
@@ -41,36 +75,47 @@ func ensureLogDir(filePath string) error {
 	return os.MkdirAll(dir, 0755)
 }
 
-func logWithCaller(level string, format string, args ...interface{}) {
-	// 2 表示跳过：
-	// runtime.Caller -> logWithCaller -> Info/Warn/Error -> 业务代码
-	_, file, line, ok := runtime.Caller(2)
-
-	location := "unknown"
-	if ok {
-		location = fmt.Sprintf("%s:%d", filepath.Base(file), line)
+// logf 以 printf 格式向包级别日志输出一条记录
+func logf(level slog.Level, format string, args ...interface{}) {
+	l := packageLogger()
+	if !l.Enabled(context.Background(), level) {
+		return
 	}
-
-	log.SetPrefix(fmt.Sprintf("[%s] ", level))
-	log.Println(location, "-", fmt.Sprintf(format, args...))
+	l.Log(context.Background(), level, fmt.Sprintf(format, args...))
 }
-func Info(message string, args ...interface{}) {
-	logWithCaller("INFO", message, args...)
+
+// Info 输出 INFO 日志
+//
+// Deprecated: 使用 SetLogger 或 Config.Logger 传入的 *slog.Logger
+func Info(format string, args ...interface{}) {
+	logf(slog.LevelInfo, format, args...)
 }
 
-func Warn(message string, args ...interface{}) {
-	logWithCaller("WARN", message, args...)
+// Warn 输出 WARN 日志
+//
+// Deprecated: 使用 SetLogger 或 Config.Logger 传入的 *slog.Logger
+func Warn(format string, args ...interface{}) {
+	logf(slog.LevelWarn, format, args...)
 }
 
-func Error(message string, args ...interface{}) {
-	logWithCaller("ERROR", message, args...)
+// Error 输出 ERROR 日志
+//
+// Deprecated: 使用 SetLogger 或 Config.Logger 传入的 *slog.Logger
+func Error(format string, args ...interface{}) {
+	logf(slog.LevelError, format, args...)
 }
 
-func Debug(message string, args ...interface{}) {
-	logWithCaller("DEBUG", message, args...)
+// Debug 输出 DEBUG 日志
+//
+// Deprecated: 使用 SetLogger 或 Config.Logger 传入的 *slog.Logger
+func Debug(format string, args ...interface{}) {
+	logf(slog.LevelDebug, format, args...)
 }
 
-func Fatal(message string, args ...interface{}) {
-	logWithCaller("FATAL", message, args...)
+// Fatal 输出 ERROR 日志后退出进程，SDK 内部不会调用
+//
+// Deprecated: 由调用方自行决定是否退出进程
+func Fatal(format string, args ...interface{}) {
+	logf(slog.LevelError, format, args...)
 	os.Exit(1)
 }
@@ -0,0 +1,111 @@
+package baidupanplus
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+)
+
+// TransferDirection 传输方向
+type TransferDirection string
+
+const (
+	DirectionUpload   TransferDirection = "upload"
+	DirectionDownload TransferDirection = "download"
+)
+
+// Metrics SDK 指标的接收方，通过 Config.Metrics 设置，实现需并发安全
+// prommetrics 包提供了基于 Prometheus 客户端的实现
+type Metrics interface {
+	// ObserveRequest 一次 API 请求结束：接口名、HTTP 状态码（网络错误时为 0）、响应中的 errno 与耗时
+	ObserveRequest(endpoint string, status int, errno int, elapsed time.Duration)
+	// IncRetry SDK 对接口发起了一次重试
+	IncRetry(endpoint string)
+	// AddTransferredBytes 上传或下载了 n 字节文件数据
+	AddTransferredBytes(direction TransferDirection, n int64)
+	// AddActiveTransfers 进行中的传输数变化 delta
+	AddActiveTransfers(direction TransferDirection, delta int)
+	// ObserveRateLimitWait 因限流等待的时间，limiter 为接口族名，或带宽限速的 upload_bandwidth / download_bandwidth
+	ObserveRateLimitWait(limiter string, wait time.Duration)
+}
+
+// nopMetrics 未设置 Config.Metrics 时使用，丢弃全部指标
+type nopMetrics struct{}
+
+func (nopMetrics) ObserveRequest(string, int, int, time.Duration) {}
+func (nopMetrics) IncRetry(string)                                {}
+func (nopMetrics) AddTransferredBytes(TransferDirection, int64)   {}
+func (nopMetrics) AddActiveTransfers(TransferDirection, int)      {}
+func (nopMetrics) ObserveRateLimitWait(string, time.Duration)     {}
+
+// callObserver 在 openapi.APIClient.callAPI 处记录每次 API 请求
+type callObserver struct {
+	metrics Metrics
+}
+
+func (o callObserver) ObserveCall(req *http.Request, resp *http.Response, err error, elapsed time.Duration) {
+	status, errno := 0, 0
+	if err == nil && resp != nil {
+		status = resp.StatusCode
+		// 请求经过 governedTransport 时响应已解析过，直接复用结果
+		if result, ok := inspectedResult(resp); ok {
+			errno = result.errno
+		}
+	}
+	o.metrics.ObserveRequest(endpointOf(req.URL), status, errno, elapsed)
+}
+
+// endpointOf 根据请求 URL 得到指标中的接口名：method 参数，文件管理接口为 opera，OAuth 接口为 grant_type
+func endpointOf(u *url.URL) string {
+	q := u.Query()
+	switch {
+	case q.Get("method") == "filemanager" && q.Get("opera") != "":
+		return q.Get("opera")
+	case q.Get("method") != "":
+		return q.Get("method")
+	case q.Get("grant_type") != "":
+		return q.Get("grant_type")
+	case q.Get("response_type") != "":
+		return q.Get("response_type")
+	}
+	return path.Base(u.Path)
+}
+
+// trackTransfer 记录一次进行中的传输，返回的函数在传输结束时调用
+func (c *Client) trackTransfer(direction TransferDirection) func() {
+	c.metrics.AddActiveTransfers(direction, 1)
+	return func() {
+		c.metrics.AddActiveTransfers(direction, -1)
+	}
+}
+
+// rateLimitWaitObserver 返回记录带宽限速等待时间的回调
+func (c *Client) rateLimitWaitObserver(direction TransferDirection) func(time.Duration) {
+	return func(wait time.Duration) {
+		c.metrics.ObserveRateLimitWait(string(direction)+"_bandwidth", wait)
+	}
+}
+
+// meterReader 统计经 r 读取的文件数据字节数
+func (c *Client) meterReader(direction TransferDirection, r io.Reader) io.Reader {
+	if _, ok := c.metrics.(nopMetrics); ok {
+		return r
+	}
+	return &meteredReader{r: r, direction: direction, metrics: c.metrics}
+}
+
+type meteredReader struct {
+	r         io.Reader
+	direction TransferDirection
+	metrics   Metrics
+}
+
+func (m *meteredReader) Read(p []byte) (int, error) {
+	n, err := m.r.Read(p)
+	if n > 0 {
+		m.metrics.AddTransferredBytes(m.direction, int64(n))
+	}
+	return n, err
+}
@@ -0,0 +1,117 @@
+package baidupanplus
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	openapi "github.com/S-zhi/baidupansdk/openxpanapi"
+)
+
+// ConflictPolicy 创建文件或目录时目标路径已存在的处理策略，对应 create 接口的 rtype 参数
+type ConflictPolicy int32
+
+const (
+	ConflictFail           ConflictPolicy = 0 // 返回失败
+	ConflictRename         ConflictPolicy = 1 // 路径冲突时重命名
+	ConflictRenameIfDiffer ConflictPolicy = 2 // 路径冲突且内容不同时重命名
+	ConflictOverwrite      ConflictPolicy = 3 // 覆盖
+)
+
+// Mkdir 创建目录 dirPath，父目录不存在时返回满足 errors.Is(err, ErrNotFound) 的错误
+// policy 为 ConflictFail 时，dirPath 已是目录视为成功并返回该目录；为 ConflictRename 等策略时由服务端决定新目录名，
+// 返回的 FileInfo.Path 为实际创建的路径
+func (c *Client) Mkdir(ctx context.Context, dirPath string, policy ConflictPolicy) (_ *FileInfo, err error) {
+	dirPath = path.Clean("/" + dirPath)
+	ctx, span := c.startSpan(ctx, "Mkdir", attrRemotePath.String(dirPath))
+	defer func() { endSpan(span, err) }()
+
+	if parent := path.Dir(dirPath); parent != "/" {
+		info, err := c.Stat(ctx, parent)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("mkdir %s: parent is not a directory", dirPath)
+		}
+	}
+	return c.mkdir(ctx, dirPath, policy)
+}
+
+// MkdirAll 创建目录 dirPath 及所有不存在的父目录，dirPath 已是目录时直接返回该目录
+func (c *Client) MkdirAll(ctx context.Context, dirPath string) (_ *FileInfo, err error) {
+	dirPath = path.Clean("/" + dirPath)
+	ctx, span := c.startSpan(ctx, "MkdirAll", attrRemotePath.String(dirPath))
+	defer func() { endSpan(span, err) }()
+
+	return c.mkdirAll(ctx, dirPath)
+}
+
+func (c *Client) mkdirAll(ctx context.Context, dirPath string) (*FileInfo, error) {
+	info, err := c.Stat(ctx, dirPath)
+	if err == nil {
+		if !info.IsDir() {
+			return nil, fmt.Errorf("mkdir %s: %w", dirPath, newErrnoError("mkdir", errnoExists))
+		}
+		return info, nil
+	}
+	if CategoryOf(err) != CategoryNotFound {
+		return nil, err
+	}
+	if parent := path.Dir(dirPath); parent != "/" {
+		if _, err := c.mkdirAll(ctx, parent); err != nil {
+			return nil, err
+		}
+	}
+	return c.mkdir(ctx, dirPath, ConflictFail)
+}
+
+// errnoExists 文件或目录已存在
+const errnoExists = -8
+
+// mkdir 调用 create 接口创建目录，ConflictFail 下同名目录已存在时返回该目录
+func (c *Client) mkdir(ctx context.Context, dirPath string, policy ConflictPolicy) (*FileInfo, error) {
+	resp, _, err := c.api.FileuploadApi.Xpanfilecreate(ctx).
+		AccessToken(c.cfg.AccessToken).
+		Path(dirPath).
+		Isdir(isdirDir).
+		Size(0).
+		Uploadid("").
+		BlockList("[]").
+		Rtype(int32(policy)).
+		Execute()
+	if err != nil {
+		c.logger.Error("failed to execute mkdir", logKeyOp, "mkdir", logKeyPath, dirPath, logKeyError, err)
+		return nil, err
+	}
+	if resp.GetErrno() == errnoExists && policy == ConflictFail {
+		info, err := c.Stat(ctx, dirPath)
+		if err != nil {
+			return nil, err
+		}
+		if info.IsDir() {
+			return info, nil
+		}
+	}
+	if resp.GetErrno() != 0 {
+		c.logger.Error("mkdir failed", logKeyOp, "mkdir", logKeyPath, dirPath, logKeyErrno, resp.GetErrno())
+		return nil, fmt.Errorf("mkdir %s: %w", dirPath, newErrnoError("mkdir", resp.GetErrno()))
+	}
+	c.logger.Info("created directory", logKeyOp, "mkdir", logKeyPath, resp.GetPath())
+	return fileInfoFromCreate(resp), nil
+}
+
+// fileInfoFromCreate 将 create 接口的响应转换为 FileInfo
+func fileInfoFromCreate(resp openapi.Filecreateresponse) *FileInfo {
+	return &FileInfo{
+		FsId:           resp.GetFsId(),
+		Path:           resp.GetPath(),
+		ServerFilename: path.Base(resp.GetPath()),
+		Size:           resp.GetSize(),
+		Isdir:          resp.GetIsdir(),
+		Md5:            resp.GetMd5(),
+		Category:       resp.GetCategory(),
+		ServerCtime:    int64(resp.GetCtime()),
+		ServerMtime:    int64(resp.GetMtime()),
+	}
+}
@@ -0,0 +1,122 @@
+package baidupanplus
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/S-zhi/baidupansdk/baidupantest"
+)
+
+func TestMkdirAll(t *testing.T) {
+	c, srv := newTestClient(t)
+	ctx := context.Background()
+	srv.AddDir("/apps/test")
+
+	info, err := c.MkdirAll(ctx, "apps/test/a/b/../b/c/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Path != "/apps/test/a/b/c" || !info.IsDir() {
+		t.Errorf("MkdirAll = %s (dir %v), want directory /apps/test/a/b/c", info.Path, info.IsDir())
+	}
+	for _, p := range []string{"/apps/test/a", "/apps/test/a/b", "/apps/test/a/b/c"} {
+		if got, err := c.Stat(ctx, p); err != nil || !got.IsDir() {
+			t.Errorf("%s not created as a directory: %v", p, err)
+		}
+	}
+	// 只为不存在的三级目录调用 create
+	if n := srv.Requests(baidupantest.OpCreate); n != 3 {
+		t.Errorf("create requests = %d, want 3", n)
+	}
+
+	// 目录已存在时直接返回，不再调用 create
+	again, err := c.MkdirAll(ctx, "/apps/test/a/b/c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.FsId != info.FsId {
+		t.Errorf("existing directory fs_id = %d, want %d", again.FsId, info.FsId)
+	}
+	if n := srv.Requests(baidupantest.OpCreate); n != 3 {
+		t.Errorf("create requests after second MkdirAll = %d, want 3", n)
+	}
+
+	// 路径中的某一级是文件时失败
+	srv.AddFile("/apps/test/file", []byte("x"))
+	for _, p := range []string{"/apps/test/file", "/apps/test/file/sub"} {
+		if _, err := c.MkdirAll(ctx, p); CategoryOf(err) != CategoryExists {
+			t.Errorf("MkdirAll(%s) error = %v, want exists", p, err)
+		}
+	}
+}
+
+func TestMkdirConflictPolicies(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("missing parent", func(t *testing.T) {
+		c, srv := newTestClient(t)
+		if _, err := c.Mkdir(ctx, "/apps/test/missing/dir", ConflictFail); !errors.Is(err, ErrNotFound) {
+			t.Errorf("error = %v, want ErrNotFound", err)
+		}
+		if srv.Exists("/apps/test/missing") {
+			t.Error("Mkdir created the missing parent")
+		}
+	})
+
+	t.Run("parent is a file", func(t *testing.T) {
+		c, srv := newTestClient(t)
+		srv.AddFile("/apps/test/file", []byte("x"))
+		if _, err := c.Mkdir(ctx, "/apps/test/file/dir", ConflictFail); err == nil {
+			t.Error("Mkdir under a file succeeded")
+		}
+	})
+
+	t.Run("fail returns existing directory", func(t *testing.T) {
+		c, srv := newTestClient(t)
+		id := srv.AddDir("/apps/test/dir")
+		info, err := c.Mkdir(ctx, "/apps/test/dir", ConflictFail)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.FsId != id || info.Path != "/apps/test/dir" {
+			t.Errorf("Mkdir = %d %s, want existing %d /apps/test/dir", info.FsId, info.Path, id)
+		}
+	})
+
+	t.Run("fail on existing file", func(t *testing.T) {
+		c, srv := newTestClient(t)
+		srv.AddFile("/apps/test/dir", []byte("x"))
+		if _, err := c.Mkdir(ctx, "/apps/test/dir", ConflictFail); CategoryOf(err) != CategoryExists {
+			t.Errorf("error = %v, want exists", err)
+		}
+	})
+
+	t.Run("rename", func(t *testing.T) {
+		c, srv := newTestClient(t)
+		srv.AddDir("/apps/test/dir")
+		info, err := c.Mkdir(ctx, "/apps/test/dir", ConflictRename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Path == "/apps/test/dir" || !strings.HasPrefix(info.Path, "/apps/test/dir_") || !info.IsDir() {
+			t.Errorf("renamed directory = %s", info.Path)
+		}
+		if !srv.Exists(info.Path) || !srv.Exists("/apps/test/dir") {
+			t.Error("rename did not keep both directories")
+		}
+	})
+
+	t.Run("new directory", func(t *testing.T) {
+		c, srv := newTestClient(t)
+		srv.AddDir("/apps/test")
+		info, err := c.Mkdir(ctx, "/apps/test/new", ConflictOverwrite)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Path != "/apps/test/new" || !info.IsDir() || info.ServerFilename != "new" {
+			t.Errorf("Mkdir = %+v", info)
+		}
+	})
+}
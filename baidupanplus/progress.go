@@ -0,0 +1,184 @@
+package baidupanplus
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// TransferPhase 传输阶段
+type TransferPhase string
+
+const (
+	PhaseHashing     TransferPhase = "hashing"     // 计算分片 MD5
+	PhasePrecreate   TransferPhase = "precreate"   // 预上传
+	PhaseUploading   TransferPhase = "uploading"   // 分片上传
+	PhaseCreating    TransferPhase = "creating"    // 合并分片创建文件
+	PhaseDownloading TransferPhase = "downloading" // 下载
+	PhaseVerifying   TransferPhase = "verifying"   // 校验结果
+	PhaseDone        TransferPhase = "done"        // 传输完成
+)
+
+// defaultProgressInterval 进度回调的默认最小间隔
+const defaultProgressInterval = 500 * time.Millisecond
+
+// Progress 传输进度事件
+type Progress struct {
+	Phase        TransferPhase `json:"phase"`         // 当前阶段
+	Path         string        `json:"path"`          // 远程路径
+	Transferred  int64         `json:"transferred"`   // 当前阶段已处理字节数
+	Total        int64         `json:"total"`         // 当前阶段总字节数，未知时为 -1
+	Speed        float64       `json:"speed"`         // 瞬时速度（字节/秒），按两次回调之间的增量计算
+	AverageSpeed float64       `json:"average_speed"` // 当前阶段平均速度（字节/秒）
+	ETA          time.Duration `json:"eta"`           // 当前阶段预计剩余时间，无法估算时为 -1
+}
+
+// ProgressFunc 进度回调函数
+// 回调在传输所在的 goroutine 中同步执行，应尽快返回
+type ProgressFunc func(p Progress)
+
+// ProgressChan 将进度事件投递到 channel 的 ProgressFunc
+// 投递不会阻塞传输：channel 已满时丢弃本次事件
+func ProgressChan(ch chan<- Progress) ProgressFunc {
+	return func(p Progress) {
+		select {
+		case ch <- p:
+		default:
+		}
+	}
+}
+
+type progressKey struct{}
+
+// WithProgress 返回携带进度回调的 context，供 Client 上的上传、下载方法使用
+// interval 为两次回调的最小间隔，<= 0 时使用默认值 500ms；阶段切换与传输结束总会触发回调
+func WithProgress(ctx context.Context, fn ProgressFunc, interval time.Duration) context.Context {
+	if fn == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, progressKey{}, newProgressTracker(fn, interval))
+}
+
+// progressFromContext 取出 context 中的进度跟踪器，未设置时返回 nil
+// progressTracker 的方法均允许 nil 接收者，调用方无需判空
+func progressFromContext(ctx context.Context) *progressTracker {
+	t, _ := ctx.Value(progressKey{}).(*progressTracker)
+	return t
+}
+
+// progressTracker 统计传输字节数并按间隔触发回调，可被多个分片并发使用
+type progressTracker struct {
+	fn       ProgressFunc
+	interval time.Duration
+
+	mu          sync.Mutex
+	path        string
+	phase       TransferPhase
+	total       int64
+	transferred int64
+	phaseStart  time.Time
+	lastEmit    time.Time
+	lastBytes   int64
+}
+
+func newProgressTracker(fn ProgressFunc, interval time.Duration) *progressTracker {
+	if interval <= 0 {
+		interval = defaultProgressInterval
+	}
+	return &progressTracker{fn: fn, interval: interval}
+}
+
+// start 进入新的阶段并立即触发一次回调
+func (t *progressTracker) start(phase TransferPhase, remotePath string, total int64) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	now := time.Now()
+	t.phase = phase
+	t.path = remotePath
+	t.total = total
+	t.transferred = 0
+	t.phaseStart = now
+	t.lastEmit = now
+	t.lastBytes = 0
+	p := t.snapshot(now, 0)
+	t.mu.Unlock()
+	t.fn(p)
+}
+
+// add 累加已传输字节数，距上次回调超过 interval 时触发回调
+func (t *progressTracker) add(n int64) {
+	if t == nil || n == 0 {
+		return
+	}
+	t.mu.Lock()
+	t.transferred += n
+	now := time.Now()
+	elapsed := now.Sub(t.lastEmit)
+	if elapsed < t.interval && (t.total < 0 || t.transferred < t.total) {
+		t.mu.Unlock()
+		return
+	}
+	p := t.snapshot(now, elapsed)
+	t.lastEmit = now
+	t.lastBytes = t.transferred
+	t.mu.Unlock()
+	t.fn(p)
+}
+
+// done 触发传输完成回调
+func (t *progressTracker) done() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	now := time.Now()
+	p := t.snapshot(now, now.Sub(t.lastEmit))
+	p.Phase = PhaseDone
+	p.ETA = 0
+	t.mu.Unlock()
+	t.fn(p)
+}
+
+// snapshot 在持有锁的情况下生成当前进度
+func (t *progressTracker) snapshot(now time.Time, sinceLast time.Duration) Progress {
+	p := Progress{
+		Phase:       t.phase,
+		Path:        t.path,
+		Transferred: t.transferred,
+		Total:       t.total,
+		ETA:         -1,
+	}
+	if sinceLast > 0 {
+		p.Speed = float64(t.transferred-t.lastBytes) / sinceLast.Seconds()
+	}
+	if elapsed := now.Sub(t.phaseStart); elapsed > 0 {
+		p.AverageSpeed = float64(t.transferred) / elapsed.Seconds()
+	}
+	if t.total >= 0 && p.AverageSpeed > 0 {
+		p.ETA = time.Duration(float64(t.total-t.transferred) / p.AverageSpeed * float64(time.Second))
+	}
+	return p
+}
+
+// reader 包装 r，读取时累加进度
+func (t *progressTracker) reader(r io.Reader) io.Reader {
+	if t == nil {
+		return r
+	}
+	return &progressReader{r: r, t: t}
+}
+
+// progressReader 统计读取字节数的 io.Reader
+type progressReader struct {
+	r io.Reader
+	t *progressTracker
+}
+
+func (pr *progressReader) Read(p []byte) (int, error) {
+	n, err := pr.r.Read(p)
+	pr.t.add(int64(n))
+	return n, err
+}
@@ -0,0 +1,141 @@
+package baidupanplus
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// progressRecorder 记录进度回调收到的全部事件
+type progressRecorder struct {
+	mu     sync.Mutex
+	events []Progress
+}
+
+func (r *progressRecorder) record(p Progress) {
+	r.mu.Lock()
+	r.events = append(r.events, p)
+	r.mu.Unlock()
+}
+
+// phases 返回去掉连续重复后的阶段序列
+func (r *progressRecorder) phases() []TransferPhase {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var phases []TransferPhase
+	for _, e := range r.events {
+		if len(phases) == 0 || phases[len(phases)-1] != e.Phase {
+			phases = append(phases, e.Phase)
+		}
+	}
+	return phases
+}
+
+// last 返回 phase 阶段的最后一个事件
+func (r *progressRecorder) last(phase TransferPhase) (Progress, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := len(r.events) - 1; i >= 0; i-- {
+		if r.events[i].Phase == phase {
+			return r.events[i], true
+		}
+	}
+	return Progress{}, false
+}
+
+func TestUploadProgress(t *testing.T) {
+	c, _ := newTestClient(t)
+	data := testData(9<<20 + 5)
+	var rec progressRecorder
+	ctx := WithProgress(context.Background(), rec.record, time.Nanosecond)
+	if err := c.UploadFile(ctx, writeTestFile(t, data), "/apps/test/a.bin"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []TransferPhase{PhaseHashing, PhasePrecreate, PhaseUploading, PhaseCreating, PhaseDone}
+	if got := rec.phases(); !slices.Equal(got, want) {
+		t.Fatalf("phases = %v, want %v", got, want)
+	}
+	for _, phase := range []TransferPhase{PhaseHashing, PhaseUploading} {
+		p, _ := rec.last(phase)
+		if p.Transferred != int64(len(data)) || p.Total != int64(len(data)) || p.Path != "/apps/test/a.bin" {
+			t.Errorf("last %s event = %+v, want %d of %d bytes", phase, p, len(data), len(data))
+		}
+	}
+	if p, _ := rec.last(PhaseDone); p.ETA != 0 {
+		t.Errorf("done event ETA = %v, want 0", p.ETA)
+	}
+}
+
+func TestDownloadProgress(t *testing.T) {
+	c, srv := newTestClient(t)
+	data := testData(3<<20 + 1)
+	srv.AddFile("/apps/test/a.bin", data)
+	var rec progressRecorder
+	ctx := WithProgress(context.Background(), rec.record, time.Nanosecond)
+	var buf bytes.Buffer
+	if _, err := c.DownloadTo(ctx, "/apps/test/a.bin", &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	phases := rec.phases()
+	if len(phases) < 2 || phases[0] != PhaseDownloading || phases[len(phases)-1] != PhaseDone {
+		t.Fatalf("phases = %v, want downloading ... done", phases)
+	}
+	p, _ := rec.last(PhaseDownloading)
+	if p.Transferred != int64(len(data)) || p.Total != int64(len(data)) {
+		t.Errorf("last downloading event = %+v, want %d bytes", p, len(data))
+	}
+}
+
+func TestProgressInterval(t *testing.T) {
+	var rec progressRecorder
+	tracker := newProgressTracker(rec.record, time.Hour)
+	tracker.start(PhaseUploading, "/a", 100)
+	tracker.add(10)
+	tracker.add(10)
+	if n := len(rec.events); n != 1 {
+		t.Fatalf("%d events before the interval elapsed, want only the phase start", n)
+	}
+	// 达到总量时不受间隔限制
+	tracker.add(80)
+	tracker.done()
+	if n := len(rec.events); n != 3 {
+		t.Fatalf("events = %+v, want start, completion and done", rec.events)
+	}
+	if p := rec.events[1]; p.Transferred != 100 || p.AverageSpeed <= 0 {
+		t.Errorf("completion event = %+v", p)
+	}
+
+	// 未设置进度回调时 tracker 为 nil，方法均可调用
+	var none *progressTracker
+	none.start(PhaseHashing, "/a", 1)
+	none.add(1)
+	none.done()
+	if progressFromContext(WithProgress(context.Background(), nil, 0)) != nil {
+		t.Error("WithProgress(nil) installed a tracker")
+	}
+}
+
+func TestProgressChan(t *testing.T) {
+	ch := make(chan Progress, 1)
+	fn := ProgressChan(ch)
+	fn(Progress{Phase: PhaseHashing})
+	// channel 已满时丢弃事件而不阻塞
+	done := make(chan struct{})
+	go func() {
+		fn(Progress{Phase: PhaseUploading})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("ProgressChan blocked on a full channel")
+	}
+	if p := <-ch; p.Phase != PhaseHashing {
+		t.Errorf("received %s, want the first event", p.Phase)
+	}
+}
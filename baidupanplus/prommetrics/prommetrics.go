@@ -0,0 +1,124 @@
+// Package prommetrics 基于 Prometheus 客户端实现 baidupanplus.Metrics
+//
+//	m, err := prommetrics.New(prometheus.DefaultRegisterer)
+//	client := baidupanplus.NewClient(baidupanplus.Config{AccessToken: token, Metrics: m})
+package prommetrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/S-zhi/baidupansdk/baidupanplus"
+)
+
+// namespace 所有指标名的前缀
+const namespace = "baidupan"
+
+// Metrics 将 SDK 指标记录到 Prometheus 收集器
+type Metrics struct {
+	requests      *prometheus.CounterVec
+	latency       *prometheus.HistogramVec
+	errnos        *prometheus.CounterVec
+	retries       *prometheus.CounterVec
+	bytes         *prometheus.CounterVec
+	active        *prometheus.GaugeVec
+	rateLimitWait *prometheus.HistogramVec
+}
+
+var _ baidupanplus.Metrics = (*Metrics)(nil)
+
+// New 创建收集器并注册到 reg，reg 为 nil 时不注册
+func New(reg prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "api_requests_total",
+			Help:      "Number of Netdisk API requests by endpoint and HTTP status (0 for network errors).",
+		}, []string{"endpoint", "status"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "api_request_duration_seconds",
+			Help:      "Netdisk API request latency.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"endpoint"}),
+		errnos: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "api_errno_total",
+			Help:      "Number of Netdisk API responses with a non-zero errno.",
+		}, []string{"endpoint", "errno"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "api_retries_total",
+			Help:      "Number of requests retried by the SDK.",
+		}, []string{"endpoint"}),
+		bytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "transfer_bytes_total",
+			Help:      "File bytes uploaded or downloaded.",
+		}, []string{"direction"}),
+		active: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "active_transfers",
+			Help:      "Uploads and downloads in progress.",
+		}, []string{"direction"}),
+		rateLimitWait: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "rate_limit_wait_seconds",
+			Help:      "Time spent waiting for request or bandwidth rate limits.",
+			Buckets:   []float64{0, .001, .01, .05, .1, .25, .5, 1, 2.5, 5, 10},
+		}, []string{"limiter"}),
+	}
+	if reg != nil {
+		for _, c := range m.collectors() {
+			if err := reg.Register(c); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return m, nil
+}
+
+// collectors 返回全部收集器
+func (m *Metrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{m.requests, m.latency, m.errnos, m.retries, m.bytes, m.active, m.rateLimitWait}
+}
+
+// Describe 实现 prometheus.Collector，便于调用方自行注册
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	for _, c := range m.collectors() {
+		c.Describe(ch)
+	}
+}
+
+// Collect 实现 prometheus.Collector
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	for _, c := range m.collectors() {
+		c.Collect(ch)
+	}
+}
+
+func (m *Metrics) ObserveRequest(endpoint string, status int, errno int, elapsed time.Duration) {
+	m.requests.WithLabelValues(endpoint, strconv.Itoa(status)).Inc()
+	m.latency.WithLabelValues(endpoint).Observe(elapsed.Seconds())
+	if errno != 0 {
+		m.errnos.WithLabelValues(endpoint, strconv.Itoa(errno)).Inc()
+	}
+}
+
+func (m *Metrics) IncRetry(endpoint string) {
+	m.retries.WithLabelValues(endpoint).Inc()
+}
+
+func (m *Metrics) AddTransferredBytes(direction baidupanplus.TransferDirection, n int64) {
+	m.bytes.WithLabelValues(string(direction)).Add(float64(n))
+}
+
+func (m *Metrics) AddActiveTransfers(direction baidupanplus.TransferDirection, delta int) {
+	m.active.WithLabelValues(string(direction)).Add(float64(delta))
+}
+
+func (m *Metrics) ObserveRateLimitWait(limiter string, wait time.Duration) {
+	m.rateLimitWait.WithLabelValues(limiter).Observe(wait.Seconds())
+}
@@ -0,0 +1,98 @@
+package prommetrics
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/S-zhi/baidupansdk/baidupanplus"
+	"github.com/S-zhi/baidupansdk/baidupantest"
+)
+
+func TestMetricsWithClient(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	m, err := New(reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := baidupantest.NewServer()
+	defer srv.Close()
+	srv.SetAccessToken("token")
+	srv.AddFile("/apps/test/a.bin", make([]byte, 3000))
+	// 第一次下载请求输出 1000 字节后断开，SDK 续传一次
+	srv.InjectFault(baidupantest.OpDownload, baidupantest.Fault{DropAfter: 1000, Times: 1})
+	c := baidupanplus.NewClient(baidupanplus.Config{
+		AccessToken: "token",
+		HTTPClient:  srv.Client(),
+		Logger:      slog.New(slog.DiscardHandler),
+		Metrics:     m,
+	})
+
+	if _, err := c.List(context.Background(), "/apps/missing"); err == nil {
+		t.Fatal("listing a missing directory succeeded")
+	}
+	if _, err := c.DownloadTo(context.Background(), "/apps/test/a.bin", io.Discard); err != nil {
+		t.Fatalf("download: %v", err)
+	}
+
+	want := `
+# HELP baidupan_active_transfers Uploads and downloads in progress.
+# TYPE baidupan_active_transfers gauge
+baidupan_active_transfers{direction="download"} 0
+# HELP baidupan_api_errno_total Number of Netdisk API responses with a non-zero errno.
+# TYPE baidupan_api_errno_total counter
+baidupan_api_errno_total{endpoint="list",errno="-9"} 1
+# HELP baidupan_api_retries_total Number of requests retried by the SDK.
+# TYPE baidupan_api_retries_total counter
+baidupan_api_retries_total{endpoint="download"} 1
+# HELP baidupan_transfer_bytes_total File bytes uploaded or downloaded.
+# TYPE baidupan_transfer_bytes_total counter
+baidupan_transfer_bytes_total{direction="download"} 3000
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want),
+		"baidupan_active_transfers", "baidupan_api_errno_total", "baidupan_api_retries_total", "baidupan_transfer_bytes_total"); err != nil {
+		t.Error(err)
+	}
+	if n := testutil.ToFloat64(m.requests.WithLabelValues("filemetas", "200")); n != 1 {
+		t.Errorf("filemetas requests = %v, want 1", n)
+	}
+	if n := testutil.CollectAndCount(m.latency); n == 0 {
+		t.Error("no request latency recorded")
+	}
+	problems, err := testutil.GatherAndLint(reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range problems {
+		t.Errorf("lint %s: %s", p.Metric, p.Text)
+	}
+}
+
+func TestNewRegistration(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	if _, err := New(reg); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(reg); err == nil {
+		t.Error("registering twice succeeded")
+	}
+
+	// reg 为 nil 时不注册，调用方可以自行注册 Metrics 本身
+	m, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := prometheus.NewRegistry()
+	if err := other.Register(m); err != nil {
+		t.Fatalf("register as collector: %v", err)
+	}
+	m.IncRetry("upload")
+	if n := testutil.ToFloat64(m.retries.WithLabelValues("upload")); n != 1 {
+		t.Errorf("retries = %v, want 1", n)
+	}
+}
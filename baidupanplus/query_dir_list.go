@@ -29,38 +29,42 @@ type FileListResponse struct {
 //	error: 错误信息
 func QueryDirWithConfig(qConfig *QueryDirConfig) (*FileListResponse, error) {
 	if qConfig == nil {
-		Warn("QueryDir: 参数为空，调用 defaultQueryDirConfig")
+		packageLogger().Warn("QueryDir: 参数为空，调用 defaultQueryDirConfig")
 		qConfig = &defaultQueryDirConfig
 		if qConfig == nil {
-			Error("QueryDir: defaultQueryDirConfig is nil")
+			packageLogger().Error("QueryDir: defaultQueryDirConfig is nil")
 			return nil, fmt.Errorf("QueryDir: defaultQueryDirConfig is nil")
 		}
 	}
 
-	apiXpanfilelistRequest := client.FileinfoApi.Xpanfilelist(ctx).
+	c := packageClient(qConfig.Config)
+	api := c.api
+	apiXpanfilelistRequest := api.FileinfoApi.Xpanfilelist(ctx).
 		AccessToken(qConfig.AccessToken).
 		Dir(qConfig.Dir).
 		Start("0").
 		Limit(qConfig.Limit)
 
 	// SDK 返回的是原始 JSON 字符串
-	jsonStr, _, err := client.FileinfoApi.XpanfilelistExecute(apiXpanfilelistRequest)
+	jsonStr, _, err := api.FileinfoApi.XpanfilelistExecute(apiXpanfilelistRequest)
 	if err != nil {
-		Error(fmt.Sprintf("Failed to execute Xpanfilelist: %v", err))
+		c.logger.Error("failed to execute list", logKeyOp, "list", logKeyPath, qConfig.Dir, logKeyError, err)
 		return nil, err
 	}
 
 	var fileListResp FileListResponse
 	err = json.Unmarshal([]byte(jsonStr), &fileListResp)
 	if err != nil {
-		Error(fmt.Sprintf("Failed to unmarshal file list response: %v", err))
+		c.logger.Error("failed to unmarshal file list response", logKeyOp, "list", logKeyPath, qConfig.Dir, logKeyError, err)
 		return nil, err
 	}
 
 	if fileListResp.Errno != 0 {
-		return nil, fmt.Errorf("get file list failed with errno: %d", fileListResp.Errno)
+		c.logger.Error("get file list failed", logKeyOp, "list", logKeyPath, qConfig.Dir,
+			logKeyErrno, fileListResp.Errno, logKeyRequestID, fileListResp.RequestId)
+		return nil, newErrnoError("get file list", fileListResp.Errno)
 	}
 
-	Info(fmt.Sprintf("Successfully retrieved file list for: %s, count: %d", qConfig.Dir, len(fileListResp.List)))
+	c.logger.Debug("retrieved file list", logKeyOp, "list", logKeyPath, qConfig.Dir, "count", len(fileListResp.List))
 	return &fileListResp, nil
 }
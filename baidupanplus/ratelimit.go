@@ -0,0 +1,185 @@
+package baidupanplus
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// rateLimitChunk 限速读取时单次读取的最大字节数
+// 较小的粒度让限速更平滑，并使运行时调整的速率尽快生效
+const rateLimitChunk = 32 * 1024
+
+// RateLimiter 令牌桶带宽限速器，单位为 字节/秒
+// 同一个 RateLimiter 可被多个并发传输共享，此时它们的总带宽受限；速率可在运行时通过 SetLimit 调整
+type RateLimiter struct {
+	mu     sync.Mutex
+	limit  int64 // 字节/秒，<= 0 表示不限速
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter 创建限速器，bytesPerSec <= 0 表示不限速
+func NewRateLimiter(bytesPerSec int64) *RateLimiter {
+	l := &RateLimiter{}
+	l.SetLimit(bytesPerSec)
+	return l
+}
+
+// SetLimit 调整限速速率，bytesPerSec <= 0 表示不限速
+func (l *RateLimiter) SetLimit(bytesPerSec int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit = bytesPerSec
+	// 桶容量取 1/4 秒的流量，且不小于单次读取粒度
+	l.burst = float64(bytesPerSec) / 4
+	if l.burst < rateLimitChunk {
+		l.burst = rateLimitChunk
+	}
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = time.Now()
+}
+
+// Limit 返回当前速率，<= 0 表示不限速
+func (l *RateLimiter) Limit() int64 {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
+
+// WaitN 消耗 n 个字节的令牌，令牌不足时阻塞直到补足或 ctx 结束
+// 令牌允许透支：先消耗再等待，保证大块读取也能按平均速率放行
+func (l *RateLimiter) WaitN(ctx context.Context, n int) error {
+	return sleepContext(ctx, l.reserve(n))
+}
+
+// reserve 消耗 n 个字节的令牌，返回需要等待的时间
+func (l *RateLimiter) reserve(n int) time.Duration {
+	if l == nil || n <= 0 {
+		return 0
+	}
+	l.mu.Lock()
+	if l.limit <= 0 {
+		l.mu.Unlock()
+		return 0
+	}
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * float64(l.limit)
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / float64(l.limit) * float64(time.Second))
+	}
+	l.mu.Unlock()
+	return wait
+}
+
+// sleepContext 等待 d 或直到 ctx 结束
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type rateLimiterKey struct{}
+
+// WithRateLimiter 返回携带单次传输限速器的 context
+// 单次传输限速与 Client 级别的限速同时生效，取两者中更严格的一方
+func WithRateLimiter(ctx context.Context, l *RateLimiter) context.Context {
+	if l == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, rateLimiterKey{}, l)
+}
+
+// rateLimiterFromContext 取出 context 中的单次传输限速器，未设置时返回 nil
+func rateLimiterFromContext(ctx context.Context) *RateLimiter {
+	l, _ := ctx.Value(rateLimiterKey{}).(*RateLimiter)
+	return l
+}
+
+// limitReader 包装 r，读取时依次受 limiters 中所有非 nil 限速器约束，每次需要等待时调用 onWait
+func limitReader(ctx context.Context, r io.Reader, onWait func(time.Duration), limiters ...*RateLimiter) io.Reader {
+	active := activeLimiters(limiters)
+	if len(active) == 0 {
+		return r
+	}
+	return &rateLimitedReader{ctx: ctx, r: r, limiters: active, onWait: onWait}
+}
+
+// limitReadCloser 与 limitReader 相同，但保留 Close
+func limitReadCloser(ctx context.Context, rc io.ReadCloser, onWait func(time.Duration), limiters ...*RateLimiter) io.ReadCloser {
+	active := activeLimiters(limiters)
+	if len(active) == 0 {
+		return rc
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{&rateLimitedReader{ctx: ctx, r: rc, limiters: active, onWait: onWait}, rc}
+}
+
+// activeLimiters 过滤掉 nil 限速器
+func activeLimiters(limiters []*RateLimiter) []*RateLimiter {
+	var active []*RateLimiter
+	for _, l := range limiters {
+		if l != nil {
+			active = append(active, l)
+		}
+	}
+	return active
+}
+
+// rateLimitedReader 受限速器约束的 io.Reader
+type rateLimitedReader struct {
+	ctx      context.Context
+	r        io.Reader
+	limiters []*RateLimiter
+	onWait   func(time.Duration)
+}
+
+func (lr *rateLimitedReader) Read(p []byte) (int, error) {
+	if len(p) > rateLimitChunk {
+		p = p[:rateLimitChunk]
+	}
+	n, err := lr.r.Read(p)
+	for _, l := range lr.limiters {
+		wait := l.reserve(n)
+		if wait > 0 && lr.onWait != nil {
+			lr.onWait(wait)
+		}
+		if waitErr := sleepContext(lr.ctx, wait); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+// SetUploadRateLimit 调整客户端上传总带宽（字节/秒），对进行中的传输立即生效，<= 0 表示不限速
+func (c *Client) SetUploadRateLimit(bytesPerSec int64) {
+	c.uploadLimiter.SetLimit(bytesPerSec)
+}
+
+// SetDownloadRateLimit 调整客户端下载总带宽（字节/秒），对进行中的传输立即生效，<= 0 表示不限速
+func (c *Client) SetDownloadRateLimit(bytesPerSec int64) {
+	c.downloadLimiter.SetLimit(bytesPerSec)
+}
@@ -0,0 +1,124 @@
+package baidupanplus
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterReserve(t *testing.T) {
+	l := NewRateLimiter(1 << 20)
+	// 桶初始为空，令牌可以透支：等待时间按累计欠下的字节数计算
+	if wait := l.reserve(512 << 10); wait < 490*time.Millisecond || wait > 500*time.Millisecond {
+		t.Errorf("first wait = %v, want about 500ms", wait)
+	}
+	if wait := l.reserve(512 << 10); wait < 990*time.Millisecond || wait > time.Second {
+		t.Errorf("second wait = %v, want about 1s", wait)
+	}
+
+	// 空闲后积累的令牌不超过桶容量（1/4 秒的流量）
+	l.mu.Lock()
+	l.tokens = 0
+	l.last = time.Now().Add(-10 * time.Second)
+	l.mu.Unlock()
+	if wait := l.reserve(256 << 10); wait != 0 {
+		t.Errorf("wait with a full bucket = %v, want 0", wait)
+	}
+	if wait := l.reserve(256 << 10); wait < 240*time.Millisecond {
+		t.Errorf("wait after draining the bucket = %v, want about 250ms", wait)
+	}
+
+	// 运行时调整为不限速后立即生效
+	l.SetLimit(0)
+	if wait := l.reserve(10 << 20); wait != 0 || l.Limit() != 0 {
+		t.Errorf("unlimited wait = %v, limit = %d", wait, l.Limit())
+	}
+	var none *RateLimiter
+	if wait := none.reserve(1); wait != 0 || none.Limit() != 0 {
+		t.Error("nil limiter limited the transfer")
+	}
+}
+
+func TestRateLimiterWaitHonoursContext(t *testing.T) {
+	l := NewRateLimiter(1024)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := l.WaitN(ctx, 1<<20); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("WaitN = %v, want deadline exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("WaitN returned after %v", elapsed)
+	}
+}
+
+func TestRateLimitedTransfers(t *testing.T) {
+	data := testData(512 << 10)
+
+	t.Run("client download", func(t *testing.T) {
+		_, srv := newTestClient(t)
+		cfg := newTestConfig(srv)
+		cfg.DownloadRateLimit = 512 << 10
+		c := NewClient(cfg)
+		srv.AddFile("/apps/test/a.bin", data)
+		start := time.Now()
+		var buf bytes.Buffer
+		if _, err := c.DownloadTo(context.Background(), "/apps/test/a.bin", &buf); err != nil {
+			t.Fatal(err)
+		}
+		if elapsed := time.Since(start); elapsed < 800*time.Millisecond {
+			t.Errorf("512KB at 512KB/s took %v, want about 1s", elapsed)
+		}
+		if !bytes.Equal(buf.Bytes(), data) {
+			t.Error("content differs")
+		}
+	})
+
+	t.Run("client upload", func(t *testing.T) {
+		c, srv := newTestClient(t)
+		c.SetUploadRateLimit(512 << 10)
+		start := time.Now()
+		if err := c.UploadReader(context.Background(), bytes.NewReader(data), int64(len(data)), "/apps/test/a.bin"); err != nil {
+			t.Fatal(err)
+		}
+		if elapsed := time.Since(start); elapsed < 800*time.Millisecond {
+			t.Errorf("512KB at 512KB/s took %v, want about 1s", elapsed)
+		}
+		if got, _ := srv.ReadFile("/apps/test/a.bin"); !bytes.Equal(got, data) {
+			t.Error("content differs")
+		}
+	})
+
+	t.Run("shared per-transfer limiter", func(t *testing.T) {
+		c, srv := newTestClient(t)
+		srv.AddFile("/apps/test/a.bin", data[:256<<10])
+		srv.AddFile("/apps/test/b.bin", data[256<<10:])
+		// 两个并发下载共享 512KB/s，合计 512KB 约需 1 秒
+		ctx := WithRateLimiter(context.Background(), NewRateLimiter(512<<10))
+		start := time.Now()
+		var wg sync.WaitGroup
+		errs := make(chan error, 2)
+		for _, p := range []string{"/apps/test/a.bin", "/apps/test/b.bin"} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := c.DownloadTo(ctx, p, io.Discard)
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if elapsed := time.Since(start); elapsed < 800*time.Millisecond {
+			t.Errorf("two 256KB downloads sharing 512KB/s took %v, want about 1s", elapsed)
+		}
+	})
+}
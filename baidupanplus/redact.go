@@ -0,0 +1,78 @@
+package baidupanplus
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/S-zhi/baidupansdk/internal/redact"
+)
+
+// redactHandler 在输出前屏蔽日志消息与字段中的 access_token、refresh_token、client_secret、sign 与 secret_key
+type redactHandler struct {
+	inner slog.Handler
+}
+
+// redactLogger 为 l 加上敏感信息屏蔽，nil 或已屏蔽的 Logger 原样返回
+func redactLogger(l *slog.Logger) *slog.Logger {
+	if l == nil {
+		return nil
+	}
+	if _, ok := l.Handler().(redactHandler); ok {
+		return l
+	}
+	return slog.New(redactHandler{inner: l.Handler()})
+}
+
+func (h redactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+func (h redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	redacted := slog.NewRecord(r.Time, r.Level, redact.String(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		redacted.AddAttrs(redactAttr(a))
+		return true
+	})
+	return h.inner.Handle(ctx, redacted)
+}
+
+func (h redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = redactAttr(a)
+	}
+	return redactHandler{inner: h.inner.WithAttrs(redacted)}
+}
+
+func (h redactHandler) WithGroup(name string) slog.Handler {
+	return redactHandler{inner: h.inner.WithGroup(name)}
+}
+
+// redactAttr 屏蔽单个字段：敏感字段名整体屏蔽，字符串、错误及其它值按内容屏蔽
+func redactAttr(a slog.Attr) slog.Attr {
+	if redact.Key(a.Key) {
+		return slog.String(a.Key, redact.Mask)
+	}
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, redact.String(v.String()))
+	case slog.KindGroup:
+		group := v.Group()
+		redacted := make([]slog.Attr, len(group))
+		for i, ga := range group {
+			redacted[i] = redactAttr(ga)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(redacted...)}
+	case slog.KindAny:
+		if err, ok := v.Any().(error); ok {
+			return slog.Any(a.Key, redact.Error(err))
+		}
+		s := fmt.Sprint(v.Any())
+		if redacted := redact.String(s); redacted != s {
+			return slog.String(a.Key, redacted)
+		}
+	}
+	return slog.Attr{Key: a.Key, Value: v}
+}
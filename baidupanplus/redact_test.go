@@ -0,0 +1,78 @@
+package baidupanplus
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRedactHandler(t *testing.T) {
+	tests := []struct {
+		name string
+		log  func(l *slog.Logger)
+		want string
+	}{
+		{"message", func(l *slog.Logger) {
+			l.Info("GET /file?access_token=s3cr3t1&method=list")
+		}, `msg="GET /file?access_token=***&method=list"`},
+		{"sensitive key", func(l *slog.Logger) {
+			l.Info("m", "access_token", "s3cr3t1", "refresh_token", "s3cr3t2", "client_secret", "s3cr3t3", "sign", "s3cr3t4", "secret_key", "s3cr3t5")
+		}, "access_token=*** refresh_token=*** client_secret=*** sign=*** secret_key=***"},
+		{"string value", func(l *slog.Logger) {
+			l.Info("m", "url", "https://d.pcs.baidu.com/file/x?sign=s3cr3t1&expires=8h")
+		}, `url="https://d.pcs.baidu.com/file/x?sign=***&expires=8h"`},
+		{"json value", func(l *slog.Logger) {
+			l.Info("m", "body", `{"access_token":"s3cr3t1","secret_key":"s3cr3t2"}`)
+		}, `body="{\"access_token\":\"***\",\"secret_key\":\"***\"}"`},
+		{"error value", func(l *slog.Logger) {
+			l.Info("m", logKeyError, &url.Error{Op: "Get", URL: "https://x/?refresh_token=s3cr3t1", Err: errors.New("EOF")})
+		}, `error="Get \"https://x/?refresh_token=***\": EOF"`},
+		{"stringer value", func(l *slog.Logger) {
+			l.Info("m", "req", fmt.Stringer(stringer("client_secret=s3cr3t1")))
+		}, `req="client_secret=***"`},
+		{"group", func(l *slog.Logger) {
+			l.Info("m", slog.Group("oauth", "client_secret", "s3cr3t1", "url", "/token?refresh_token=s3cr3t2"))
+		}, `oauth.client_secret=*** oauth.url="/token?refresh_token=***"`},
+		{"with attrs", func(l *slog.Logger) {
+			l.With("secret_key", "s3cr3t1", "url", "?access_token=s3cr3t2").Info("m")
+		}, `secret_key=*** url="?access_token=***"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tt.log(redactLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+				ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+					if a.Key == slog.TimeKey || a.Key == slog.LevelKey {
+						return slog.Attr{}
+					}
+					return a
+				},
+			}))))
+			out := buf.String()
+			if !strings.Contains(out, tt.want) {
+				t.Errorf("output %q does not contain %q", out, tt.want)
+			}
+			if strings.Contains(out, "s3cr3t") {
+				t.Errorf("output leaks a token: %q", out)
+			}
+		})
+	}
+}
+
+func TestRedactLoggerIdempotent(t *testing.T) {
+	l := redactLogger(slog.New(slog.DiscardHandler))
+	if redactLogger(l) != l {
+		t.Error("redactLogger wrapped an already redacting logger")
+	}
+	if redactLogger(nil) != nil {
+		t.Error("redactLogger(nil) != nil")
+	}
+}
+
+type stringer string
+
+func (s stringer) String() string { return string(s) }
@@ -0,0 +1,470 @@
+package s3gw
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// sigV4Algorithm AWS Signature V4 的算法名
+	sigV4Algorithm = "AWS4-HMAC-SHA256"
+	// amzDateFormat X-Amz-Date 的时间格式
+	amzDateFormat = "20060102T150405Z"
+	// maxClockSkew 允许的请求时间与服务器时间之差
+	maxClockSkew = 15 * time.Minute
+	// unsignedPayload 未对请求体签名时 x-amz-content-sha256 的取值
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+	// streamingPayload aws-chunked 编码且每块带签名时 x-amz-content-sha256 的取值
+	streamingPayload = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	// streamingUnsignedPayload aws-chunked 编码且分块不带签名时 x-amz-content-sha256 的取值
+	streamingUnsignedPayload = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+	// chunkAlgorithm 分块签名的算法名
+	chunkAlgorithm = "AWS4-HMAC-SHA256-PAYLOAD"
+	// emptySHA256 空数据的 SHA-256
+	emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	// maxSignedChunkSize 带签名的分块在校验前需整块缓存，超过此大小的分块视为格式错误
+	maxSignedChunkSize = 16 << 20
+)
+
+// sigV4Request 从 Authorization 头或预签名 URL 中解析出的签名参数
+type sigV4Request struct {
+	accessKey     string
+	date          string // 凭证范围中的日期，如 20240101
+	region        string
+	service       string
+	signedHeaders []string
+	signature     string
+	amzDate       string
+	payloadHash   string
+	presigned     bool
+	expires       time.Duration
+}
+
+// authenticate 校验请求的 AWS Signature V4 签名，支持 Authorization 头与预签名 URL
+// 签名通过后由 verifyBody 替换 r.Body，读取请求体时再校验数据与签名中声明的一致
+func (h *Handler) authenticate(r *http.Request) error {
+	if h.access == "" && h.secret == "" {
+		return verifyBody(r, r.Header.Get("X-Amz-Content-Sha256"), nil)
+	}
+	req, err := parseSigV4(r)
+	if err != nil {
+		return err
+	}
+	if req.accessKey != h.access {
+		return errInvalidAccessKeyID
+	}
+	t, err := time.Parse(amzDateFormat, req.amzDate)
+	if err != nil || !strings.HasPrefix(req.amzDate, req.date) {
+		return errAccessDenied
+	}
+	now := h.now()
+	if req.presigned {
+		if now.Before(t.Add(-maxClockSkew)) || now.After(t.Add(req.expires)) {
+			return errAccessDenied
+		}
+	} else if d := now.Sub(t); d > maxClockSkew || d < -maxClockSkew {
+		return errRequestTimeSkewed
+	}
+
+	canonical := canonicalRequest(r, req)
+	scope := strings.Join([]string{req.date, req.region, req.service, "aws4_request"}, "/")
+	canonicalHash := sha256.Sum256([]byte(canonical))
+	stringToSign := sigV4Algorithm + "\n" + req.amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+	key := hmacSHA256([]byte("AWS4"+h.secret), req.date)
+	key = hmacSHA256(key, req.region)
+	key = hmacSHA256(key, req.service)
+	key = hmacSHA256(key, "aws4_request")
+	expected := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	if !hmac.Equal([]byte(expected), []byte(req.signature)) {
+		return errSignatureMismatch
+	}
+	return verifyBody(r, req.payloadHash, &chunkSigner{key: key, amzDate: req.amzDate, scope: scope, prev: req.signature})
+}
+
+// verifyBody 按 x-amz-content-sha256 的取值包装 r.Body：
+// 十六进制 SHA-256 在读到结尾时比对摘要，STREAMING-AWS4-HMAC-SHA256-PAYLOAD 逐块校验签名链。
+// signer 为 nil 表示未配置密钥，此时分块签名无法校验，只按未签名的 aws-chunked 解码
+func verifyBody(r *http.Request, payloadHash string, signer *chunkSigner) error {
+	switch {
+	case payloadHash == "" || payloadHash == unsignedPayload || payloadHash == streamingUnsignedPayload:
+		return nil
+	case payloadHash == streamingPayload:
+		if signer != nil {
+			r.Body = &chunkedReader{r: bufio.NewReader(r.Body), Closer: r.Body, signer: signer}
+		}
+		return nil
+	case strings.HasPrefix(payloadHash, "STREAMING-"):
+		// 带 trailer 签名或 ECDSA 签名的流式上传
+		if signer != nil {
+			return errNotImplemented
+		}
+		return nil
+	case isSHA256Hex(payloadHash):
+		r.Body = &payloadVerifier{r: r.Body, hash: sha256.New(), want: payloadHash, size: r.ContentLength}
+		return nil
+	default:
+		return errInvalidContentSHA256
+	}
+}
+
+// isSHA256Hex 判断 s 是否为小写十六进制的 SHA-256
+func isSHA256Hex(s string) bool {
+	if len(s) != 2*sha256.Size {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// payloadVerifier 读取请求体的同时计算 SHA-256，读完声明的长度或读到结尾时与 want 比对。
+// 不一致时最后一次读取不返回数据，只返回 errContentSHA256Mismatch，
+// 避免 io.ReadFull 等读满缓冲区后忽略错误的调用方把数据当作完整的请求体
+type payloadVerifier struct {
+	r        io.ReadCloser
+	hash     hash.Hash
+	want     string
+	size     int64 // 声明的长度，未知时为 -1
+	read     int64
+	verified bool
+	err      error
+}
+
+// Read 实现 io.Reader
+func (v *payloadVerifier) Read(p []byte) (int, error) {
+	if v.err != nil {
+		return 0, v.err
+	}
+	n, err := v.r.Read(p)
+	v.hash.Write(p[:n])
+	v.read += int64(n)
+	if !v.verified && (errors.Is(err, io.EOF) || v.size >= 0 && v.read >= v.size) {
+		v.verified = true
+		if hex.EncodeToString(v.hash.Sum(nil)) != v.want {
+			v.err = errContentSHA256Mismatch
+			return 0, v.err
+		}
+	}
+	return n, err
+}
+
+// Close 实现 io.Closer
+func (v *payloadVerifier) Close() error {
+	return v.r.Close()
+}
+
+// chunkSigner 校验 aws-chunked 的分块签名链：每块的签名以上一块的签名为输入，第一块以请求签名为输入
+type chunkSigner struct {
+	key     []byte
+	amzDate string
+	scope   string
+	prev    string
+}
+
+// verify 校验一块数据的签名，通过后将其作为下一块的前一个签名
+func (s *chunkSigner) verify(signature string, data []byte) bool {
+	dataHash := sha256.Sum256(data)
+	stringToSign := strings.Join([]string{chunkAlgorithm, s.amzDate, s.scope, s.prev, emptySHA256, hex.EncodeToString(dataHash[:])}, "\n")
+	expected := hex.EncodeToString(hmacSHA256(s.key, stringToSign))
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return false
+	}
+	s.prev = signature
+	return true
+}
+
+// parseSigV4 解析签名参数，请求未签名时返回 errAccessDenied
+func parseSigV4(r *http.Request) (*sigV4Request, error) {
+	q := r.URL.Query()
+	if q.Get("X-Amz-Algorithm") != "" {
+		if q.Get("X-Amz-Algorithm") != sigV4Algorithm {
+			return nil, errAccessDenied
+		}
+		expires, err := strconv.Atoi(q.Get("X-Amz-Expires"))
+		if err != nil || expires < 0 {
+			return nil, errAccessDenied
+		}
+		req := &sigV4Request{
+			signedHeaders: strings.Split(q.Get("X-Amz-SignedHeaders"), ";"),
+			signature:     q.Get("X-Amz-Signature"),
+			amzDate:       q.Get("X-Amz-Date"),
+			payloadHash:   unsignedPayload,
+			presigned:     true,
+			expires:       time.Duration(expires) * time.Second,
+		}
+		if !req.parseCredential(q.Get("X-Amz-Credential")) {
+			return nil, errAccessDenied
+		}
+		return req, nil
+	}
+
+	auth, ok := strings.CutPrefix(r.Header.Get("Authorization"), sigV4Algorithm+" ")
+	if !ok {
+		return nil, errAccessDenied
+	}
+	req := &sigV4Request{
+		amzDate:     r.Header.Get("X-Amz-Date"),
+		payloadHash: r.Header.Get("X-Amz-Content-Sha256"),
+	}
+	if req.payloadHash == "" {
+		req.payloadHash = unsignedPayload
+	}
+	var credential string
+	for _, field := range strings.Split(auth, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(field), "=")
+		switch name {
+		case "Credential":
+			credential = value
+		case "SignedHeaders":
+			req.signedHeaders = strings.Split(value, ";")
+		case "Signature":
+			req.signature = value
+		}
+	}
+	if !req.parseCredential(credential) || req.signature == "" || len(req.signedHeaders) == 0 {
+		return nil, errAccessDenied
+	}
+	return req, nil
+}
+
+// parseCredential 解析 AccessKey/日期/区域/服务/aws4_request 形式的凭证
+func (req *sigV4Request) parseCredential(credential string) bool {
+	parts := strings.Split(credential, "/")
+	if len(parts) != 5 || parts[4] != "aws4_request" {
+		return false
+	}
+	req.accessKey, req.date, req.region, req.service = parts[0], parts[1], parts[2], parts[3]
+	return true
+}
+
+// canonicalRequest 按 Signature V4 的规则构造规范请求
+func canonicalRequest(r *http.Request, req *sigV4Request) string {
+	uri := uriEncode(r.URL.Path, false)
+	if uri == "" {
+		uri = "/"
+	}
+	var headers strings.Builder
+	for _, name := range req.signedHeaders {
+		var values []string
+		switch name {
+		case "host":
+			values = []string{r.Host}
+		case "content-length":
+			values = r.Header.Values("Content-Length")
+			if len(values) == 0 {
+				values = []string{strconv.FormatInt(r.ContentLength, 10)}
+			}
+		case "transfer-encoding":
+			values = r.TransferEncoding
+		default:
+			values = r.Header.Values(name)
+		}
+		trimmed := make([]string, len(values))
+		for i, v := range values {
+			trimmed[i] = strings.Join(strings.Fields(v), " ")
+		}
+		headers.WriteString(name + ":" + strings.Join(trimmed, ",") + "\n")
+	}
+	return strings.Join([]string{
+		r.Method,
+		uri,
+		canonicalQuery(r.URL.RawQuery, req.presigned),
+		headers.String(),
+		strings.Join(req.signedHeaders, ";"),
+		req.payloadHash,
+	}, "\n")
+}
+
+// canonicalQuery 按键、值排序并重新编码查询参数，预签名请求不包含 X-Amz-Signature
+func canonicalQuery(rawQuery string, presigned bool) string {
+	var pairs []string
+	for _, field := range strings.Split(rawQuery, "&") {
+		if field == "" {
+			continue
+		}
+		k, v, _ := strings.Cut(field, "=")
+		key, err1 := url.QueryUnescape(k)
+		value, err2 := url.QueryUnescape(v)
+		if err1 != nil || err2 != nil {
+			key, value = k, v
+		}
+		if presigned && key == "X-Amz-Signature" {
+			continue
+		}
+		pairs = append(pairs, uriEncode(key, true)+"="+uriEncode(value, true))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode 按 RFC 3986 编码，只保留非保留字符；encodeSlash 为 false 时保留 "/"
+func uriEncode(s string, encodeSlash bool) string {
+	const hexDigits = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			b.WriteByte('%')
+			b.WriteByte(hexDigits[c>>4])
+			b.WriteByte(hexDigits[c&15])
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// requestBody 返回请求体的原始数据与长度，长度未知时为 -1
+// 客户端以 aws-chunked 编码发送时（如 minio-go 在 HTTP 下的流式签名）去掉分块格式，
+// 长度取自 x-amz-decoded-content-length；authenticate 已替换为校验签名的解码器时直接使用
+func requestBody(r *http.Request) (io.Reader, int64, error) {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") &&
+		!strings.Contains(r.Header.Get("Content-Encoding"), "aws-chunked") {
+		return r.Body, r.ContentLength, nil
+	}
+	size := int64(-1)
+	if s := r.Header.Get("X-Amz-Decoded-Content-Length"); s != "" {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || n < 0 {
+			return nil, 0, errMissingLength
+		}
+		size = n
+	}
+	if body, ok := r.Body.(*chunkedReader); ok {
+		return body, size, nil
+	}
+	return &chunkedReader{r: bufio.NewReader(r.Body), Closer: r.Body}, size, nil
+}
+
+// errMalformedChunk aws-chunked 请求体格式错误
+var errMalformedChunk = &apiError{"IncompleteBody", "The aws-chunked request body is malformed.", http.StatusBadRequest}
+
+// chunkedReader 解码 aws-chunked 格式：每块为 "十六进制长度[;chunk-signature=...]\r\n数据\r\n"，
+// 以长度为 0 的块结束，其后可带 trailer 与空行。
+// signer 不为空时整块读入并校验签名后才返回其中的数据，包括最后长度为 0 的块
+type chunkedReader struct {
+	r *bufio.Reader
+	io.Closer
+	signer  *chunkSigner
+	n       int64 // 当前块剩余的字节数
+	chunk   []byte
+	buf     []byte // 已校验、尚未返回的数据
+	started bool
+	err     error
+}
+
+// Read 实现 io.Reader
+func (c *chunkedReader) Read(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	if c.n == 0 {
+		if err := c.nextChunk(); err != nil {
+			c.err = err
+			return 0, err
+		}
+	}
+	if c.signer != nil {
+		n := copy(p, c.buf)
+		c.buf = c.buf[n:]
+		c.n -= int64(n)
+		if c.n == 0 {
+			// 立即读取并校验下一块，保证最后一个字节返回前整条签名链已校验完毕
+			if err := c.nextChunk(); err != nil {
+				c.err = err
+				if !errors.Is(err, io.EOF) {
+					return 0, err
+				}
+			}
+		}
+		return n, nil
+	}
+	if int64(len(p)) > c.n {
+		p = p[:c.n]
+	}
+	n, err := c.r.Read(p)
+	c.n -= int64(n)
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		c.err = err
+	}
+	return n, err
+}
+
+// nextChunk 读取下一块的长度，最后一块时跳过 trailer 并返回 io.EOF
+func (c *chunkedReader) nextChunk() error {
+	if c.started {
+		if line, err := c.readLine(); err != nil || line != "" {
+			return errMalformedChunk
+		}
+	}
+	c.started = true
+	line, err := c.readLine()
+	if err != nil {
+		return errMalformedChunk
+	}
+	sizeField, ext, _ := strings.Cut(line, ";")
+	n, err := strconv.ParseInt(strings.TrimSpace(sizeField), 16, 64)
+	if err != nil || n < 0 {
+		return errMalformedChunk
+	}
+	if c.signer != nil {
+		signature, ok := strings.CutPrefix(strings.TrimSpace(ext), "chunk-signature=")
+		if !ok || n > maxSignedChunkSize {
+			return errMalformedChunk
+		}
+		if int64(cap(c.chunk)) < n {
+			c.chunk = make([]byte, n)
+		}
+		c.buf = c.chunk[:n]
+		if _, err := io.ReadFull(c.r, c.buf); err != nil {
+			return errMalformedChunk
+		}
+		if !c.signer.verify(signature, c.buf) {
+			return errSignatureMismatch
+		}
+	}
+	if n > 0 {
+		c.n = n
+		return nil
+	}
+	for {
+		line, err := c.readLine()
+		if err != nil || line == "" {
+			return io.EOF
+		}
+	}
+}
+
+// readLine 读取一行并去掉行尾的 CRLF，行过长时返回错误
+func (c *chunkedReader) readLine() (string, error) {
+	line, err := c.r.ReadSlice('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(line), "\r\n"), nil
+}
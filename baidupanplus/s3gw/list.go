@@ -0,0 +1,232 @@
+package s3gw
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/S-zhi/baidupansdk/baidupanplus"
+)
+
+// maxListKeys ListObjectsV2 单页最多返回的条目数
+const maxListKeys = 1000
+
+// errListDone 已收集到足够的条目，停止遍历
+var errListDone = errors.New("list done")
+
+// listObjectsV2 处理 ListObjectsV2
+func (h *Handler) listObjectsV2(w http.ResponseWriter, r *http.Request, bucket string, root string) {
+	q := r.URL.Query()
+	maxKeys := maxListKeys
+	if s := q.Get("max-keys"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			h.writeError(w, r, errInvalidArgument)
+			return
+		}
+		maxKeys = min(n, maxListKeys)
+	}
+	after := q.Get("start-after")
+	token := q.Get("continuation-token")
+	if token != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(token)
+		if err != nil {
+			h.writeError(w, r, errInvalidArgument)
+			return
+		}
+		after = string(decoded)
+	}
+
+	l := &lister{
+		client:    h.client,
+		prefix:    q.Get("prefix"),
+		delimiter: q.Get("delimiter"),
+		after:     after,
+		limit:     maxKeys + 1,
+	}
+	if err := l.run(r.Context(), root); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	result := listBucketResultV2{
+		Name:              bucket,
+		Prefix:            l.prefix,
+		Delimiter:         l.delimiter,
+		StartAfter:        q.Get("start-after"),
+		ContinuationToken: token,
+		MaxKeys:           maxKeys,
+	}
+	entries := l.entries
+	if len(entries) > maxKeys {
+		entries = entries[:maxKeys]
+		result.IsTruncated = true
+		result.NextContinuationToken = base64.RawURLEncoding.EncodeToString([]byte(entries[len(entries)-1].key))
+	}
+	for _, e := range entries {
+		if e.info == nil {
+			result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{Prefix: e.key})
+			continue
+		}
+		result.Contents = append(result.Contents, objectEntry{
+			Key:          e.key,
+			LastModified: formatTime(e.info.ModTime()),
+			ETag:         objectETag(e.info),
+			Size:         e.info.Size,
+			StorageClass: "STANDARD",
+		})
+	}
+	result.KeyCount = len(entries)
+	writeXML(w, http.StatusOK, result)
+}
+
+// listEntry 列表中的一项，info 为空时表示 CommonPrefix
+type listEntry struct {
+	key  string
+	info *baidupanplus.FileInfo
+}
+
+// lister 按键的字典序深度优先遍历网盘目录，收集 prefix 下、after 之后的至多 limit 项
+// 每个目录的子项按键排序（目录的键带 "/" 后缀），因此遍历顺序就是全局的键顺序；
+// 不可能包含匹配项的目录、以及全部键都不大于 after 的目录不会被列出
+type lister struct {
+	client    *baidupanplus.Client
+	prefix    string
+	delimiter string
+	after     string
+	limit     int
+
+	entries []listEntry
+}
+
+// run 从 prefix 所在的最深一级目录开始遍历
+func (l *lister) run(ctx context.Context, root string) error {
+	if l.limit <= 1 {
+		return nil
+	}
+	dirKey := l.prefix[:strings.LastIndex(l.prefix, "/")+1]
+	err := l.walk(ctx, path.Join(root, dirKey), dirKey)
+	if errors.Is(err, errListDone) {
+		return nil
+	}
+	return err
+}
+
+// walk 遍历目录 dir，dirKey 为该目录对应的键前缀（以 "/" 结尾，根目录为空）
+func (l *lister) walk(ctx context.Context, dir string, dirKey string) error {
+	files, err := l.client.List(ctx, dir)
+	if baidupanplus.CategoryOf(err) == baidupanplus.CategoryNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	type child struct {
+		key  string
+		info *baidupanplus.FileInfo
+	}
+	children := make([]child, 0, len(files))
+	for i := range files {
+		key := dirKey + files[i].ServerFilename
+		if files[i].IsDir() {
+			key += "/"
+		}
+		children = append(children, child{key: key, info: &files[i]})
+	}
+	slices.SortFunc(children, func(a, b child) int { return strings.Compare(a.key, b.key) })
+
+	for _, c := range children {
+		if !c.info.IsDir() {
+			if strings.HasPrefix(c.key, l.prefix) {
+				if err := l.add(c.key, c.info); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		switch {
+		case strings.HasPrefix(c.key, l.prefix):
+			// 分隔符已出现在目录键中时，目录下的全部键归入同一个 CommonPrefix，无需列出
+			if p, ok := l.commonPrefix(c.key); ok {
+				if err := l.add(p, nil); err != nil {
+					return err
+				}
+				continue
+			}
+		case !strings.HasPrefix(l.prefix, c.key):
+			continue
+		}
+		if c.key < l.after && !strings.HasPrefix(l.after, c.key) {
+			continue
+		}
+		if err := l.walk(ctx, c.info.Path, c.key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// commonPrefix 返回 key 在 prefix 之后第一次出现分隔符处截断的前缀
+func (l *lister) commonPrefix(key string) (string, bool) {
+	if l.delimiter == "" {
+		return "", false
+	}
+	i := strings.Index(key[len(l.prefix):], l.delimiter)
+	if i < 0 {
+		return "", false
+	}
+	return key[:len(l.prefix)+i+len(l.delimiter)], true
+}
+
+// add 记录一个对象或 CommonPrefix，收集满 limit 项时返回 errListDone
+func (l *lister) add(key string, info *baidupanplus.FileInfo) error {
+	if info != nil {
+		if p, ok := l.commonPrefix(key); ok {
+			key, info = p, nil
+		}
+	}
+	if key <= l.after {
+		return nil
+	}
+	if n := len(l.entries); n > 0 && l.entries[n-1].info == nil && l.entries[n-1].key == key {
+		return nil
+	}
+	l.entries = append(l.entries, listEntry{key: key, info: info})
+	if len(l.entries) >= l.limit {
+		return errListDone
+	}
+	return nil
+}
+
+type listBucketResultV2 struct {
+	XMLName               xml.Name       `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBucketResult"`
+	Name                  string         `xml:"Name"`
+	Prefix                string         `xml:"Prefix"`
+	Delimiter             string         `xml:"Delimiter,omitempty"`
+	StartAfter            string         `xml:"StartAfter,omitempty"`
+	ContinuationToken     string         `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string         `xml:"NextContinuationToken,omitempty"`
+	KeyCount              int            `xml:"KeyCount"`
+	MaxKeys               int            `xml:"MaxKeys"`
+	IsTruncated           bool           `xml:"IsTruncated"`
+	Contents              []objectEntry  `xml:"Contents"`
+	CommonPrefixes        []commonPrefix `xml:"CommonPrefixes"`
+}
+
+type objectEntry struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+type commonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
@@ -0,0 +1,298 @@
+package s3gw
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/S-zhi/baidupansdk/baidupanplus"
+)
+
+// maxPartNumber 分片编号的上限，与 S3 一致
+const maxPartNumber = 10000
+
+// multipartUpload 进行中的分片上传
+// S3 的分片大小由客户端决定，与网盘固定的分片大小无法对齐，因此分片先暂存在本地，
+// Complete 时按顺序拼接后通过 precreate / superfile2 / create 上传
+type multipartUpload struct {
+	bucket     string
+	key        string
+	remotePath string
+	dir        string // 暂存分片的本地目录
+
+	mu         sync.Mutex
+	parts      map[int]uploadedPart
+	completing bool
+}
+
+// uploadedPart 已暂存的分片
+type uploadedPart struct {
+	path string
+	size int64
+	etag string // 分片数据的 MD5（十六进制，不含引号）
+}
+
+// createMultipartUpload 处理 CreateMultipartUpload
+func (h *Handler) createMultipartUpload(w http.ResponseWriter, r *http.Request, bucket string, key string, remotePath string) {
+	if strings.HasSuffix(key, "/") {
+		h.writeError(w, r, errInvalidKey)
+		return
+	}
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	uploadID := hex.EncodeToString(id)
+	dir, err := os.MkdirTemp(h.tempDir, "baidupan-s3-"+uploadID+"-*")
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	h.mu.Lock()
+	h.uploads[uploadID] = &multipartUpload{bucket: bucket, key: key, remotePath: remotePath, dir: dir, parts: map[int]uploadedPart{}}
+	h.mu.Unlock()
+	writeXML(w, http.StatusOK, initiateMultipartUploadResult{Bucket: bucket, Key: key, UploadID: uploadID})
+}
+
+// lookupUpload 查找属于 bucket/key 的分片上传
+func (h *Handler) lookupUpload(bucket string, key string, uploadID string) (*multipartUpload, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	u, ok := h.uploads[uploadID]
+	if !ok || u.bucket != bucket || u.key != key {
+		return nil, errNoSuchUpload
+	}
+	return u, nil
+}
+
+// uploadPart 处理 UploadPart，同一编号的分片重复上传时以最后一次为准
+func (h *Handler) uploadPart(w http.ResponseWriter, r *http.Request, bucket string, key string, uploadID string, partNumber string) {
+	n, err := strconv.Atoi(partNumber)
+	if err != nil || n < 1 || n > maxPartNumber {
+		h.writeError(w, r, errInvalidArgument)
+		return
+	}
+	u, err := h.lookupUpload(bucket, key, uploadID)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	body, size, err := requestBody(r)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	f, err := os.CreateTemp(u.dir, "part-*")
+	if err != nil {
+		// 上传已完成或被取消，暂存目录已删除
+		h.writeError(w, r, errNoSuchUpload)
+		return
+	}
+	hash := md5.New()
+	written, err := io.Copy(io.MultiWriter(f, hash), body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && size >= 0 && written != size {
+		err = errIncompleteBody
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		h.writeError(w, r, err)
+		return
+	}
+
+	part := uploadedPart{path: filepath.Join(u.dir, fmt.Sprintf("part-%05d", n)), size: written, etag: hex.EncodeToString(hash.Sum(nil))}
+	u.mu.Lock()
+	if u.completing {
+		u.mu.Unlock()
+		_ = os.Remove(f.Name())
+		h.writeError(w, r, errNoSuchUpload)
+		return
+	}
+	err = os.Rename(f.Name(), part.path)
+	if err == nil {
+		u.parts[n] = part
+	}
+	u.mu.Unlock()
+	if err != nil {
+		_ = os.Remove(f.Name())
+		h.writeError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", `"`+part.etag+`"`)
+	w.WriteHeader(http.StatusOK)
+}
+
+// completeMultipartUpload 处理 CompleteMultipartUpload：按请求中的顺序拼接分片并上传，未列出的分片被丢弃
+// 上传失败时保留分片，客户端可以重试
+func (h *Handler) completeMultipartUpload(w http.ResponseWriter, r *http.Request, bucket string, key string, uploadID string) {
+	u, err := h.lookupUpload(bucket, key, uploadID)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	var req completeMultipartUpload
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Parts) == 0 {
+		h.writeError(w, r, errMalformedXML)
+		return
+	}
+
+	u.mu.Lock()
+	if u.completing {
+		u.mu.Unlock()
+		h.writeError(w, r, errNoSuchUpload)
+		return
+	}
+	parts := make([]uploadedPart, 0, len(req.Parts))
+	for i, p := range req.Parts {
+		if i > 0 && p.PartNumber <= req.Parts[i-1].PartNumber {
+			u.mu.Unlock()
+			h.writeError(w, r, errInvalidPartOrder)
+			return
+		}
+		part, ok := u.parts[p.PartNumber]
+		if !ok || strings.Trim(p.ETag, `"`) != part.etag {
+			u.mu.Unlock()
+			h.writeError(w, r, errInvalidPart)
+			return
+		}
+		parts = append(parts, part)
+	}
+	u.completing = true
+	u.mu.Unlock()
+
+	info, err := h.uploadParts(r, u.remotePath, parts)
+	if err != nil {
+		u.mu.Lock()
+		u.completing = false
+		u.mu.Unlock()
+		h.writeError(w, r, err)
+		return
+	}
+	h.mu.Lock()
+	delete(h.uploads, uploadID)
+	h.mu.Unlock()
+	_ = os.RemoveAll(u.dir)
+
+	writeXML(w, http.StatusOK, completeMultipartUploadResult{
+		Location: "/" + bucket + "/" + key,
+		Bucket:   bucket,
+		Key:      key,
+		ETag:     objectETag(info),
+	})
+}
+
+// uploadParts 将分片按顺序拼接后上传，返回写入后的文件信息
+// 拼接结果可以 Seek，SDK 先按网盘的分片大小计算真实的分片 MD5 再预上传
+func (h *Handler) uploadParts(r *http.Request, remotePath string, parts []uploadedPart) (*baidupanplus.FileInfo, error) {
+	var joined partsReaderAt
+	for _, part := range parts {
+		f, err := os.Open(part.path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		joined.files = append(joined.files, f)
+		joined.sizes = append(joined.sizes, part.size)
+		joined.size += part.size
+	}
+	return h.upload(r, remotePath, io.NewSectionReader(&joined, 0, joined.size), joined.size)
+}
+
+// partsReaderAt 将多个分片文件按顺序拼接为一个 io.ReaderAt
+type partsReaderAt struct {
+	files []*os.File
+	sizes []int64
+	size  int64
+}
+
+// ReadAt 实现 io.ReaderAt
+func (p *partsReaderAt) ReadAt(b []byte, off int64) (int, error) {
+	var n int
+	for i := 0; i < len(p.files) && len(b) > 0; i++ {
+		if off >= p.sizes[i] {
+			off -= p.sizes[i]
+			continue
+		}
+		m, err := p.files[i].ReadAt(b[:min(int64(len(b)), p.sizes[i]-off)], off)
+		n += m
+		if err != nil {
+			// 分片文件比记录的长度短
+			return n, err
+		}
+		b = b[m:]
+		off = 0
+	}
+	if len(b) > 0 {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// abortMultipartUpload 处理 AbortMultipartUpload，删除已暂存的分片
+func (h *Handler) abortMultipartUpload(w http.ResponseWriter, r *http.Request, bucket string, key string, uploadID string) {
+	u, err := h.lookupUpload(bucket, key, uploadID)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	u.mu.Lock()
+	if u.completing {
+		u.mu.Unlock()
+		h.writeError(w, r, errNoSuchUpload)
+		return
+	}
+	u.completing = true
+	u.mu.Unlock()
+	h.mu.Lock()
+	delete(h.uploads, uploadID)
+	h.mu.Unlock()
+	_ = os.RemoveAll(u.dir)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Close 删除全部未完成的分片上传暂存的分片，之后这些上传无法继续
+func (h *Handler) Close() error {
+	h.mu.Lock()
+	uploads := h.uploads
+	h.uploads = map[string]*multipartUpload{}
+	h.mu.Unlock()
+	var firstErr error
+	for _, u := range uploads {
+		if err := os.RemoveAll(u.dir); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+type initiateMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ InitiateMultipartUploadResult"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	UploadID string   `xml:"UploadId"`
+}
+
+type completeMultipartUpload struct {
+	Parts []struct {
+		PartNumber int    `xml:"PartNumber"`
+		ETag       string `xml:"ETag"`
+	} `xml:"Part"`
+}
+
+type completeMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ CompleteMultipartUploadResult"`
+	Location string   `xml:"Location"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	ETag     string   `xml:"ETag"`
+}
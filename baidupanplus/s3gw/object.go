@@ -0,0 +1,268 @@
+package s3gw
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/S-zhi/baidupansdk/baidupanplus"
+)
+
+// emptyETag 空对象的 ETag
+const emptyETag = `"d41d8cd98f00b204e9800998ecf8427e"`
+
+// objectETag 返回对象的 ETag，PutObject、CompleteMultipartUpload、CopyObject、HeadObject 与列表使用同一来源
+// 网盘的 md5 字段是云端哈希而非内容的 MD5，因此 ETag 由 fs_id 与该字段组成，形如 "fs_id-md5"，
+// 不是 32 位十六进制，客户端不会把它当作内容的 MD5 校验
+func objectETag(info *baidupanplus.FileInfo) string {
+	hash := info.Md5
+	if hash == "" {
+		hash = strconv.FormatInt(info.ServerMtime, 10)
+	}
+	return `"` + strconv.FormatInt(info.FsId, 10) + "-" + hash + `"`
+}
+
+// getObject 处理 GetObject 与 HeadObject，Range 与条件请求由 http.ServeContent 处理
+func (h *Handler) getObject(w http.ResponseWriter, r *http.Request, remotePath string) {
+	info, err := h.client.Stat(r.Context(), remotePath)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	if info.IsDir() {
+		h.writeError(w, r, errNoSuchKey)
+		return
+	}
+	// dlink 在第一次读取时才获取，HEAD 与命中 304 的请求不会访问文件内容
+	f, err := h.client.OpenFileInfo(r.Context(), info)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	defer f.Close()
+
+	header := w.Header()
+	header.Set("ETag", objectETag(info))
+	ctype := mime.TypeByExtension(path.Ext(info.ServerFilename))
+	if ctype == "" {
+		ctype = "application/octet-stream"
+	}
+	header.Set("Content-Type", ctype)
+	var modtime time.Time
+	if info.ServerMtime > 0 {
+		modtime = info.ModTime()
+	}
+	http.ServeContent(w, r, info.ServerFilename, modtime, f)
+}
+
+// putObject 处理 PutObject：数据流式上传并覆盖同名文件，以 "/" 结尾的空对象创建目录
+func (h *Handler) putObject(w http.ResponseWriter, r *http.Request, key string, remotePath string) {
+	body, size, err := requestBody(r)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	if strings.HasSuffix(key, "/") {
+		if size != 0 {
+			h.writeError(w, r, errInvalidArgument)
+			return
+		}
+		if _, err := h.client.MkdirAll(r.Context(), remotePath); err != nil {
+			h.writeError(w, r, err)
+			return
+		}
+		w.Header().Set("ETag", emptyETag)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if size < 0 {
+		// 未知长度的请求体先写入本地临时文件，上传需要预先知道文件大小
+		spooled, n, err := h.spool(body)
+		if err != nil {
+			h.writeError(w, r, err)
+			return
+		}
+		defer os.Remove(spooled.Name())
+		defer spooled.Close()
+		body, size = spooled, n
+	}
+
+	info, err := h.upload(r, remotePath, body, size)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", objectETag(info))
+	w.WriteHeader(http.StatusOK)
+}
+
+// upload 将 body 的 size 字节上传到 remotePath 并覆盖同名文件，返回写入后的文件信息
+// remotePath 已是目录时拒绝上传，避免覆盖整个目录。已在本地磁盘上的数据应以 io.Seeker 传入，
+// SDK 据此先计算真实的分片 MD5 再预上传；只有直接转发的请求体才以占位的 block_list 流式上传
+func (h *Handler) upload(r *http.Request, remotePath string, body io.Reader, size int64) (*baidupanplus.FileInfo, error) {
+	info, err := h.client.Stat(r.Context(), remotePath)
+	if err == nil && info.IsDir() {
+		return nil, errObjectIsDirectory
+	}
+	if err != nil && baidupanplus.CategoryOf(err) != baidupanplus.CategoryNotFound {
+		return nil, err
+	}
+
+	var counter *countingReader
+	if _, ok := body.(io.Seeker); !ok {
+		// 请求体读取不足时返回 IncompleteBody；本地数据的长度已确定，无需统计
+		counter = &countingReader{r: body}
+		body = counter
+	}
+	res, err := h.client.UploadReaderWithOptions(r.Context(), body, size, remotePath, baidupanplus.UploadOptions{OnConflict: baidupanplus.ConflictOverwrite})
+	if err != nil {
+		var apiErr *apiError
+		if errors.As(err, &apiErr) {
+			// 请求体的摘要或分块签名校验失败
+			return nil, err
+		}
+		if counter != nil && counter.n < size && r.Context().Err() == nil {
+			return nil, errIncompleteBody
+		}
+		return nil, err
+	}
+	// 重新查询文件，使返回的 ETag 与之后 HeadObject、列表得到的一致；失败时退回上传返回的信息
+	if info, err := h.client.Stat(r.Context(), remotePath); err == nil {
+		return info, nil
+	}
+	return &res.FileInfo, nil
+}
+
+// spool 将 body 写入本地临时文件，返回定位到开头的文件与数据长度
+func (h *Handler) spool(body io.Reader) (*os.File, int64, error) {
+	f, err := os.CreateTemp(h.tempDir, "baidupan-s3-*")
+	if err != nil {
+		return nil, 0, err
+	}
+	n, err := io.Copy(f, body)
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return nil, 0, err
+	}
+	return f, n, nil
+}
+
+// copyObject 处理 CopyObject，通过网盘的复制接口完成，数据不经过网关
+func (h *Handler) copyObject(w http.ResponseWriter, r *http.Request, remotePath string) {
+	source := r.Header.Get("X-Amz-Copy-Source")
+	source, _, _ = strings.Cut(source, "?") // 忽略 versionId
+	source, err := url.PathUnescape(strings.TrimPrefix(source, "/"))
+	if err != nil {
+		h.writeError(w, r, errInvalidArgument)
+		return
+	}
+	srcBucket, srcKey, _ := strings.Cut(source, "/")
+	root, ok := h.buckets[srcBucket]
+	if !ok {
+		h.writeError(w, r, errNoSuchBucket)
+		return
+	}
+	if !validKey(srcKey) {
+		h.writeError(w, r, errInvalidKey)
+		return
+	}
+	srcPath := path.Join(root, srcKey)
+
+	info, err := h.client.Stat(r.Context(), srcPath)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	if info.IsDir() {
+		h.writeError(w, r, errNoSuchKey)
+		return
+	}
+	if srcPath != remotePath {
+		dst, err := h.client.Stat(r.Context(), remotePath)
+		if err == nil && dst.IsDir() {
+			h.writeError(w, r, errObjectIsDirectory)
+			return
+		}
+		if err != nil && baidupanplus.CategoryOf(err) != baidupanplus.CategoryNotFound {
+			h.writeError(w, r, err)
+			return
+		}
+		err = h.client.Copy(r.Context(), srcPath, path.Dir(remotePath), path.Base(remotePath), baidupanplus.OnDupOverwrite)
+		if err != nil {
+			h.writeError(w, r, err)
+			return
+		}
+		// 副本是新的文件，ETag 取自副本的信息
+		if info, err = h.client.Stat(r.Context(), remotePath); err != nil {
+			h.writeError(w, r, err)
+			return
+		}
+	}
+	writeXML(w, http.StatusOK, copyObjectResult{
+		LastModified: formatTime(h.now()),
+		ETag:         objectETag(info),
+	})
+}
+
+// deleteObject 处理 DeleteObject，对象不存在时同样返回成功
+// 键对应目录时只删除以 "/" 结尾的空目录标记，不会递归删除目录
+func (h *Handler) deleteObject(w http.ResponseWriter, r *http.Request, key string, remotePath string) {
+	info, err := h.client.Stat(r.Context(), remotePath)
+	switch {
+	case baidupanplus.CategoryOf(err) == baidupanplus.CategoryNotFound:
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case err != nil:
+		h.writeError(w, r, err)
+		return
+	case info.IsDir():
+		if !strings.HasSuffix(key, "/") {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		entries, err := h.client.List(r.Context(), remotePath)
+		if err != nil {
+			h.writeError(w, r, err)
+			return
+		}
+		if len(entries) > 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	if err := h.client.Remove(r.Context(), remotePath); err != nil && baidupanplus.CategoryOf(err) != baidupanplus.CategoryNotFound {
+		h.writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// countingReader 统计已读取的字节数
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+type copyObjectResult struct {
+	XMLName      xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ CopyObjectResult"`
+	LastModified string   `xml:"LastModified"`
+	ETag         string   `xml:"ETag"`
+}
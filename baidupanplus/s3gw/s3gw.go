@@ -0,0 +1,327 @@
+// Package s3gw 以 S3 API 的一个子集提供网盘文件，使只支持 S3 的工具（如 restic 的 S3 后端）可以直接使用网盘
+//
+// 每个桶映射为一个网盘目录，对象键中的 "/" 对应子目录。支持 ListBuckets、HeadBucket、CreateBucket、
+// GetBucketLocation、ListObjectsV2、GetObject（含 Range）、HeadObject、PutObject、CopyObject、DeleteObject
+// 与分片上传；只支持路径风格的地址（http://host/bucket/key）
+//
+//	h := s3gw.New(client, s3gw.Config{
+//		Buckets:   map[string]string{"backup": "/apps/myapp/backup"},
+//		AccessKey: "restic",
+//		SecretKey: "secret",
+//	})
+//	http.ListenAndServe("127.0.0.1:9000", h)
+package s3gw
+
+import (
+	"encoding/xml"
+	"errors"
+	"log/slog"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/S-zhi/baidupansdk/baidupanplus"
+)
+
+// defaultRegion 未配置 Config.Region 时返回的区域
+const defaultRegion = "us-east-1"
+
+// Config 网关配置
+type Config struct {
+	// Buckets 桶名到网盘目录的映射，不在其中的桶视为不存在
+	Buckets map[string]string
+	// Region GetBucketLocation 返回的区域，默认为 us-east-1
+	Region string
+	// AccessKey / SecretKey 校验请求的 AWS Signature V4 签名，均为空时不校验
+	AccessKey string
+	SecretKey string
+	// TempDir 分片上传时暂存分片的本地目录，默认为 os.TempDir()
+	TempDir string
+	// Logger 记录请求失败的原因，为空时不记录
+	Logger *slog.Logger
+}
+
+// Handler 实现 http.Handler
+type Handler struct {
+	client  *baidupanplus.Client
+	buckets map[string]string
+	region  string
+	access  string
+	secret  string
+	tempDir string
+	logger  *slog.Logger
+	now     func() time.Time
+
+	mu      sync.Mutex
+	uploads map[string]*multipartUpload // 进行中的分片上传，按 uploadId 索引
+}
+
+var _ http.Handler = (*Handler)(nil)
+
+// New 创建网关，所有请求都通过 client 访问网盘
+func New(client *baidupanplus.Client, cfg Config) *Handler {
+	buckets := make(map[string]string, len(cfg.Buckets))
+	for name, dir := range cfg.Buckets {
+		buckets[name] = path.Clean("/" + dir)
+	}
+	region := cfg.Region
+	if region == "" {
+		region = defaultRegion
+	}
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	return &Handler{
+		client:  client,
+		buckets: buckets,
+		region:  region,
+		access:  cfg.AccessKey,
+		secret:  cfg.SecretKey,
+		tempDir: cfg.TempDir,
+		logger:  logger,
+		now:     time.Now,
+		uploads: map[string]*multipartUpload{},
+	}
+}
+
+// ServeHTTP 实现 http.Handler，按路径与查询参数分派到各个 S3 操作
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := h.authenticate(r); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket == "" {
+		if r.Method != http.MethodGet {
+			h.writeError(w, r, errMethodNotAllowed)
+			return
+		}
+		h.listBuckets(w, r)
+		return
+	}
+	root, ok := h.buckets[bucket]
+	if !ok {
+		h.writeError(w, r, errNoSuchBucket)
+		return
+	}
+	if key == "" {
+		h.serveBucket(w, r, bucket, root)
+		return
+	}
+	if !validKey(key) {
+		h.writeError(w, r, errInvalidKey)
+		return
+	}
+	h.serveObject(w, r, bucket, key, path.Join(root, key))
+}
+
+// serveBucket 处理桶级别的请求
+func (h *Handler) serveBucket(w http.ResponseWriter, r *http.Request, bucket string, root string) {
+	q := r.URL.Query()
+	switch r.Method {
+	case http.MethodHead:
+		w.WriteHeader(http.StatusOK)
+	case http.MethodPut:
+		// 桶由配置决定，CreateBucket 只确保对应的目录存在
+		if _, err := h.client.MkdirAll(r.Context(), root); err != nil {
+			h.writeError(w, r, err)
+			return
+		}
+		w.Header().Set("Location", "/"+bucket)
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		switch {
+		case q.Has("location"):
+			region := h.region
+			if region == defaultRegion {
+				// 按 S3 的约定，us-east-1 返回空的 LocationConstraint
+				region = ""
+			}
+			writeXML(w, http.StatusOK, locationConstraint{Region: region})
+		case q.Get("list-type") == "2":
+			h.listObjectsV2(w, r, bucket, root)
+		default:
+			h.writeError(w, r, errNotImplemented)
+		}
+	default:
+		h.writeError(w, r, errNotImplemented)
+	}
+}
+
+// serveObject 处理对象级别的请求
+func (h *Handler) serveObject(w http.ResponseWriter, r *http.Request, bucket string, key string, remotePath string) {
+	q := r.URL.Query()
+	uploadID := q.Get("uploadId")
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		if uploadID != "" {
+			h.writeError(w, r, errNotImplemented)
+			return
+		}
+		h.getObject(w, r, remotePath)
+	case http.MethodPut:
+		switch {
+		case uploadID != "" && r.Header.Get("X-Amz-Copy-Source") != "":
+			h.writeError(w, r, errNotImplemented)
+		case uploadID != "":
+			h.uploadPart(w, r, bucket, key, uploadID, q.Get("partNumber"))
+		case r.Header.Get("X-Amz-Copy-Source") != "":
+			h.copyObject(w, r, remotePath)
+		default:
+			h.putObject(w, r, key, remotePath)
+		}
+	case http.MethodPost:
+		switch {
+		case q.Has("uploads"):
+			h.createMultipartUpload(w, r, bucket, key, remotePath)
+		case uploadID != "":
+			h.completeMultipartUpload(w, r, bucket, key, uploadID)
+		default:
+			h.writeError(w, r, errNotImplemented)
+		}
+	case http.MethodDelete:
+		if uploadID != "" {
+			h.abortMultipartUpload(w, r, bucket, key, uploadID)
+			return
+		}
+		h.deleteObject(w, r, key, remotePath)
+	default:
+		h.writeError(w, r, errMethodNotAllowed)
+	}
+}
+
+// validKey 对象键必须能无损映射为网盘路径：不能包含空的路径段、"." 或 ".."，只允许以 "/" 结尾表示目录
+func validKey(key string) bool {
+	trimmed := strings.TrimSuffix(key, "/")
+	return trimmed != "" && path.Clean("/"+trimmed) == "/"+trimmed
+}
+
+// listBuckets 列出配置的全部桶
+func (h *Handler) listBuckets(w http.ResponseWriter, r *http.Request) {
+	result := listAllMyBucketsResult{Owner: owner{ID: "baidupan", DisplayName: "baidupan"}}
+	for name := range h.buckets {
+		result.Buckets = append(result.Buckets, bucketEntry{Name: name, CreationDate: formatTime(time.Unix(0, 0))})
+	}
+	slices.SortFunc(result.Buckets, func(a, b bucketEntry) int { return strings.Compare(a.Name, b.Name) })
+	writeXML(w, http.StatusOK, result)
+}
+
+// apiError S3 错误响应
+type apiError struct {
+	Code    string
+	Message string
+	Status  int
+}
+
+func (e *apiError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+var (
+	errNoSuchBucket          = &apiError{"NoSuchBucket", "The specified bucket does not exist.", http.StatusNotFound}
+	errNoSuchKey             = &apiError{"NoSuchKey", "The specified key does not exist.", http.StatusNotFound}
+	errNoSuchUpload          = &apiError{"NoSuchUpload", "The specified multipart upload does not exist.", http.StatusNotFound}
+	errInvalidKey            = &apiError{"InvalidArgument", "Object key must be a clean slash-separated path.", http.StatusBadRequest}
+	errInvalidArgument       = &apiError{"InvalidArgument", "Invalid argument.", http.StatusBadRequest}
+	errInvalidPart           = &apiError{"InvalidPart", "One or more of the specified parts could not be found or the ETag did not match.", http.StatusBadRequest}
+	errInvalidPartOrder      = &apiError{"InvalidPartOrder", "The list of parts was not in ascending order.", http.StatusBadRequest}
+	errMalformedXML          = &apiError{"MalformedXML", "The XML you provided was not well-formed.", http.StatusBadRequest}
+	errMissingLength         = &apiError{"MissingContentLength", "You must provide the Content-Length HTTP header.", http.StatusLengthRequired}
+	errIncompleteBody        = &apiError{"IncompleteBody", "You did not provide the number of bytes specified by the Content-Length HTTP header.", http.StatusBadRequest}
+	errObjectIsDirectory     = &apiError{"InvalidRequest", "The key refers to a directory.", http.StatusConflict}
+	errAccessDenied          = &apiError{"AccessDenied", "Access Denied.", http.StatusForbidden}
+	errInvalidAccessKeyID    = &apiError{"InvalidAccessKeyId", "The access key ID you provided does not exist in our records.", http.StatusForbidden}
+	errSignatureMismatch     = &apiError{"SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided.", http.StatusForbidden}
+	errContentSHA256Mismatch = &apiError{"XAmzContentSHA256Mismatch", "The provided 'x-amz-content-sha256' header does not match what was computed.", http.StatusBadRequest}
+	errInvalidContentSHA256  = &apiError{"InvalidArgument", "x-amz-content-sha256 must be UNSIGNED-PAYLOAD, a STREAMING- value or a hex SHA-256.", http.StatusBadRequest}
+	errRequestTimeSkewed     = &apiError{"RequestTimeTooSkewed", "The difference between the request time and the server's time is too large.", http.StatusForbidden}
+	errMethodNotAllowed      = &apiError{"MethodNotAllowed", "The specified method is not allowed against this resource.", http.StatusMethodNotAllowed}
+	errNotImplemented        = &apiError{"NotImplemented", "A header or query you provided implies functionality that is not implemented.", http.StatusNotImplemented}
+	errSlowDown              = &apiError{"SlowDown", "Please reduce your request rate.", http.StatusServiceUnavailable}
+	errStorageFull           = &apiError{"InsufficientStorage", "The netdisk does not have enough free space.", http.StatusInsufficientStorage}
+	errInternal              = &apiError{"InternalError", "We encountered an internal error. Please try again.", http.StatusInternalServerError}
+)
+
+// toAPIError 将 SDK 错误按类别转换为 S3 错误，响应中不包含 SDK 错误的详情
+func toAPIError(err error) *apiError {
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	switch baidupanplus.CategoryOf(err) {
+	case baidupanplus.CategoryNotFound:
+		return errNoSuchKey
+	case baidupanplus.CategoryPermission:
+		return errAccessDenied
+	case baidupanplus.CategoryRateLimited:
+		return errSlowDown
+	case baidupanplus.CategoryQuota:
+		return errStorageFull
+	case baidupanplus.CategoryInvalid:
+		return errInvalidArgument
+	default:
+		return errInternal
+	}
+}
+
+// writeError 写入 S3 格式的错误响应，HEAD 请求只返回状态码
+func (h *Handler) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	if baidupanplus.CategoryOf(err) == baidupanplus.CategoryCanceled {
+		// 客户端已断开，无需响应
+		return
+	}
+	apiErr := toAPIError(err)
+	if apiErr.Status >= http.StatusInternalServerError {
+		h.logger.Error("S3 请求失败", "method", r.Method, "path", r.URL.Path, "error", err)
+	}
+	if r.Method == http.MethodHead {
+		w.WriteHeader(apiErr.Status)
+		return
+	}
+	writeXML(w, apiErr.Status, errorResponse{Code: apiErr.Code, Message: apiErr.Message, Resource: r.URL.Path})
+}
+
+// writeXML 写入 XML 响应
+func writeXML(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(xml.Header))
+	_ = xml.NewEncoder(w).Encode(v)
+}
+
+// formatTime 按 S3 XML 响应的格式输出时间
+func formatTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+type errorResponse struct {
+	XMLName  xml.Name `xml:"Error"`
+	Code     string   `xml:"Code"`
+	Message  string   `xml:"Message"`
+	Resource string   `xml:"Resource"`
+}
+
+type locationConstraint struct {
+	XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ LocationConstraint"`
+	Region  string   `xml:",chardata"`
+}
+
+type owner struct {
+	ID          string `xml:"ID"`
+	DisplayName string `xml:"DisplayName"`
+}
+
+type bucketEntry struct {
+	Name         string `xml:"Name"`
+	CreationDate string `xml:"CreationDate"`
+}
+
+type listAllMyBucketsResult struct {
+	XMLName xml.Name      `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListAllMyBucketsResult"`
+	Owner   owner         `xml:"Owner"`
+	Buckets []bucketEntry `xml:"Buckets>Bucket"`
+}
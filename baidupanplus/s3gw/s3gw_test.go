@@ -0,0 +1,476 @@
+package s3gw
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/S-zhi/baidupansdk/baidupanplus"
+	"github.com/S-zhi/baidupansdk/baidupantest"
+)
+
+const (
+	testAccessKey = "AKIDEXAMPLE"
+	testSecretKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+	testRegion    = "us-east-1"
+)
+
+// testGateway 模拟网盘服务与映射 backup 桶到 /apps/s3/backup 的网关
+type testGateway struct {
+	t         *testing.T
+	srv       *baidupantest.Server
+	url       string
+	precreate *precreateRecorder
+}
+
+// precreateRecorder 记录 precreate 请求提交的 block_list
+type precreateRecorder struct {
+	base http.RoundTripper
+
+	mu         sync.Mutex
+	blockLists []string
+}
+
+func (r *precreateRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Query().Get("method") == "precreate" && req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		form, _ := url.ParseQuery(string(body))
+		r.mu.Lock()
+		r.blockLists = append(r.blockLists, form.Get("block_list"))
+		r.mu.Unlock()
+	}
+	return r.base.RoundTrip(req)
+}
+
+// last 返回最近一次 precreate 提交的 block_list
+func (r *precreateRecorder) last() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.blockLists) == 0 {
+		return ""
+	}
+	return r.blockLists[len(r.blockLists)-1]
+}
+
+func newTestGateway(t *testing.T) *testGateway {
+	t.Helper()
+	srv := baidupantest.NewServer()
+	t.Cleanup(srv.Close)
+	srv.SetAccessToken("token")
+	srv.AddDir("/apps/s3/backup")
+	recorder := &precreateRecorder{base: srv.Client().Transport}
+	client := baidupanplus.NewClient(baidupanplus.Config{
+		AccessToken: "token",
+		HTTPClient:  &http.Client{Transport: recorder},
+		Logger:      slog.New(slog.DiscardHandler),
+	})
+	h := New(client, Config{
+		Buckets:   map[string]string{"backup": "/apps/s3/backup"},
+		AccessKey: testAccessKey,
+		SecretKey: testSecretKey,
+		TempDir:   t.TempDir(),
+	})
+	t.Cleanup(func() { h.Close() })
+	gw := httptest.NewServer(h)
+	t.Cleanup(gw.Close)
+	return &testGateway{t: t, srv: srv, url: gw.URL, precreate: recorder}
+}
+
+// signingKey 按 Signature V4 派生签名密钥
+func signingKey(secret string, date string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), date)
+	key = hmacSHA256(key, testRegion)
+	key = hmacSHA256(key, "s3")
+	return hmacSHA256(key, "aws4_request")
+}
+
+// newRequest 构造以 secret 签名的请求，返回请求与其签名
+func (g *testGateway) newRequest(method string, target string, query url.Values, body io.Reader, payloadHash string, secret string) (*http.Request, string) {
+	g.t.Helper()
+	req, err := http.NewRequest(method, g.url+target, body)
+	if err != nil {
+		g.t.Fatal(err)
+	}
+	req.URL.RawQuery = query.Encode()
+	now := time.Now().UTC()
+	amzDate := now.Format(amzDateFormat)
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signed := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	if req.Header.Get("Content-Encoding") != "" {
+		signed = append(signed, "content-encoding")
+	}
+	canonical := strings.Join([]string{
+		method,
+		req.URL.EscapedPath(),
+		query.Encode(),
+		"host:" + req.URL.Host + "\nx-amz-content-sha256:" + payloadHash + "\nx-amz-date:" + amzDate + "\n",
+		strings.Join(signed, ";"),
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + testRegion + "/s3/aws4_request"
+	canonicalHash := sha256.Sum256([]byte(canonical))
+	stringToSign := sigV4Algorithm + "\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+	signature := hex.EncodeToString(hmacSHA256(signingKey(secret, date), stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, testAccessKey, scope, strings.Join(signed, ";"), signature))
+	return req, signature
+}
+
+// do 以正确的密钥签名并发送请求，检查响应状态码并返回响应体
+func (g *testGateway) do(method string, target string, query url.Values, body []byte, header http.Header, wantStatus int) (*http.Response, []byte) {
+	g.t.Helper()
+	sum := sha256.Sum256(body)
+	req, _ := g.newRequest(method, target, query, bytes.NewReader(body), hex.EncodeToString(sum[:]), testSecretKey)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	return g.send(req, wantStatus)
+}
+
+func (g *testGateway) send(req *http.Request, wantStatus int) (*http.Response, []byte) {
+	g.t.Helper()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		g.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		g.t.Fatal(err)
+	}
+	if resp.StatusCode != wantStatus {
+		g.t.Fatalf("%s %s: status %d, want %d: %s", req.Method, req.URL.Path, resp.StatusCode, wantStatus, data)
+	}
+	return resp, data
+}
+
+// errorCode 解析 S3 错误响应中的 Code
+func errorCode(t *testing.T, data []byte) string {
+	t.Helper()
+	var resp errorResponse
+	if err := xml.Unmarshal(data, &resp); err != nil {
+		t.Fatalf("decode error response %q: %v", data, err)
+	}
+	return resp.Code
+}
+
+func TestSigV4(t *testing.T) {
+	g := newTestGateway(t)
+	data := []byte("hello, s3")
+	sum := sha256.Sum256(data)
+	payloadHash := hex.EncodeToString(sum[:])
+
+	req, err := http.NewRequest(http.MethodGet, g.url+"/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, body := g.send(req, http.StatusForbidden); errorCode(t, body) != "AccessDenied" {
+		t.Errorf("unsigned request: %s", body)
+	}
+	req, _ = g.newRequest(http.MethodGet, "/", nil, nil, emptySHA256, "wrong secret")
+	if _, body := g.send(req, http.StatusForbidden); errorCode(t, body) != "SignatureDoesNotMatch" {
+		t.Errorf("wrong secret: %s", body)
+	}
+	g.do(http.MethodGet, "/", nil, nil, nil, http.StatusOK)
+
+	resp, _ := g.do(http.MethodPut, "/backup/a.txt", nil, data, nil, http.StatusOK)
+	if got, ok := g.srv.ReadFile("/apps/s3/backup/a.txt"); !ok || !bytes.Equal(got, data) {
+		t.Fatalf("remote content = %q, %v", got, ok)
+	}
+	if etag := resp.Header.Get("ETag"); etag == "" {
+		t.Error("PutObject returned no ETag")
+	}
+
+	// 请求体与签名中声明的 SHA-256 不一致
+	req, _ = g.newRequest(http.MethodPut, "/backup/b.txt", nil, strings.NewReader("tampered!"), payloadHash, testSecretKey)
+	if _, body := g.send(req, http.StatusBadRequest); errorCode(t, body) != "XAmzContentSHA256Mismatch" {
+		t.Errorf("payload mismatch: %s", body)
+	}
+	// 长度未知的请求体同样在读到结尾时校验
+	req, _ = g.newRequest(http.MethodPut, "/backup/b.txt", nil, io.MultiReader(strings.NewReader("tampered!")), payloadHash, testSecretKey)
+	if _, body := g.send(req, http.StatusBadRequest); errorCode(t, body) != "XAmzContentSHA256Mismatch" {
+		t.Errorf("chunked payload mismatch: %s", body)
+	}
+	if g.srv.Exists("/apps/s3/backup/b.txt") {
+		t.Error("object was created although the payload hash did not match")
+	}
+
+	// 长度未知的请求体先写入本地临时文件，预上传提交真实的分片 MD5
+	req, _ = g.newRequest(http.MethodPut, "/backup/spooled.txt", nil, io.MultiReader(bytes.NewReader(data)), payloadHash, testSecretKey)
+	g.send(req, http.StatusOK)
+	if got, _ := g.srv.ReadFile("/apps/s3/backup/spooled.txt"); !bytes.Equal(got, data) {
+		t.Errorf("spooled content = %q", got)
+	}
+	if want := blockList(data); g.precreate.last() != want {
+		t.Errorf("precreate block_list = %s, want %s", g.precreate.last(), want)
+	}
+
+	req, _ = g.newRequest(http.MethodPut, "/backup/c.txt", nil, bytes.NewReader(data), unsignedPayload, testSecretKey)
+	g.send(req, http.StatusOK)
+	req, _ = g.newRequest(http.MethodPut, "/backup/c.txt", nil, bytes.NewReader(data), "not-a-hash", testSecretKey)
+	g.send(req, http.StatusBadRequest)
+}
+
+// blockList 返回 data 按 4MB 分片的 MD5 列表，格式与 precreate 的 block_list 相同
+func blockList(data []byte) string {
+	var sums []string
+	for len(data) > 0 {
+		n := min(4<<20, len(data))
+		sums = append(sums, fmt.Sprintf("%q", fmt.Sprintf("%x", md5.Sum(data[:n]))))
+		data = data[n:]
+	}
+	return "[" + strings.Join(sums, ",") + "]"
+}
+
+// chunkedBody 按 aws-chunked 格式编码 data 并为每块签名，seed 为请求签名
+func chunkedBody(data []byte, chunkSize int, seed string, amzDate string) []byte {
+	date := amzDate[:8]
+	scope := date + "/" + testRegion + "/s3/aws4_request"
+	key := signingKey(testSecretKey, date)
+	prev := seed
+	var buf bytes.Buffer
+	for {
+		n := min(chunkSize, len(data))
+		chunk := data[:n]
+		data = data[n:]
+		chunkHash := sha256.Sum256(chunk)
+		stringToSign := strings.Join([]string{chunkAlgorithm, amzDate, scope, prev, emptySHA256, hex.EncodeToString(chunkHash[:])}, "\n")
+		prev = hex.EncodeToString(hmacSHA256(key, stringToSign))
+		fmt.Fprintf(&buf, "%x;chunk-signature=%s\r\n", n, prev)
+		buf.Write(chunk)
+		buf.WriteString("\r\n")
+		if n == 0 {
+			return buf.Bytes()
+		}
+	}
+}
+
+func TestStreamingSignature(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789abcdef"), 10000)
+	tests := []struct {
+		name       string
+		tamper     func(body []byte) []byte
+		wantStatus int
+		wantCode   string
+	}{
+		{"valid", nil, http.StatusOK, ""},
+		{"tampered data", func(body []byte) []byte {
+			// 修改第二块中的一个字节
+			i := bytes.Index(body, []byte("\r\n")) + 2 + 65536 + 100
+			body[i] ^= 1
+			return body
+		}, http.StatusForbidden, "SignatureDoesNotMatch"},
+		{"dropped first chunk", func(body []byte) []byte {
+			// 签名链从请求签名开始，去掉第一块后其余块的签名都不成立
+			first := bytes.Index(body, []byte("\r\n")) + 2 + 65536 + 2
+			return body[first:]
+		}, http.StatusForbidden, "SignatureDoesNotMatch"},
+		{"missing final chunk", func(body []byte) []byte {
+			return body[:bytes.LastIndex(body, []byte("0;chunk-signature="))]
+		}, http.StatusBadRequest, "IncompleteBody"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGateway(t)
+			req, seed := g.newRequest(http.MethodPut, "/backup/stream.bin", nil, nil, streamingPayload, testSecretKey)
+			body := chunkedBody(data, 65536, seed, req.Header.Get("X-Amz-Date"))
+			if tt.tamper != nil {
+				body = tt.tamper(body)
+			}
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			req.ContentLength = int64(len(body))
+			req.Header.Set("X-Amz-Decoded-Content-Length", strconv.Itoa(len(data)))
+
+			_, resp := g.send(req, tt.wantStatus)
+			got, ok := g.srv.ReadFile("/apps/s3/backup/stream.bin")
+			if tt.wantCode == "" {
+				if !ok || !bytes.Equal(got, data) {
+					t.Fatalf("remote content differs: got %d bytes, %v", len(got), ok)
+				}
+				return
+			}
+			if code := errorCode(t, resp); code != tt.wantCode {
+				t.Errorf("code = %s, want %s", code, tt.wantCode)
+			}
+			if ok {
+				t.Error("object was created from an unverified body")
+			}
+		})
+	}
+}
+
+func TestMultipartUpload(t *testing.T) {
+	g := newTestGateway(t)
+	_, body := g.do(http.MethodPost, "/backup/big.bin", url.Values{"uploads": {""}}, nil, nil, http.StatusOK)
+	var initiate initiateMultipartUploadResult
+	if err := xml.Unmarshal(body, &initiate); err != nil {
+		t.Fatal(err)
+	}
+
+	parts := [][]byte{bytes.Repeat([]byte("a"), 5<<20), []byte("tail")}
+	var complete strings.Builder
+	complete.WriteString("<CompleteMultipartUpload>")
+	for i, part := range parts {
+		q := url.Values{"partNumber": {strconv.Itoa(i + 1)}, "uploadId": {initiate.UploadID}}
+		resp, _ := g.do(http.MethodPut, "/backup/big.bin", q, part, nil, http.StatusOK)
+		fmt.Fprintf(&complete, "<Part><PartNumber>%d</PartNumber><ETag>%s</ETag></Part>", i+1, resp.Header.Get("ETag"))
+	}
+	complete.WriteString("</CompleteMultipartUpload>")
+
+	// 摘要不符的分片被拒绝，不会替换已上传的分片
+	sum := sha256.Sum256([]byte("other"))
+	q := url.Values{"partNumber": {"2"}, "uploadId": {initiate.UploadID}}
+	req, _ := g.newRequest(http.MethodPut, "/backup/big.bin", q, strings.NewReader("evil"), hex.EncodeToString(sum[:]), testSecretKey)
+	g.send(req, http.StatusBadRequest)
+
+	_, body = g.do(http.MethodPost, "/backup/big.bin", url.Values{"uploadId": {initiate.UploadID}}, []byte(complete.String()), nil, http.StatusOK)
+	var result completeMultipartUploadResult
+	if err := xml.Unmarshal(body, &result); err != nil {
+		t.Fatal(err)
+	}
+	if head, _ := g.do(http.MethodHead, "/backup/big.bin", nil, nil, nil, http.StatusOK); head.Header.Get("ETag") != result.ETag {
+		t.Errorf("CompleteMultipartUpload ETag %s, HeadObject ETag %s", result.ETag, head.Header.Get("ETag"))
+	}
+	got, ok := g.srv.ReadFile("/apps/s3/backup/big.bin")
+	if want := bytes.Join(parts, nil); !ok || !bytes.Equal(got, want) {
+		t.Fatalf("remote content differs: got %d bytes, want %d", len(got), len(want))
+	}
+	// 本地暂存的分片可以回读，预上传提交真实的分片 MD5
+	if want := blockList(bytes.Join(parts, nil)); g.precreate.last() != want {
+		t.Errorf("precreate block_list = %s, want %s", g.precreate.last(), want)
+	}
+	// 完成后上传 ID 失效
+	g.do(http.MethodPost, "/backup/big.bin", url.Values{"uploadId": {initiate.UploadID}}, []byte(complete.String()), nil, http.StatusNotFound)
+}
+
+func TestETagConsistent(t *testing.T) {
+	g := newTestGateway(t)
+	data := []byte("hello, etag")
+	put, _ := g.do(http.MethodPut, "/backup/docs/a.txt", nil, data, nil, http.StatusOK)
+	etag := put.Header.Get("ETag")
+	// 网盘的 md5 字段不是内容的 MD5，ETag 不能被客户端当作内容的 MD5
+	if etag == fmt.Sprintf(`"%x"`, md5.Sum(data)) || len(strings.Trim(etag, `"`)) == 32 {
+		t.Errorf("ETag %s looks like a content MD5", etag)
+	}
+
+	if head, _ := g.do(http.MethodHead, "/backup/docs/a.txt", nil, nil, nil, http.StatusOK); head.Header.Get("ETag") != etag {
+		t.Errorf("PutObject ETag %s, HeadObject ETag %s", etag, head.Header.Get("ETag"))
+	}
+	if get, _ := g.do(http.MethodGet, "/backup/docs/a.txt", nil, nil, nil, http.StatusOK); get.Header.Get("ETag") != etag {
+		t.Errorf("PutObject ETag %s, GetObject ETag %s", etag, get.Header.Get("ETag"))
+	}
+	_, body := g.do(http.MethodGet, "/backup", url.Values{"list-type": {"2"}, "prefix": {"docs/"}}, nil, nil, http.StatusOK)
+	var list listBucketResultV2
+	if err := xml.Unmarshal(body, &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Contents) != 1 || list.Contents[0].ETag != etag {
+		t.Errorf("ListObjectsV2 = %+v, want ETag %s", list.Contents, etag)
+	}
+
+	_, body = g.do(http.MethodPut, "/backup/docs/b.txt", nil, nil, http.Header{"X-Amz-Copy-Source": {"/backup/docs/a.txt"}}, http.StatusOK)
+	var copied copyObjectResult
+	if err := xml.Unmarshal(body, &copied); err != nil {
+		t.Fatal(err)
+	}
+	if head, _ := g.do(http.MethodHead, "/backup/docs/b.txt", nil, nil, nil, http.StatusOK); head.Header.Get("ETag") != copied.ETag {
+		t.Errorf("CopyObject ETag %s, HeadObject ETag %s", copied.ETag, head.Header.Get("ETag"))
+	}
+}
+
+func TestListObjectsV2(t *testing.T) {
+	g := newTestGateway(t)
+	for _, p := range []string{"docs/a.txt", "docs/b.txt", "docs/sub/c.txt", "photos/d.jpg", "top.txt"} {
+		g.srv.AddFile("/apps/s3/backup/"+p, []byte(p))
+	}
+
+	list := func(q url.Values) listBucketResultV2 {
+		t.Helper()
+		q.Set("list-type", "2")
+		_, body := g.do(http.MethodGet, "/backup", q, nil, nil, http.StatusOK)
+		var result listBucketResultV2
+		if err := xml.Unmarshal(body, &result); err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+	keys := func(result listBucketResultV2) []string {
+		var keys []string
+		for _, c := range result.Contents {
+			keys = append(keys, c.Key)
+		}
+		for _, p := range result.CommonPrefixes {
+			keys = append(keys, p.Prefix)
+		}
+		sort.Strings(keys)
+		return keys
+	}
+
+	if got, want := keys(list(url.Values{"delimiter": {"/"}})), []string{"docs/", "photos/", "top.txt"}; !slices.Equal(got, want) {
+		t.Errorf("root listing = %v, want %v", got, want)
+	}
+	if got, want := keys(list(url.Values{"prefix": {"docs/"}, "delimiter": {"/"}})), []string{"docs/a.txt", "docs/b.txt", "docs/sub/"}; !slices.Equal(got, want) {
+		t.Errorf("docs/ listing = %v, want %v", got, want)
+	}
+	if got, want := keys(list(url.Values{"prefix": {"docs/"}})), []string{"docs/a.txt", "docs/b.txt", "docs/sub/c.txt"}; !slices.Equal(got, want) {
+		t.Errorf("recursive listing = %v, want %v", got, want)
+	}
+
+	// 分页：max-keys=2 时通过 continuation-token 取得其余的键
+	var all []string
+	q := url.Values{"max-keys": {"2"}}
+	for page := 0; ; page++ {
+		if page > 5 {
+			t.Fatal("listing did not terminate")
+		}
+		result := list(q)
+		all = append(all, keys(result)...)
+		if !result.IsTruncated {
+			break
+		}
+		q = url.Values{"max-keys": {"2"}, "continuation-token": {result.NextContinuationToken}}
+	}
+	if want := []string{"docs/a.txt", "docs/b.txt", "docs/sub/c.txt", "photos/d.jpg", "top.txt"}; !slices.Equal(all, want) {
+		t.Errorf("paged listing = %v, want %v", all, want)
+	}
+}
+
+func TestGetObjectRange(t *testing.T) {
+	g := newTestGateway(t)
+	g.srv.AddFile("/apps/s3/backup/a.txt", []byte("hello, range"))
+
+	resp, body := g.do(http.MethodGet, "/backup/a.txt", nil, nil, http.Header{"Range": {"bytes=7-11"}}, http.StatusPartialContent)
+	if string(body) != "range" {
+		t.Errorf("ranged GET = %q, want %q", body, "range")
+	}
+	if got := resp.Header.Get("Content-Range"); got != "bytes 7-11/12" {
+		t.Errorf("Content-Range = %q", got)
+	}
+	g.do(http.MethodGet, "/backup/a.txt", nil, nil, http.Header{"Range": {"bytes=20-"}}, http.StatusRequestedRangeNotSatisfiable)
+	_, body = g.do(http.MethodGet, "/backup/missing.txt", nil, nil, nil, http.StatusNotFound)
+	if code := errorCode(t, body); code != "NoSuchKey" {
+		t.Errorf("code = %s, want NoSuchKey", code)
+	}
+}
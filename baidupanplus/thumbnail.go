@@ -0,0 +1,291 @@
+package baidupanplus
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/S-zhi/baidupansdk/internal/redact"
+)
+
+// ErrNoThumbnail 文件没有对应尺寸的缩略图（如非图片、视频文件）
+var ErrNoThumbnail = errors.New("thumbnail not available")
+
+// ThumbSize 缩略图尺寸，对应 filemetas 接口 thumbs 中的字段
+type ThumbSize string
+
+const (
+	ThumbIcon   ThumbSize = "icon" // 图标
+	ThumbSmall  ThumbSize = "url1" // 小图
+	ThumbMedium ThumbSize = "url2" // 中图
+	ThumbLarge  ThumbSize = "url3" // 大图
+)
+
+// defaultThumbnailCacheSize 缩略图磁盘缓存的默认容量
+const defaultThumbnailCacheSize = 256 * mb
+
+// maxThumbnailSize 单张缩略图的大小上限，防止异常响应占用过多内存
+const maxThumbnailSize = 16 * mb
+
+// url 返回 thumbs 中 size 对应的地址
+func (t *Thumbs) url(size ThumbSize) string {
+	if t == nil {
+		return ""
+	}
+	switch size {
+	case ThumbIcon:
+		return t.Icon
+	case ThumbSmall:
+		return t.URL1
+	case ThumbMedium:
+		return t.URL2
+	case ThumbLarge:
+		return t.URL3
+	}
+	return ""
+}
+
+// Thumbnail 返回文件 fsID 指定尺寸的缩略图内容
+// 设置了 Config.ThumbnailCacheDir 时缩略图缓存在该目录，命中缓存时不发起任何请求，超出容量时淘汰最久未使用的缩略图
+func (c *Client) Thumbnail(ctx context.Context, fsID int64, size ThumbSize) (_ []byte, err error) {
+	ctx, span := c.startSpan(ctx, "Thumbnail", attrFsID.Int64(fsID))
+	defer func() { endSpan(span, err) }()
+
+	key := fmt.Sprintf("%d_%s", fsID, size)
+	cache := c.thumbnailCache()
+	if cache != nil {
+		if data, ok := cache.get(key); ok {
+			return data, nil
+		}
+	}
+
+	metas, err := c.FileMetas(ctx, []int64{fsID}, FileMetasOptions{Thumb: true})
+	if err != nil {
+		return nil, err
+	}
+	if len(metas) == 0 {
+		return nil, fmt.Errorf("%w: fs_id %d", ErrNotFound, fsID)
+	}
+	thumbURL := metas[0].Thumbs.url(size)
+	if thumbURL == "" {
+		return nil, fmt.Errorf("%w: %s %s", ErrNoThumbnail, metas[0].Path, size)
+	}
+
+	data, err := c.fetchThumbnail(ctx, thumbURL)
+	if err != nil {
+		c.logger.Error("failed to fetch thumbnail", logKeyOp, "thumbnail", logKeyPath, metas[0].Path, logKeyError, err)
+		return nil, err
+	}
+	if cache != nil {
+		if err := cache.put(key, data); err != nil {
+			c.logger.Warn("failed to cache thumbnail", logKeyOp, "thumbnail", logKeyPath, metas[0].Path, logKeyError, err)
+		}
+	}
+	return data, nil
+}
+
+// fetchThumbnail 下载缩略图
+func (c *Client) fetchThumbnail(ctx context.Context, thumbURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, thumbURL, nil)
+	if err != nil {
+		return nil, redact.Error(err)
+	}
+	req.Header.Set("User-Agent", "pan.baidu.com")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, redact.Error(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("thumbnail request failed with status: %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxThumbnailSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxThumbnailSize {
+		return nil, fmt.Errorf("thumbnail larger than %d bytes", maxThumbnailSize)
+	}
+	return data, nil
+}
+
+// thumbnailCache 首次使用时打开缩略图缓存，未配置或打开失败时返回 nil
+func (c *Client) thumbnailCache() *thumbCache {
+	if c.cfg.ThumbnailCacheDir == "" {
+		return nil
+	}
+	c.thumbOnce.Do(func() {
+		maxBytes := c.cfg.ThumbnailCacheSize
+		if maxBytes <= 0 {
+			maxBytes = defaultThumbnailCacheSize
+		}
+		cache, err := openThumbCache(c.cfg.ThumbnailCacheDir, maxBytes)
+		if err != nil {
+			c.logger.Warn("failed to open thumbnail cache, caching disabled", logKeyOp, "thumbnail",
+				logKeyLocalPath, c.cfg.ThumbnailCacheDir, logKeyError, err)
+			return
+		}
+		c.thumbs = cache
+	})
+	return c.thumbs
+}
+
+// thumbCache 按最近使用顺序淘汰的缩略图磁盘缓存，每张缩略图一个带 thumbFileSuffix 后缀的文件
+// 文件的修改时间记录最近一次使用时间，重新打开时据此恢复顺序
+type thumbCache struct {
+	dir      string
+	maxBytes int64
+
+	mu      sync.Mutex
+	size    int64
+	order   *list.List // 元素为 *thumbEntry，队首为最近使用
+	entries map[string]*list.Element
+}
+
+type thumbEntry struct {
+	key  string
+	size int64
+}
+
+// thumbFileSuffix 缓存文件的后缀，缓存目录中只有带该后缀的文件由缓存管理，其它文件不会被加载或淘汰
+const thumbFileSuffix = ".thumb"
+
+// thumbTempPrefix 写入中的临时文件前缀
+const thumbTempPrefix = ".thumb-tmp-"
+
+// openThumbCache 打开 dir 下的缓存，加载已有缩略图并淘汰超出 maxBytes 的部分
+func openThumbCache(dir string, maxBytes int64) (*thumbCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	type cached struct {
+		key   string
+		size  int64
+		mtime time.Time
+	}
+	var files []cached
+	for _, e := range dirEntries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		if strings.HasPrefix(e.Name(), thumbTempPrefix) {
+			_ = os.Remove(filepath.Join(dir, e.Name()))
+			continue
+		}
+		key, ok := strings.CutSuffix(e.Name(), thumbFileSuffix)
+		if !ok {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, cached{key: key, size: info.Size(), mtime: info.ModTime()})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].mtime.After(files[j].mtime) })
+
+	c := &thumbCache{dir: dir, maxBytes: maxBytes, order: list.New(), entries: make(map[string]*list.Element)}
+	for _, f := range files {
+		c.entries[f.key] = c.order.PushBack(&thumbEntry{key: f.key, size: f.size})
+		c.size += f.size
+	}
+	c.mu.Lock()
+	c.evictLocked()
+	c.mu.Unlock()
+	return c, nil
+}
+
+// get 读取 key 对应的缩略图并标记为最近使用
+func (c *thumbCache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	p := c.path(key)
+	data, err := os.ReadFile(p)
+	if err != nil {
+		// 文件被外部删除，移除记录
+		c.removeLocked(elem)
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	now := time.Now()
+	_ = os.Chtimes(p, now, now)
+	return data, true
+}
+
+// put 写入缩略图，超过容量时淘汰最久未使用的缩略图；单张超过容量时不缓存
+func (c *thumbCache) put(key string, data []byte) error {
+	size := int64(len(data))
+	if size > c.maxBytes {
+		return nil
+	}
+	tmp, err := os.CreateTemp(c.dir, thumbTempPrefix+"*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := os.Rename(tmp.Name(), c.path(key)); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if elem, ok := c.entries[key]; ok {
+		c.size -= elem.Value.(*thumbEntry).size
+		c.order.Remove(elem)
+	}
+	c.entries[key] = c.order.PushFront(&thumbEntry{key: key, size: size})
+	c.size += size
+	c.evictLocked()
+	return nil
+}
+
+// evictLocked 从队尾开始删除缩略图，直到总大小不超过容量
+func (c *thumbCache) evictLocked() {
+	for c.size > c.maxBytes {
+		elem := c.order.Back()
+		if elem == nil {
+			return
+		}
+		_ = os.Remove(c.path(elem.Value.(*thumbEntry).key))
+		c.removeLocked(elem)
+	}
+}
+
+// path 返回 key 对应的缓存文件路径
+func (c *thumbCache) path(key string) string {
+	return filepath.Join(c.dir, key+thumbFileSuffix)
+}
+
+func (c *thumbCache) removeLocked(elem *list.Element) {
+	entry := elem.Value.(*thumbEntry)
+	c.order.Remove(elem)
+	delete(c.entries, entry.key)
+	c.size -= entry.size
+}
@@ -0,0 +1,81 @@
+package baidupanplus
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/S-zhi/baidupansdk/baidupantest"
+)
+
+func TestThumbCacheIgnoresForeignFiles(t *testing.T) {
+	dir := t.TempDir()
+	foreign := map[string][]byte{
+		"notes.txt":      bytes.Repeat([]byte("x"), 100),
+		".tmp-unrelated": []byte("keep"),
+		"1_c60_u60.jpg":  []byte("keep"),
+	}
+	for name, data := range foreign {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 容量小于目录中已有文件的总大小，只能淘汰缓存自己的文件
+	cache, err := openThumbCache(dir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cache.entries) != 0 || cache.size != 0 {
+		t.Fatalf("cache loaded %d foreign files (%d bytes)", len(cache.entries), cache.size)
+	}
+	for _, key := range []string{"1_c60_u60", "2_c60_u60"} {
+		if err := cache.put(key, []byte("123456")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok := cache.get("1_c60_u60"); ok {
+		t.Error("least recently used thumbnail was not evicted")
+	}
+	if data, ok := cache.get("2_c60_u60"); !ok || string(data) != "123456" {
+		t.Errorf("get = %q, %v", data, ok)
+	}
+	for name, data := range foreign {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || !bytes.Equal(got, data) {
+			t.Errorf("foreign file %s was modified or removed: %v", name, err)
+		}
+	}
+
+	// 重新打开时只加载缓存文件
+	reopened, err := openThumbCache(dir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reopened.entries["2_c60_u60"]; !ok || len(reopened.entries) != 1 {
+		t.Errorf("reopened cache has %d entries", len(reopened.entries))
+	}
+}
+
+func TestThumbnailCached(t *testing.T) {
+	_, srv := newTestClient(t)
+	cfg := newTestConfig(srv)
+	cfg.ThumbnailCacheDir = t.TempDir()
+	c := NewClient(cfg)
+	fsID := srv.AddFile("/apps/test/a.jpg", []byte("image"))
+
+	for i := 0; i < 2; i++ {
+		data, err := c.Thumbnail(context.Background(), fsID, ThumbIcon)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := baidupantest.ThumbnailData(fsID, "c60_u60"); !bytes.Equal(data, want) {
+			t.Fatalf("thumbnail = %q, want %q", data, want)
+		}
+	}
+	if n := srv.Requests(baidupantest.OpThumbnail); n != 1 {
+		t.Errorf("thumbnail requests = %d, want 1", n)
+	}
+}
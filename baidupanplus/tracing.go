@@ -0,0 +1,54 @@
+package baidupanplus
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName SDK 创建 Tracer 使用的名称
+const tracerName = "github.com/S-zhi/baidupansdk/baidupanplus"
+
+// span 属性名，与日志字段保持一致
+const (
+	attrRemotePath = attribute.Key("baidupan.remote_path")
+	attrLocalPath  = attribute.Key("baidupan.local_path")
+	attrSize       = attribute.Key("baidupan.size")
+	attrPartSeq    = attribute.Key("baidupan.part_seq")
+	attrFsID       = attribute.Key("baidupan.fs_id")
+	attrEndpoint   = attribute.Key("baidupan.endpoint")
+	attrErrno      = attribute.Key("baidupan.errno")
+	attrRequestID  = attribute.Key("baidupan.request_id")
+	attrOffset     = attribute.Key("baidupan.offset")
+	attrHTTPStatus = attribute.Key("http.response.status_code")
+)
+
+// newTracer 返回 provider 的 Tracer，provider 为空时使用 otel 全局 TracerProvider（未设置时不记录）
+func newTracer(provider trace.TracerProvider) trace.Tracer {
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return provider.Tracer(tracerName)
+}
+
+// startSpan 在 ctx 中的 span 下创建名为 baidupan.<name> 的子 span
+func (c *Client) startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return c.tracer.Start(ctx, "baidupan."+name, trace.WithAttributes(attrs...))
+}
+
+// endSpan 结束 span，err 非空时记录错误与 errno
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		var errnoErr *ErrnoError
+		if errors.As(err, &errnoErr) {
+			span.SetAttributes(attrErrno.Int(errnoErr.Errno))
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
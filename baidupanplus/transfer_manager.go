@@ -0,0 +1,593 @@
+package baidupanplus
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrJobNotFound TransferManager 中不存在指定的任务
+var ErrJobNotFound = errors.New("transfer job not found")
+
+// defaultTransferConcurrency TransferManager 默认同时运行的任务数
+const defaultTransferConcurrency = 3
+
+// TransferKind 传输任务类型
+type TransferKind string
+
+const (
+	TransferUpload   TransferKind = "upload"   // 上传本地文件
+	TransferDownload TransferKind = "download" // 下载远程文件
+)
+
+// JobState 传输任务状态
+type JobState string
+
+const (
+	JobQueued    JobState = "queued"    // 等待运行
+	JobRunning   JobState = "running"   // 运行中
+	JobPaused    JobState = "paused"    // 已暂停，Resume 后重新排队
+	JobCompleted JobState = "completed" // 已完成
+	JobFailed    JobState = "failed"    // 已失败，Resume 后从断点重试
+	JobCanceled  JobState = "canceled"  // 已取消，断点状态已清除
+)
+
+// TransferRequest 添加到 TransferManager 的传输任务
+type TransferRequest struct {
+	Kind       TransferKind
+	LocalPath  string
+	RemotePath string
+	Priority   int // 优先级，数值大的先运行，相同时按添加顺序
+
+	Upload   UploadOptions   // 上传任务的选项
+	Download DownloadOptions // 下载任务的选项
+}
+
+// TransferJob 传输任务的状态快照
+type TransferJob struct {
+	ID         string          `json:"id"`
+	Kind       TransferKind    `json:"kind"`
+	LocalPath  string          `json:"local_path"`
+	RemotePath string          `json:"remote_path"`
+	Priority   int             `json:"priority"`
+	Upload     UploadOptions   `json:"upload_options"`
+	Download   DownloadOptions `json:"download_options"`
+
+	State       JobState `json:"state"`
+	Size        int64    `json:"size"`        // 文件大小，下载任务开始运行前为 0
+	Transferred int64    `json:"transferred"` // 已传输字节数，包括之前运行中已完成的部分
+	Error       string   `json:"error,omitempty"`
+
+	// ResultPath 完成后实际写入的路径：上传任务为远程路径，下载任务为本地路径
+	ResultPath string `json:"result_path,omitempty"`
+	Skipped    bool   `json:"skipped,omitempty"` // 因 SkipIfSame 等选项跳过了传输
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TransferEventType 任务生命周期事件类型
+type TransferEventType string
+
+const (
+	EventQueued    TransferEventType = "queued"    // 任务已添加或已恢复排队
+	EventStarted   TransferEventType = "started"   // 任务开始运行
+	EventProgress  TransferEventType = "progress"  // 传输进度
+	EventPaused    TransferEventType = "paused"    // 任务已暂停
+	EventCompleted TransferEventType = "completed" // 任务已完成
+	EventFailed    TransferEventType = "failed"    // 任务已失败
+	EventCanceled  TransferEventType = "canceled"  // 任务已取消
+)
+
+// TransferEvent 任务生命周期事件
+type TransferEvent struct {
+	Type     TransferEventType
+	Job      TransferJob
+	Progress Progress // 仅 EventProgress 有效
+}
+
+// TransferManagerConfig TransferManager 配置
+type TransferManagerConfig struct {
+	// Dir 保存任务队列与断点状态的目录，每个任务一个 JSON 文件，必填
+	Dir string
+
+	// Concurrency 同时运行的任务总数，默认 3
+	Concurrency int
+	// UploadConcurrency / DownloadConcurrency 同时运行的上传、下载任务数上限，<= 0 时只受 Concurrency 限制
+	UploadConcurrency   int
+	DownloadConcurrency int
+
+	// OnEvent 任务生命周期事件回调，各事件依次调用，应尽快返回
+	OnEvent func(TransferEvent)
+	// ProgressInterval EventProgress 的最小间隔，默认 500ms
+	ProgressInterval time.Duration
+}
+
+// TransferManager 持久化的传输队列：任务与断点状态保存在磁盘上，进程重启后重新创建即可继续未完成的任务
+// 上传按分片续传，下载通过 Range 请求从已下载的位置续传
+type TransferManager struct {
+	client *Client
+	cfg    TransferManagerConfig
+
+	mu      sync.Mutex
+	jobs    map[string]*managedJob
+	running map[TransferKind]int
+	closed  bool
+	wg      sync.WaitGroup
+
+	eventMu sync.Mutex
+}
+
+// managedJob 任务及其断点状态，也是保存到磁盘的内容
+type managedJob struct {
+	TransferJob
+	UploadCheckpoint   *uploadCheckpoint   `json:"upload_checkpoint,omitempty"`
+	DownloadCheckpoint *downloadCheckpoint `json:"download_checkpoint,omitempty"`
+
+	// 运行中的任务由所在 goroutine 独占修改断点状态与保存
+	cancel context.CancelFunc
+	stopAs JobState // 运行中被 Pause / Cancel / Close 打断后进入的状态
+}
+
+// NewTransferManager 创建传输队列，加载 cfg.Dir 中保存的任务并开始运行排队中的任务
+// 上次退出时仍在运行的任务重新排队，从断点继续
+func NewTransferManager(client *Client, cfg TransferManagerConfig) (*TransferManager, error) {
+	if cfg.Dir == "" {
+		return nil, fmt.Errorf("transfer manager dir is required")
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = defaultTransferConcurrency
+	}
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, err
+	}
+	m := &TransferManager{client: client, cfg: cfg, jobs: map[string]*managedJob{}, running: map[TransferKind]int{}}
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+	m.schedule()
+	return m, nil
+}
+
+// load 加载保存的任务
+func (m *TransferManager) load() error {
+	files, err := filepath.Glob(filepath.Join(m.cfg.Dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		j := &managedJob{}
+		if err := json.Unmarshal(data, j); err != nil {
+			m.client.logger.Warn("忽略无法解析的任务文件", logKeyOp, "transfer", logKeyLocalPath, file, logKeyError, err)
+			continue
+		}
+		if j.State == JobRunning {
+			j.State = JobQueued
+		}
+		m.jobs[j.ID] = j
+	}
+	return nil
+}
+
+// Add 添加任务，任务立即保存到磁盘并排队
+func (m *TransferManager) Add(req TransferRequest) (TransferJob, error) {
+	if req.Kind != TransferUpload && req.Kind != TransferDownload {
+		return TransferJob{}, fmt.Errorf("invalid transfer kind: %q", req.Kind)
+	}
+	if req.LocalPath == "" || req.RemotePath == "" {
+		return TransferJob{}, fmt.Errorf("local path and remote path are required")
+	}
+	id, err := newJobID()
+	if err != nil {
+		return TransferJob{}, err
+	}
+	now := time.Now()
+	j := &managedJob{TransferJob: TransferJob{
+		ID:         id,
+		Kind:       req.Kind,
+		LocalPath:  req.LocalPath,
+		RemotePath: req.RemotePath,
+		Priority:   req.Priority,
+		Upload:     req.Upload,
+		Download:   req.Download,
+		State:      JobQueued,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}}
+	if req.Kind == TransferUpload {
+		if st, err := os.Stat(req.LocalPath); err == nil {
+			j.Size = st.Size()
+		}
+	}
+
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return TransferJob{}, fmt.Errorf("transfer manager closed")
+	}
+	if err := m.saveLocked(j); err != nil {
+		m.mu.Unlock()
+		return TransferJob{}, err
+	}
+	m.jobs[id] = j
+	snapshot := j.TransferJob
+	m.mu.Unlock()
+
+	m.emit(TransferEvent{Type: EventQueued, Job: snapshot})
+	m.schedule()
+	return snapshot, nil
+}
+
+// Job 返回任务的状态快照
+func (m *TransferManager) Job(id string) (TransferJob, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return TransferJob{}, false
+	}
+	return j.TransferJob, true
+}
+
+// Jobs 按运行顺序返回全部任务的状态快照
+func (m *TransferManager) Jobs() []TransferJob {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	jobs := make([]TransferJob, 0, len(m.jobs))
+	for _, j := range m.sortedLocked() {
+		jobs = append(jobs, j.TransferJob)
+	}
+	return jobs
+}
+
+// Pause 暂停排队中或运行中的任务，断点状态保留；运行中的任务在当前请求中断后进入 JobPaused
+func (m *TransferManager) Pause(id string) error {
+	return m.stop(id, JobPaused)
+}
+
+// Cancel 取消未结束的任务并清除断点状态与已下载的临时文件
+func (m *TransferManager) Cancel(id string) error {
+	return m.stop(id, JobCanceled)
+}
+
+// stop 将任务转为 JobPaused 或 JobCanceled
+func (m *TransferManager) stop(id string, state JobState) error {
+	m.mu.Lock()
+	j, ok := m.jobs[id]
+	if !ok {
+		m.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrJobNotFound, id)
+	}
+	switch j.State {
+	case JobRunning:
+		j.stopAs = state
+		j.cancel()
+		m.mu.Unlock()
+		return nil
+	case JobCompleted, JobCanceled:
+		m.mu.Unlock()
+		return fmt.Errorf("transfer job %s already %s", id, j.State)
+	case JobPaused, JobFailed:
+		if state == JobPaused {
+			m.mu.Unlock()
+			return nil
+		}
+	}
+	if state == JobCanceled {
+		j.clearCheckpoint()
+	}
+	j.setState(state, nil)
+	err := m.saveLocked(j)
+	snapshot := j.TransferJob
+	m.mu.Unlock()
+
+	m.emit(TransferEvent{Type: eventOf(state), Job: snapshot})
+	return err
+}
+
+// Resume 将暂停或失败的任务重新排队，从断点继续
+func (m *TransferManager) Resume(id string) error {
+	m.mu.Lock()
+	j, ok := m.jobs[id]
+	if !ok {
+		m.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrJobNotFound, id)
+	}
+	if j.State == JobRunning && j.stopAs == JobPaused {
+		// 暂停尚未生效，中断后直接重新排队
+		j.stopAs = JobQueued
+		m.mu.Unlock()
+		return nil
+	}
+	if j.State != JobPaused && j.State != JobFailed {
+		m.mu.Unlock()
+		return fmt.Errorf("transfer job %s is %s", id, j.State)
+	}
+	j.setState(JobQueued, nil)
+	err := m.saveLocked(j)
+	snapshot := j.TransferJob
+	m.mu.Unlock()
+
+	m.emit(TransferEvent{Type: EventQueued, Job: snapshot})
+	m.schedule()
+	return err
+}
+
+// Remove 删除未在运行的任务及其保存的状态，未结束的任务同时清除断点状态
+func (m *TransferManager) Remove(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrJobNotFound, id)
+	}
+	if j.State == JobRunning {
+		return fmt.Errorf("transfer job %s is running", id)
+	}
+	j.clearCheckpoint()
+	delete(m.jobs, id)
+	if err := os.Remove(m.jobFile(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Close 停止调度并中断运行中的任务，等待它们保存断点后返回
+// 被中断的任务保存为排队状态，下次创建 TransferManager 时继续
+func (m *TransferManager) Close() error {
+	m.mu.Lock()
+	m.closed = true
+	for _, j := range m.jobs {
+		if j.State == JobRunning {
+			j.stopAs = JobQueued
+			j.cancel()
+		}
+	}
+	m.mu.Unlock()
+	m.wg.Wait()
+	return nil
+}
+
+// schedule 按优先级启动排队中的任务，直到达到并发上限
+func (m *TransferManager) schedule() {
+	type start struct {
+		job *managedJob
+		ctx context.Context
+	}
+	var started []start
+	var events []TransferEvent
+
+	m.mu.Lock()
+	for _, j := range m.sortedLocked() {
+		if m.closed || m.running[TransferUpload]+m.running[TransferDownload] >= m.cfg.Concurrency {
+			break
+		}
+		if j.State != JobQueued || m.kindFullLocked(j.Kind) {
+			continue
+		}
+		jobCtx, cancel := context.WithCancel(context.Background())
+		j.cancel = cancel
+		j.stopAs = ""
+		j.setState(JobRunning, nil)
+		if err := m.saveLocked(j); err != nil {
+			m.client.logger.Warn("保存任务状态失败", logKeyOp, "transfer", "job", j.ID, logKeyError, err)
+		}
+		m.running[j.Kind]++
+		m.wg.Add(1)
+		started = append(started, start{job: j, ctx: jobCtx})
+		events = append(events, TransferEvent{Type: EventStarted, Job: j.TransferJob})
+	}
+	m.mu.Unlock()
+
+	for i, s := range started {
+		m.emit(events[i])
+		go m.run(s.ctx, s.job)
+	}
+}
+
+// kindFullLocked 该类型的任务是否已达到单独的并发上限
+func (m *TransferManager) kindFullLocked(kind TransferKind) bool {
+	limit := m.cfg.UploadConcurrency
+	if kind == TransferDownload {
+		limit = m.cfg.DownloadConcurrency
+	}
+	return limit > 0 && m.running[kind] >= limit
+}
+
+// run 运行任务，结束后根据结果与中断原因更新状态
+func (m *TransferManager) run(ctx context.Context, j *managedJob) {
+	defer m.wg.Done()
+	ctx = WithProgress(ctx, func(p Progress) { m.onProgress(j, p) }, m.cfg.ProgressInterval)
+	save := func() error {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		return m.saveLocked(j)
+	}
+
+	var resultPath string
+	var skipped bool
+	var err error
+	switch j.Kind {
+	case TransferUpload:
+		if j.UploadCheckpoint == nil {
+			j.UploadCheckpoint = &uploadCheckpoint{}
+		}
+		var res *UploadResult
+		if res, err = m.client.uploadResumable(ctx, j.LocalPath, j.RemotePath, j.Upload, j.UploadCheckpoint, save); err == nil {
+			resultPath, skipped = res.Path, res.Skipped
+		}
+	case TransferDownload:
+		if j.DownloadCheckpoint == nil {
+			j.DownloadCheckpoint = &downloadCheckpoint{}
+		}
+		var res *DownloadResult
+		if res, err = m.client.downloadResumable(ctx, j.RemotePath, j.LocalPath, j.Download, j.DownloadCheckpoint, save); err == nil {
+			resultPath, skipped = res.LocalPath, res.Skipped
+		}
+	}
+
+	m.mu.Lock()
+	j.cancel()
+	m.running[j.Kind]--
+	switch {
+	case err == nil:
+		j.clearCheckpoint()
+		j.ResultPath, j.Skipped = resultPath, skipped
+		if !skipped {
+			j.Transferred = j.Size
+		}
+		j.setState(JobCompleted, nil)
+	case j.stopAs == JobCanceled:
+		j.clearCheckpoint()
+		j.setState(JobCanceled, nil)
+	case j.stopAs != "":
+		j.Transferred = j.checkpointBytes()
+		j.setState(j.stopAs, nil)
+	default:
+		j.Transferred = j.checkpointBytes()
+		m.client.logger.Error("传输任务失败", logKeyOp, "transfer", "job", j.ID, logKeyPath, j.RemotePath, logKeyError, err)
+		j.setState(JobFailed, err)
+	}
+	if err := m.saveLocked(j); err != nil {
+		m.client.logger.Warn("保存任务状态失败", logKeyOp, "transfer", "job", j.ID, logKeyError, err)
+	}
+	snapshot := j.TransferJob
+	m.mu.Unlock()
+
+	if snapshot.State != JobQueued {
+		m.emit(TransferEvent{Type: eventOf(snapshot.State), Job: snapshot})
+	}
+	m.schedule()
+}
+
+// onProgress 更新任务的传输字节数并发出 EventProgress
+func (m *TransferManager) onProgress(j *managedJob, p Progress) {
+	m.mu.Lock()
+	if p.Phase == PhaseUploading || p.Phase == PhaseDownloading {
+		j.Transferred = p.Transferred
+		if p.Total >= 0 {
+			j.Size = p.Total
+		}
+	}
+	snapshot := j.TransferJob
+	m.mu.Unlock()
+	m.emit(TransferEvent{Type: EventProgress, Job: snapshot, Progress: p})
+}
+
+// emit 依次调用 OnEvent
+func (m *TransferManager) emit(e TransferEvent) {
+	if m.cfg.OnEvent == nil {
+		return
+	}
+	m.eventMu.Lock()
+	defer m.eventMu.Unlock()
+	m.cfg.OnEvent(e)
+}
+
+// sortedLocked 按优先级从高到低、添加时间从早到晚排列任务
+func (m *TransferManager) sortedLocked() []*managedJob {
+	jobs := make([]*managedJob, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		jobs = append(jobs, j)
+	}
+	sort.Slice(jobs, func(a, b int) bool {
+		if jobs[a].Priority != jobs[b].Priority {
+			return jobs[a].Priority > jobs[b].Priority
+		}
+		if !jobs[a].CreatedAt.Equal(jobs[b].CreatedAt) {
+			return jobs[a].CreatedAt.Before(jobs[b].CreatedAt)
+		}
+		return jobs[a].ID < jobs[b].ID
+	})
+	return jobs
+}
+
+// saveLocked 将任务原子写入 cfg.Dir
+func (m *TransferManager) saveLocked(j *managedJob) error {
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(m.jobFile(j.ID), false, time.Time{}, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+func (m *TransferManager) jobFile(id string) string {
+	return filepath.Join(m.cfg.Dir, id+".json")
+}
+
+// setState 更新任务状态与错误信息
+func (j *managedJob) setState(state JobState, err error) {
+	j.State = state
+	j.Error = ""
+	if err != nil {
+		j.Error = err.Error()
+	}
+	j.UpdatedAt = time.Now()
+}
+
+// checkpointBytes 断点状态中已完成传输的字节数
+func (j *managedJob) checkpointBytes() int64 {
+	var n int64
+	if cp := j.UploadCheckpoint; cp != nil {
+		for i, done := range cp.Done {
+			if done {
+				n += min(cp.ChunkSize, cp.Size-int64(i)*cp.ChunkSize)
+			}
+		}
+	}
+	if cp := j.DownloadCheckpoint; cp != nil && cp.TempPath != "" {
+		if st, err := os.Stat(cp.TempPath); err == nil {
+			n = st.Size()
+		}
+	}
+	return n
+}
+
+// clearCheckpoint 清除断点状态并删除下载的临时文件
+func (j *managedJob) clearCheckpoint() {
+	if cp := j.DownloadCheckpoint; cp != nil && cp.TempPath != "" {
+		_ = os.Remove(cp.TempPath)
+	}
+	j.UploadCheckpoint = nil
+	j.DownloadCheckpoint = nil
+}
+
+// eventOf 任务进入 state 时对应的事件类型
+func eventOf(state JobState) TransferEventType {
+	switch state {
+	case JobPaused:
+		return EventPaused
+	case JobCompleted:
+		return EventCompleted
+	case JobFailed:
+		return EventFailed
+	case JobCanceled:
+		return EventCanceled
+	}
+	return EventQueued
+}
+
+// newJobID 生成随机任务 ID
+func newJobID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,512 @@
+package baidupanplus
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/S-zhi/baidupansdk/baidupantest"
+)
+
+// transferRecorder 记录分片上传请求的 partseq 与 dlink 请求的 Range
+type transferRecorder struct {
+	base http.RoundTripper
+
+	mu       sync.Mutex
+	requests []string
+}
+
+func (r *transferRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var record string
+	switch {
+	case req.URL.Query().Get("method") == "upload":
+		record = "partseq=" + req.URL.Query().Get("partseq")
+	case strings.HasSuffix(req.URL.Path, "/dlink"):
+		record = "range=" + req.Header.Get("Range")
+	}
+	if record != "" {
+		r.mu.Lock()
+		r.requests = append(r.requests, record)
+		r.mu.Unlock()
+	}
+	return r.base.RoundTrip(req)
+}
+
+// count 返回记录中等于 record 的次数
+func (r *transferRecorder) count(record string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, got := range r.requests {
+		if got == record {
+			n++
+		}
+	}
+	return n
+}
+
+// uploadHold 让分片上传请求保持运行的时间
+// 模拟服务在读取请求体之前等待，客户端中断请求后服务端仍会等满这段时间，因此不宜过长
+const uploadHold = time.Second
+
+// newRecordedClient 创建请求经过 transferRecorder 的客户端
+func newRecordedClient(t *testing.T) (*Client, *baidupantest.Server, *transferRecorder) {
+	t.Helper()
+	_, srv := newTestClient(t)
+	recorder := &transferRecorder{base: srv.Client().Transport}
+	cfg := newTestConfig(srv)
+	cfg.HTTPClient = &http.Client{Transport: recorder}
+	return NewClient(cfg), srv, recorder
+}
+
+// newTestManager 创建以 dir 保存状态的 TransferManager，测试结束时关闭
+func newTestManager(t *testing.T, c *Client, cfg TransferManagerConfig) *TransferManager {
+	t.Helper()
+	m, err := NewTransferManager(c, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = m.Close() })
+	return m
+}
+
+// waitUntil 轮询 cond 直到返回 true，超时后终止测试
+func waitUntil(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// waitJobState 等待任务进入 state
+func waitJobState(t *testing.T, m *TransferManager, id string, state JobState) TransferJob {
+	t.Helper()
+	var job TransferJob
+	waitUntil(t, fmt.Sprintf("job %s to be %s", id, state), func() bool {
+		job, _ = m.Job(id)
+		return job.State == state
+	})
+	return job
+}
+
+// savedJob 读取保存在 dir 中的任务
+func savedJob(t *testing.T, dir string, id string) *managedJob {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, id+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	j := &managedJob{}
+	if err := json.Unmarshal(data, j); err != nil {
+		t.Fatal(err)
+	}
+	return j
+}
+
+func TestTransferManagerPersistsJobs(t *testing.T) {
+	c, srv, recorder := newRecordedClient(t)
+	stateDir := t.TempDir()
+	srv.AddFile("/apps/test/remote.bin", testData(3000))
+	srv.InjectFault(baidupantest.OpUpload, baidupantest.Fault{Latency: uploadHold})
+
+	m1, err := NewTransferManager(c, TransferManagerConfig{Dir: stateDir, Concurrency: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := m1.Add(TransferRequest{Kind: TransferUpload, LocalPath: writeTestFile(t, testData(1000)), RemotePath: "/apps/test/a.bin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := m1.Add(TransferRequest{Kind: TransferUpload, LocalPath: writeTestFile(t, testData(2000)), RemotePath: "/apps/test/b.bin", Priority: 2,
+		Upload: UploadOptions{OnConflict: ConflictOverwrite}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	local := filepath.Join(t.TempDir(), "remote.bin")
+	d, err := m1.Add(TransferRequest{Kind: TransferDownload, LocalPath: local, RemotePath: "/apps/test/remote.bin",
+		Download: DownloadOptions{OnConflict: LocalConflictRename}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m1.Pause(d.ID); err != nil {
+		t.Fatal(err)
+	}
+	waitUntil(t, "the first upload request", func() bool { return recorder.count("partseq=0") == 1 })
+	if err := m1.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m1.Add(TransferRequest{Kind: TransferUpload, LocalPath: local, RemotePath: "/apps/test/c.bin"}); err == nil {
+		t.Error("Add succeeded on a closed manager")
+	}
+
+	// 运行中被关闭的任务保存为排队状态，暂停的任务保持暂停
+	if j := savedJob(t, stateDir, a.ID); j.State != JobQueued || j.UploadCheckpoint == nil || j.UploadCheckpoint.UploadID == "" {
+		t.Errorf("saved running job = %s with checkpoint %+v, want queued with an upload id", j.State, j.UploadCheckpoint)
+	}
+	if j := savedJob(t, stateDir, d.ID); j.State != JobPaused {
+		t.Errorf("saved paused job = %s", j.State)
+	}
+	// 无法解析的任务文件被忽略
+	if err := os.WriteFile(filepath.Join(stateDir, "broken.json"), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.ClearFaults()
+	var mu sync.Mutex
+	var started []string
+	m2 := newTestManager(t, c, TransferManagerConfig{Dir: stateDir, Concurrency: 1, OnEvent: func(e TransferEvent) {
+		if e.Type == EventStarted {
+			mu.Lock()
+			started = append(started, e.Job.ID)
+			mu.Unlock()
+		}
+	}})
+	jobs := m2.Jobs()
+	if len(jobs) != 3 || jobs[0].ID != b.ID || jobs[1].ID != a.ID || jobs[2].ID != d.ID {
+		t.Fatalf("reloaded jobs = %+v, want b, a, d", jobs)
+	}
+	if jobs[0].Upload.OnConflict != ConflictOverwrite || jobs[2].Download.OnConflict != LocalConflictRename {
+		t.Errorf("reloaded options = %+v / %+v", jobs[0].Upload, jobs[2].Download)
+	}
+
+	waitJobState(t, m2, a.ID, JobCompleted)
+	waitJobState(t, m2, b.ID, JobCompleted)
+	if job, _ := m2.Job(d.ID); job.State != JobPaused {
+		t.Errorf("paused job ran after reload: %s", job.State)
+	}
+	mu.Lock()
+	if !slices.Equal(started, []string{b.ID, a.ID}) {
+		t.Errorf("start order after reload = %v, want b, a", started)
+	}
+	mu.Unlock()
+	if got, _ := srv.ReadFile("/apps/test/a.bin"); !bytes.Equal(got, testData(1000)) {
+		t.Error("a.bin content differs")
+	}
+	if got, _ := srv.ReadFile("/apps/test/b.bin"); !bytes.Equal(got, testData(2000)) {
+		t.Error("b.bin content differs")
+	}
+
+	if err := m2.Resume(d.ID); err != nil {
+		t.Fatal(err)
+	}
+	job := waitJobState(t, m2, d.ID, JobCompleted)
+	if job.ResultPath != local || job.Size != 3000 || job.Transferred != 3000 {
+		t.Errorf("download job = %+v", job)
+	}
+	if got, _ := os.ReadFile(local); !bytes.Equal(got, testData(3000)) {
+		t.Error("downloaded content differs")
+	}
+	// 完成的任务清除断点状态
+	if j := savedJob(t, stateDir, d.ID); j.State != JobCompleted || j.DownloadCheckpoint != nil {
+		t.Errorf("saved completed job = %s with checkpoint %+v", j.State, j.DownloadCheckpoint)
+	}
+}
+
+func TestTransferManagerResumesUploadAfterRestart(t *testing.T) {
+	c, srv, recorder := newRecordedClient(t)
+	stateDir := t.TempDir()
+	data := testData(8<<20 + 1) // 3 个 4MB 分片
+	local := writeTestFile(t, data)
+	// 第一个分片正常上传，之后的分片一直等待，直到关闭
+	srv.InjectFault(baidupantest.OpUpload, baidupantest.Fault{Times: 1})
+	srv.InjectFault(baidupantest.OpUpload, baidupantest.Fault{Latency: uploadHold})
+
+	m1, err := NewTransferManager(c, TransferManagerConfig{Dir: stateDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := m1.Add(TransferRequest{Kind: TransferUpload, LocalPath: local, RemotePath: "/apps/test/a.bin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitUntil(t, "the second part", func() bool { return recorder.count("partseq=1") == 1 })
+	if err := m1.Close(); err != nil {
+		t.Fatal(err)
+	}
+	saved := savedJob(t, stateDir, job.ID)
+	if saved.State != JobQueued || saved.Transferred != 4<<20 || !slices.Equal(saved.UploadCheckpoint.Done, []bool{true, false, false}) {
+		t.Fatalf("saved job = %s, %d bytes, checkpoint %+v", saved.State, saved.Transferred, saved.UploadCheckpoint)
+	}
+
+	srv.ClearFaults()
+	m2 := newTestManager(t, c, TransferManagerConfig{Dir: stateDir})
+	done := waitJobState(t, m2, job.ID, JobCompleted)
+	if done.Transferred != int64(len(data)) || done.ResultPath != "/apps/test/a.bin" {
+		t.Errorf("completed job = %+v", done)
+	}
+	// 沿用断点中的 uploadid，只上传未完成的分片
+	if n := srv.Requests(baidupantest.OpPrecreate); n != 1 {
+		t.Errorf("precreate requests = %d, want 1", n)
+	}
+	if n := recorder.count("partseq=0"); n != 1 {
+		t.Errorf("part 0 uploaded %d times, want 1", n)
+	}
+	if n := recorder.count("partseq=2"); n != 1 {
+		t.Errorf("part 2 uploaded %d times, want 1", n)
+	}
+	if got, _ := srv.ReadFile("/apps/test/a.bin"); !bytes.Equal(got, data) {
+		t.Error("uploaded content differs")
+	}
+}
+
+func TestTransferManagerResumesDownloadAfterRestart(t *testing.T) {
+	c, srv, recorder := newRecordedClient(t)
+	stateDir := t.TempDir()
+	data := testData(3<<20 + 7)
+	srv.AddFile("/apps/test/a.bin", data)
+	// 第一次请求输出 1MB 后断开，续传请求一直等待，直到关闭
+	srv.InjectFault(baidupantest.OpDownload, baidupantest.Fault{DropAfter: 1 << 20, Times: 1})
+	srv.InjectFault(baidupantest.OpDownload, baidupantest.Fault{Latency: 5 * time.Second})
+	local := filepath.Join(t.TempDir(), "a.bin")
+
+	m1, err := NewTransferManager(c, TransferManagerConfig{Dir: stateDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := m1.Add(TransferRequest{Kind: TransferDownload, LocalPath: local, RemotePath: "/apps/test/a.bin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resume := fmt.Sprintf("range=bytes=%d-", 1<<20)
+	waitUntil(t, "the resumed request", func() bool { return recorder.count(resume) == 1 })
+	if err := m1.Close(); err != nil {
+		t.Fatal(err)
+	}
+	saved := savedJob(t, stateDir, job.ID)
+	if saved.State != JobQueued || saved.Transferred != 1<<20 || saved.DownloadCheckpoint == nil {
+		t.Fatalf("saved job = %s, %d bytes, checkpoint %+v", saved.State, saved.Transferred, saved.DownloadCheckpoint)
+	}
+
+	srv.ClearFaults()
+	m2 := newTestManager(t, c, TransferManagerConfig{Dir: stateDir})
+	done := waitJobState(t, m2, job.ID, JobCompleted)
+	if done.Transferred != int64(len(data)) || done.ResultPath != local {
+		t.Errorf("completed job = %+v", done)
+	}
+	// 只有第一次请求从头下载，重启后从临时文件的末尾继续
+	if n := recorder.count("range="); n != 1 {
+		t.Errorf("%d requests started from the beginning, want 1", n)
+	}
+	if n := recorder.count(resume); n != 2 {
+		t.Errorf("resumed requests = %d, want 2", n)
+	}
+	if got, _ := os.ReadFile(local); !bytes.Equal(got, data) {
+		t.Error("downloaded content differs")
+	}
+	if _, err := os.Stat(saved.DownloadCheckpoint.TempPath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("temp file left behind: %v", err)
+	}
+}
+
+func TestTransferManagerPauseResumeCancel(t *testing.T) {
+	c, srv, recorder := newRecordedClient(t)
+	stateDir := t.TempDir()
+	srv.InjectFault(baidupantest.OpUpload, baidupantest.Fault{Latency: uploadHold})
+	var mu sync.Mutex
+	events := map[string][]TransferEventType{}
+	m := newTestManager(t, c, TransferManagerConfig{Dir: stateDir, Concurrency: 1, OnEvent: func(e TransferEvent) {
+		if e.Type != EventProgress {
+			mu.Lock()
+			events[e.Job.ID] = append(events[e.Job.ID], e.Type)
+			mu.Unlock()
+		}
+	}})
+
+	a, err := m.Add(TransferRequest{Kind: TransferUpload, LocalPath: writeTestFile(t, testData(1000)), RemotePath: "/apps/test/a.bin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 并发为 1，b 在 a 运行期间保持排队
+	b, err := m.Add(TransferRequest{Kind: TransferUpload, LocalPath: writeTestFile(t, testData(2000)), RemotePath: "/apps/test/b.bin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitUntil(t, "the first upload request", func() bool { return recorder.count("partseq=0") == 1 })
+
+	// 暂停运行中的任务，断点保留
+	if err := m.Pause(a.ID); err != nil {
+		t.Fatal(err)
+	}
+	waitJobState(t, m, a.ID, JobPaused)
+	if j := savedJob(t, stateDir, a.ID); j.UploadCheckpoint == nil || j.UploadCheckpoint.UploadID == "" {
+		t.Error("pause dropped the upload checkpoint")
+	}
+	// a 暂停后 b 开始运行，取消 b 清除断点
+	waitUntil(t, "b to start", func() bool { return recorder.count("partseq=0") == 2 })
+	if err := m.Cancel(b.ID); err != nil {
+		t.Fatal(err)
+	}
+	waitJobState(t, m, b.ID, JobCanceled)
+	if j := savedJob(t, stateDir, b.ID); j.UploadCheckpoint != nil {
+		t.Error("cancel kept the upload checkpoint")
+	}
+	if err := m.Resume(b.ID); err == nil {
+		t.Error("resumed a canceled job")
+	}
+	if err := m.Cancel(b.ID); err == nil {
+		t.Error("canceled a job twice")
+	}
+
+	srv.ClearFaults()
+	if err := m.Resume(a.ID); err != nil {
+		t.Fatal(err)
+	}
+	waitJobState(t, m, a.ID, JobCompleted)
+	if n := srv.Requests(baidupantest.OpPrecreate); n != 2 {
+		t.Errorf("precreate requests = %d, want 2 (one per job)", n)
+	}
+	if srv.Exists("/apps/test/b.bin") {
+		t.Error("canceled upload created the remote file")
+	}
+
+	mu.Lock()
+	wantA := []TransferEventType{EventQueued, EventStarted, EventPaused, EventQueued, EventStarted, EventCompleted}
+	wantB := []TransferEventType{EventQueued, EventStarted, EventCanceled}
+	if !slices.Equal(events[a.ID], wantA) || !slices.Equal(events[b.ID], wantB) {
+		t.Errorf("events a = %v, b = %v", events[a.ID], events[b.ID])
+	}
+	mu.Unlock()
+
+	for _, err := range []error{m.Pause("missing"), m.Resume("missing"), m.Cancel("missing"), m.Remove("missing")} {
+		if !errors.Is(err, ErrJobNotFound) {
+			t.Errorf("error = %v, want ErrJobNotFound", err)
+		}
+	}
+	if err := m.Remove(a.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.Job(a.ID); ok {
+		t.Error("removed job still listed")
+	}
+	if _, err := os.Stat(filepath.Join(stateDir, a.ID+".json")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("removed job file: %v", err)
+	}
+}
+
+func TestTransferManagerCancelDownloadRemovesTempFile(t *testing.T) {
+	c, srv, recorder := newRecordedClient(t)
+	srv.AddFile("/apps/test/a.bin", testData(2<<20))
+	srv.InjectFault(baidupantest.OpDownload, baidupantest.Fault{DropAfter: 1 << 20, Times: 1})
+	srv.InjectFault(baidupantest.OpDownload, baidupantest.Fault{Latency: 5 * time.Second})
+	dir := t.TempDir()
+	m := newTestManager(t, c, TransferManagerConfig{Dir: t.TempDir()})
+
+	job, err := m.Add(TransferRequest{Kind: TransferDownload, LocalPath: filepath.Join(dir, "a.bin"), RemotePath: "/apps/test/a.bin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitUntil(t, "the resumed request", func() bool { return recorder.count(fmt.Sprintf("range=bytes=%d-", 1<<20)) == 1 })
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("download dir has %d entries, want the temp file", len(entries))
+	}
+	if err := m.Cancel(job.ID); err != nil {
+		t.Fatal(err)
+	}
+	waitJobState(t, m, job.ID, JobCanceled)
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("cancel left %s behind", entries[0].Name())
+	}
+}
+
+func TestTransferManagerPriority(t *testing.T) {
+	c, srv := newTestClient(t)
+	// 第一个任务运行期间添加其余任务，它们按优先级排队
+	srv.InjectFault(baidupantest.OpUpload, baidupantest.Fault{Latency: 200 * time.Millisecond, Times: 1})
+	var mu sync.Mutex
+	var started []string
+	m := newTestManager(t, c, TransferManagerConfig{Dir: t.TempDir(), Concurrency: 1, OnEvent: func(e TransferEvent) {
+		if e.Type == EventStarted {
+			mu.Lock()
+			started = append(started, filepath.Base(e.Job.RemotePath))
+			mu.Unlock()
+		}
+	}})
+
+	local := writeTestFile(t, testData(100))
+	var ids []string
+	for _, req := range []struct {
+		name     string
+		priority int
+	}{{"first", 0}, {"p1", 1}, {"p5a", 5}, {"p0", 0}, {"p5b", 5}, {"neg", -1}} {
+		job, err := m.Add(TransferRequest{Kind: TransferUpload, LocalPath: local, RemotePath: "/apps/test/" + req.name, Priority: req.priority})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, job.ID)
+	}
+	for _, id := range ids {
+		waitJobState(t, m, id, JobCompleted)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{"first", "p5a", "p5b", "p1", "p0", "neg"}; !slices.Equal(started, want) {
+		t.Errorf("start order = %v, want %v", started, want)
+	}
+}
+
+func TestTransferManagerKindConcurrency(t *testing.T) {
+	c, srv := newTestClient(t)
+	srv.AddFile("/apps/test/remote.bin", testData(100))
+	srv.InjectFault(baidupantest.OpUpload, baidupantest.Fault{Latency: uploadHold})
+	m := newTestManager(t, c, TransferManagerConfig{Dir: t.TempDir(), UploadConcurrency: 1})
+
+	local := writeTestFile(t, testData(100))
+	var uploads []string
+	for i := 0; i < 2; i++ {
+		job, err := m.Add(TransferRequest{Kind: TransferUpload, LocalPath: local, RemotePath: fmt.Sprintf("/apps/test/u%d", i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		uploads = append(uploads, job.ID)
+	}
+	// 上传达到单独的上限时下载仍可运行
+	download, err := m.Add(TransferRequest{Kind: TransferDownload, LocalPath: filepath.Join(t.TempDir(), "remote.bin"), RemotePath: "/apps/test/remote.bin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job, _ := m.Job(download.ID); job.State != JobRunning {
+		t.Errorf("download = %s, want running", job.State)
+	}
+	if job, _ := m.Job(uploads[1]); job.State != JobQueued {
+		t.Errorf("second upload = %s, want queued", job.State)
+	}
+	waitJobState(t, m, download.ID, JobCompleted)
+	srv.ClearFaults()
+	if err := m.Cancel(uploads[0]); err != nil {
+		t.Fatal(err)
+	}
+	waitJobState(t, m, uploads[1], JobCompleted)
+}
+
+func TestTransferManagerValidation(t *testing.T) {
+	c, _ := newTestClient(t)
+	if _, err := NewTransferManager(c, TransferManagerConfig{}); err == nil {
+		t.Error("created a manager without a state dir")
+	}
+	m := newTestManager(t, c, TransferManagerConfig{Dir: t.TempDir()})
+	for _, req := range []TransferRequest{
+		{Kind: "sync", LocalPath: "a", RemotePath: "/a"},
+		{Kind: TransferUpload, RemotePath: "/a"},
+		{Kind: TransferDownload, LocalPath: "a"},
+	} {
+		if _, err := m.Add(req); err == nil {
+			t.Errorf("Add(%+v) succeeded", req)
+		}
+	}
+	if len(m.Jobs()) != 0 {
+		t.Error("invalid requests were queued")
+	}
+}
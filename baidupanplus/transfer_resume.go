@@ -0,0 +1,248 @@
+package baidupanplus
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// uploadCheckpoint 可续传上传的断点状态：预上传得到的 uploadid 与已上传的分片
+type uploadCheckpoint struct {
+	UploadID  string    `json:"upload_id"`
+	BlockList []string  `json:"block_list"`
+	ChunkSize int64     `json:"chunk_size"`
+	Size      int64     `json:"size"`
+	ModTime   time.Time `json:"mod_time"` // 本地文件的修改时间，变化时重新上传
+	Done      []bool    `json:"done"`     // 各分片是否已上传
+}
+
+// downloadCheckpoint 可续传下载的断点状态，已下载的数据保存在 TempPath 中
+type downloadCheckpoint struct {
+	LocalPath   string `json:"local_path"` // 实际写入的本地路径，重命名策略下确定后不再变化
+	TempPath    string `json:"temp_path"`
+	FsID        int64  `json:"fs_id"`
+	Size        int64  `json:"size"`
+	ServerMtime int64  `json:"server_mtime"` // 远程文件变化时丢弃已下载的数据
+}
+
+// uploadResumable 按 cp 续传本地文件，cp 为空时从头开始；每完成一步都会调用 save 保存 cp
+// 本地文件的大小或修改时间变化时丢弃断点重新上传
+func (c *Client) uploadResumable(ctx context.Context, localPath string, remotePath string, opts UploadOptions, cp *uploadCheckpoint, save func() error) (_ *UploadResult, err error) {
+	ctx, span := c.startSpan(ctx, "UploadFile", attrRemotePath.String(remotePath), attrLocalPath.String(localPath))
+	defer func() { endSpan(span, err) }()
+
+	defer c.trackTransfer(DirectionUpload)()
+	progress := progressFromContext(ctx)
+	st, err := os.Stat(localPath)
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttributes(attrSize.Int64(st.Size()))
+	if cp.UploadID != "" && (cp.Size != st.Size() || !cp.ModTime.Equal(st.ModTime())) {
+		c.logger.Info("本地文件已变化，重新上传", logKeyOp, "upload", logKeyPath, remotePath, logKeyLocalPath, localPath)
+		*cp = uploadCheckpoint{}
+	}
+
+	if cp.UploadID == "" {
+		skipped, err := c.startResumableUpload(ctx, localPath, remotePath, st, opts, cp)
+		if err != nil || skipped != nil {
+			return skipped, err
+		}
+		if err := save(); err != nil {
+			return nil, err
+		}
+	} else {
+		c.logger.Info("续传上传", logKeyOp, "upload", logKeyPath, remotePath, logKeyLocalPath, localPath)
+	}
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	progress.start(PhaseUploading, remotePath, cp.Size)
+	for i := range cp.Done {
+		if cp.Done[i] {
+			progress.add(min(cp.ChunkSize, cp.Size-int64(i)*cp.ChunkSize))
+		}
+	}
+	for i, done := range cp.Done {
+		if done {
+			continue
+		}
+		offset := int64(i) * cp.ChunkSize
+		n := min(cp.ChunkSize, cp.Size-offset)
+		if err := c.uploadPart(ctx, remotePath, cp.UploadID, i, io.NewSectionReader(f, offset, n), n); err != nil {
+			return nil, err
+		}
+		cp.Done[i] = true
+		if err := save(); err != nil {
+			return nil, err
+		}
+	}
+
+	progress.start(PhaseCreating, remotePath, 0)
+	info, err := c.create(ctx, remotePath, cp.UploadID, cp.Size, cp.BlockList, opts.OnConflict)
+	if err != nil {
+		var errnoErr *ErrnoError
+		if errors.As(err, &errnoErr) {
+			// uploadid 已失效或分片不完整，下次从头上传
+			*cp = uploadCheckpoint{}
+		}
+		return nil, err
+	}
+	progress.done()
+	return &UploadResult{FileInfo: *info}, nil
+}
+
+// startResumableUpload 计算分片 MD5 并预上传，结果写入 cp；opts.SkipIfSame 满足时返回 skipped
+func (c *Client) startResumableUpload(ctx context.Context, localPath string, remotePath string, st os.FileInfo, opts UploadOptions, cp *uploadCheckpoint) (skipped *UploadResult, err error) {
+	size := st.Size()
+	limits := c.UploadLimits(ctx)
+	if err := checkFileSize(limits, remotePath, size); err != nil {
+		return nil, err
+	}
+
+	progress := progressFromContext(ctx)
+	if opts.SkipIfSame {
+		if skipped, err := c.skipIfSameFile(ctx, localPath, size, remotePath, progress); err != nil || skipped != nil {
+			return skipped, err
+		}
+	}
+	progress.start(PhaseHashing, remotePath, size)
+	var blockList []string
+	err = ProcessFileInShards(localPath, limits.ChunkSize, func(index int, data []byte, isLast bool) error {
+		sum := md5.Sum(data)
+		blockList = append(blockList, hex.EncodeToString(sum[:]))
+		progress.add(int64(len(data)))
+		return ctx.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	if size == 0 {
+		blockList = []string{emptyBlockMD5}
+	}
+	if err := c.checkQuota(ctx, remotePath, size); err != nil {
+		return nil, err
+	}
+
+	progress.start(PhasePrecreate, remotePath, 0)
+	uploadID, err := c.precreate(ctx, remotePath, size, blockList, opts.OnConflict)
+	if err != nil {
+		return nil, err
+	}
+	*cp = uploadCheckpoint{
+		UploadID:  uploadID,
+		BlockList: blockList,
+		ChunkSize: limits.ChunkSize,
+		Size:      size,
+		ModTime:   st.ModTime(),
+		Done:      make([]bool, len(blockList)),
+	}
+	return nil, nil
+}
+
+// downloadResumable 按 cp 续传远程文件，已下载的数据保存在 cp.TempPath 中，cp 为空时从头开始
+// 远程文件变化时丢弃已下载的数据；下载完整后按 DownloadFileWithOptions 的方式重命名为目标文件
+func (c *Client) downloadResumable(ctx context.Context, remotePath string, localPath string, opts DownloadOptions, cp *downloadCheckpoint, save func() error) (_ *DownloadResult, err error) {
+	ctx, span := c.startSpan(ctx, "DownloadFile", attrRemotePath.String(remotePath), attrLocalPath.String(localPath))
+	defer func() { endSpan(span, err) }()
+
+	var meta *FileMeta
+	if cp.TempPath != "" {
+		if meta, err = c.resolveFile(ctx, remotePath); err != nil {
+			return nil, err
+		}
+		if meta.FsId != cp.FsID || meta.Size != cp.Size || meta.ServerMtime != cp.ServerMtime {
+			c.logger.Info("远程文件已变化，重新下载", logKeyOp, "download", logKeyPath, remotePath)
+			_ = os.Remove(cp.TempPath)
+			*cp = downloadCheckpoint{}
+		}
+	}
+	if cp.TempPath == "" {
+		var target string
+		var skipped *DownloadResult
+		meta, target, skipped, err = c.prepareDownload(ctx, remotePath, localPath, opts)
+		if err != nil || skipped != nil {
+			return skipped, err
+		}
+		tmp, err := os.CreateTemp(filepath.Dir(target), fmt.Sprintf(tempFilePattern, filepath.Base(target)))
+		if err != nil {
+			return nil, err
+		}
+		_ = tmp.Close()
+		*cp = downloadCheckpoint{LocalPath: target, TempPath: tmp.Name(), FsID: meta.FsId, Size: meta.Size, ServerMtime: meta.ServerMtime}
+		if err := save(); err != nil {
+			return nil, err
+		}
+	}
+
+	written, err := c.appendDlink(ctx, remotePath, c.newDlinkSource(meta), cp, opts.Fsync)
+	if err != nil {
+		return nil, err
+	}
+	if err := commitTempFile(cp.TempPath, cp.LocalPath, serverMtime(meta)); err != nil {
+		return nil, err
+	}
+	c.logger.Info("文件下载成功", logKeyOp, "download", logKeyPath, remotePath, logKeyLocalPath, cp.LocalPath, logKeySize, written)
+	return &DownloadResult{LocalPath: cp.LocalPath, Size: written}, nil
+}
+
+// appendDlink 从 cp.TempPath 的末尾开始以 Range 请求下载剩余部分，返回文件的总大小
+func (c *Client) appendDlink(ctx context.Context, remotePath string, src *dlinkSource, cp *downloadCheckpoint, fsync bool) (int64, error) {
+	defer c.trackTransfer(DirectionDownload)()
+	out, err := os.OpenFile(cp.TempPath, os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return 0, err
+	}
+	defer out.Close()
+	offset, err := out.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	if offset > cp.Size {
+		if err := out.Truncate(0); err != nil {
+			return 0, err
+		}
+		if offset, err = out.Seek(0, io.SeekStart); err != nil {
+			return 0, err
+		}
+	}
+
+	progress := progressFromContext(ctx)
+	progress.start(PhaseDownloading, remotePath, cp.Size)
+	progress.add(offset)
+	if offset < cp.Size {
+		c.logger.Debug("续传下载", logKeyOp, "download", logKeyPath, remotePath, "offset", offset)
+		stream := newDlinkStream(ctx, src, offset, cp.Size)
+		n, err := io.Copy(out, progress.reader(stream))
+		_ = stream.Close()
+		offset += n
+		if err != nil {
+			return offset, err
+		}
+	}
+
+	progress.start(PhaseVerifying, remotePath, cp.Size)
+	if offset != cp.Size {
+		return offset, fmt.Errorf("incomplete download: expected %d bytes, got %d", cp.Size, offset)
+	}
+	if fsync {
+		if err := out.Sync(); err != nil {
+			return offset, err
+		}
+	}
+	if err := out.Close(); err != nil {
+		return offset, err
+	}
+	progress.done()
+	return offset, nil
+}
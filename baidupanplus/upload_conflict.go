@@ -0,0 +1,115 @@
+package baidupanplus
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+)
+
+// conflictServerDefault 不提交 rtype，远程路径已存在时按服务端的默认方式处理
+// UploadFile 等原有接口沿用引入冲突策略之前不带 rtype 的请求
+const conflictServerDefault ConflictPolicy = -1
+
+// compareBufferSize SkipIfSame 逐块比较本地与远程内容时每次读取的字节数
+const compareBufferSize = 1 << 20
+
+// UploadOptions UploadFileWithOptions / UploadReaderWithOptions 的选项
+type UploadOptions struct {
+	// OnConflict 远程路径已存在时的处理策略，默认 ConflictFail
+	OnConflict ConflictPolicy
+
+	// SkipIfSame 远程路径已存在内容相同的文件时跳过上传，直接返回该文件，不满足时按 OnConflict 处理
+	// 列表返回的 md5 不是文件内容的真实 MD5，因此大小相同时会下载远程文件与本地数据逐块比较，
+	// 遇到第一处不同即停止；比较期间进度处于 PhaseVerifying。UploadReaderWithOptions 要求 r 实现 io.Seeker
+	SkipIfSame bool
+}
+
+// UploadResult 上传结果
+type UploadResult struct {
+	// FileInfo 实际写入的文件，OnConflict 为 ConflictRename 等策略时 Path 可能与请求的路径不同
+	FileInfo
+
+	// Skipped 因 SkipIfSame 跳过了上传，FileInfo 为远程已存在的文件
+	Skipped bool
+}
+
+// skipIfSame 远程路径已存在与 r 前 size 字节相同的文件时返回跳过上传的结果，否则返回 nil
+// 返回 nil 时 r 的读取位置未定义，调用方需要自行恢复
+func (c *Client) skipIfSame(ctx context.Context, r io.Reader, size int64, remotePath string, progress *progressTracker) (*UploadResult, error) {
+	existing, err := c.Stat(ctx, remotePath)
+	if err != nil {
+		if CategoryOf(err) == CategoryNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if existing.IsDir() || existing.Size != size {
+		return nil, nil
+	}
+	same, err := c.sameRemoteContent(ctx, existing, r, progress)
+	if err != nil || !same {
+		return nil, err
+	}
+	c.logger.Info("skipped upload, remote file is identical", logKeyOp, "upload", logKeyPath, existing.Path, logKeySize, existing.Size)
+	progress.done()
+	return &UploadResult{FileInfo: *existing, Skipped: true}, nil
+}
+
+// skipIfSameFile 以本地文件的内容调用 skipIfSame
+func (c *Client) skipIfSameFile(ctx context.Context, localPath string, size int64, remotePath string, progress *progressTracker) (*UploadResult, error) {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return c.skipIfSame(ctx, f, size, remotePath, progress)
+}
+
+// skipIfSameReader 以 r 当前位置起的数据调用 skipIfSame，未跳过时将 r 恢复到原来的位置
+func (c *Client) skipIfSameReader(ctx context.Context, r io.Reader, size int64, remotePath string, progress *progressTracker) (*UploadResult, error) {
+	seeker, ok := r.(io.Seeker)
+	if !ok {
+		return nil, fmt.Errorf("SkipIfSame requires an io.Seeker reader")
+	}
+	start, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	skipped, err := c.skipIfSame(ctx, r, size, remotePath, progress)
+	if err != nil || skipped != nil {
+		return skipped, err
+	}
+	if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// sameRemoteContent 逐块比较远程文件与 r 的前 info.Size 字节，遇到第一处不同即停止读取
+func (c *Client) sameRemoteContent(ctx context.Context, info *FileInfo, r io.Reader, progress *progressTracker) (bool, error) {
+	remote, err := c.OpenFileInfo(ctx, info)
+	if err != nil {
+		return false, err
+	}
+	defer remote.Close()
+	progress.start(PhaseVerifying, info.Path, info.Size)
+	local := make([]byte, min(compareBufferSize, info.Size))
+	remoteBuf := make([]byte, len(local))
+	for left := info.Size; left > 0; {
+		n := min(int64(len(local)), left)
+		if _, err := io.ReadFull(r, local[:n]); err != nil {
+			return false, err
+		}
+		if _, err := io.ReadFull(remote, remoteBuf[:n]); err != nil {
+			return false, err
+		}
+		if !bytes.Equal(local[:n], remoteBuf[:n]) {
+			return false, nil
+		}
+		left -= n
+		progress.add(n)
+	}
+	return true, nil
+}
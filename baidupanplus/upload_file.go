@@ -1,25 +1,26 @@
 package baidupanplus
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"crypto/md5"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"os"
+	"path"
 
 	"github.com/S-zhi/baidupansdk/baidupanplus/tool"
-	openapi "github.com/S-zhi/baidupansdk/openxpanapi"
 )
 
-var client = *(openapi.NewAPIClient(openapi.NewConfiguration()))
 var ctx = context.Background()
 
 const (
-	isdir    = 0
-	autoinit = 1
+	isdirFile = 0 // create / precreate 的 isdir 参数：文件
+	isdirDir  = 1 // create 的 isdir 参数：目录
+	autoinit  = 1
 )
 
 // 预上传文档 : https://pan.baidu.com/union/doc/3ksg0s9r7?from=open-sdk-go
@@ -31,12 +32,13 @@ const (
 //	md5List: 分片MD5列表
 //	error: 错误信息
 func PrecreateFile(accessToken string, remotePath string, localPath string, shardSize int64) (string, []string, error) {
+	logger := packageLogger().With(logKeyOp, "precreate", logKeyPath, remotePath, logKeyLocalPath, localPath)
 	fileSize, err := tools.GetFileSizeByPath(localPath)
-	Info(fmt.Sprintf("File size: %d", fileSize))
 	if err != nil {
-		Error(fmt.Sprintf("Failed to get file size: %v", err))
+		logger.Error("failed to get file size", logKeyError, err)
 		return "", nil, err
 	}
+	logger.Debug("file size", logKeySize, fileSize)
 
 	// 计算所有分片的MD5
 	var md5List []string
@@ -48,144 +50,213 @@ func PrecreateFile(accessToken string, remotePath string, localPath string, shar
 		return nil
 	})
 	if err != nil {
-		Error(fmt.Sprintf("Failed to calculate shard MD5s: %v", err))
+		logger.Error("failed to calculate shard md5s", logKeyError, err)
 		return "", nil, err
 	}
 
+	uploadID, err := packageClient(Config{AccessToken: accessToken}).precreate(ctx, remotePath, fileSize, md5List, conflictServerDefault)
+	if err != nil {
+		return "", nil, err
+	}
+	return uploadID, md5List, nil
+}
+
+// precreate 调用预上传接口，返回 uploadid
+func (c *Client) precreate(ctx context.Context, remotePath string, fileSize int64, md5List []string, policy ConflictPolicy) (string, error) {
 	md5ListByte, _ := json.Marshal(md5List)
 	md5ListStr := string(md5ListByte)
-	apiXpanfileprecreateRequest := client.FileuploadApi.Xpanfileprecreate(ctx).
-		AccessToken(accessToken).
+	apiXpanfileprecreateRequest := c.api.FileuploadApi.Xpanfileprecreate(ctx).
+		AccessToken(c.cfg.AccessToken).
 		Path(remotePath).
 		Autoinit(autoinit).
-		Size(int32(fileSize)).
-		Isdir(isdir).
+		Size(fileSize).
+		Isdir(isdirFile).
 		BlockList(md5ListStr)
+	if policy != conflictServerDefault {
+		apiXpanfileprecreateRequest = apiXpanfileprecreateRequest.Rtype(int32(policy))
+	}
 
-	fileprecreateresponse, _, err := client.FileuploadApi.XpanfileprecreateExecute(apiXpanfileprecreateRequest)
+	fileprecreateresponse, _, err := c.api.FileuploadApi.XpanfileprecreateExecute(apiXpanfileprecreateRequest)
 	if err != nil {
-		Error(fmt.Sprintf("Failed to execute Xpanfileprecreate: %v", err))
-		return "", nil, err
+		c.logger.Error("failed to execute precreate", logKeyOp, "precreate", logKeyPath, remotePath, logKeyError, err)
+		return "", err
 	}
 
 	if fileprecreateresponse.GetErrno() != 0 {
-		return "", nil, fmt.Errorf("precreate failed with errno: %d", fileprecreateresponse.GetErrno())
+		c.logger.Error("precreate failed", logKeyOp, "precreate", logKeyPath, remotePath,
+			logKeyErrno, fileprecreateresponse.GetErrno(), logKeyRequestID, fileprecreateresponse.GetRequestId())
+		return "", newErrnoError("precreate", fileprecreateresponse.GetErrno())
 	}
 
-	return fileprecreateresponse.GetUploadid(), md5List, nil
+	return fileprecreateresponse.GetUploadid(), nil
 }
 
 // UploadPart 分片上传
 func UploadPart(accessToken string, remotePath string, uploadID string, partSeq int, partData []byte) error {
+	return packageClient(Config{AccessToken: accessToken}).uploadPart(ctx, remotePath, uploadID, partSeq, bytes.NewReader(partData), int64(len(partData)))
+}
 
-	tmpFile, err := os.CreateTemp("", "part-*")
-	if err != nil {
-		return err
-	}
-	defer func(name string) {
-		err := os.Remove(name)
-		if err != nil {
-			Error(fmt.Sprintf("Failed to remove temporary file: %v", err))
-		}
-	}(tmpFile.Name())
-	defer func(tmpFile *os.File) {
-		err := tmpFile.Close()
-		if err != nil {
-			Error(fmt.Sprintf("Failed to close temporary file: %v", err))
-		}
-	}(tmpFile)
+// limitUpload 对上传数据施加客户端级别与单次传输级别的限速，并统计上传字节数
+func (c *Client) limitUpload(ctx context.Context, r io.Reader) io.Reader {
+	limited := limitReader(ctx, r, c.rateLimitWaitObserver(DirectionUpload), c.uploadLimiter, rateLimiterFromContext(ctx))
+	return c.meterReader(DirectionUpload, limited)
+}
 
-	if _, err := tmpFile.Write(partData); err != nil {
-		return err
-	}
-	if _, err := tmpFile.Seek(0, 0); err != nil {
-		return err
-	}
+// uploadPart 上传单个分片，分片内容直接从 r 流式写入请求体
+func (c *Client) uploadPart(ctx context.Context, remotePath string, uploadID string, partSeq int, r io.Reader, size int64) (err error) {
+	ctx, span := c.startSpan(ctx, "UploadPart", attrRemotePath.String(remotePath), attrPartSeq.Int(partSeq), attrSize.Int64(size))
+	defer func() { endSpan(span, err) }()
 
-	apiXpanfileuploadRequest := client.FileuploadApi.Pcssuperfile2(ctx).
-		AccessToken(accessToken).
+	apiXpanfileuploadRequest := c.api.FileuploadApi.Pcssuperfile2(ctx).
+		AccessToken(c.cfg.AccessToken).
 		Path(remotePath).
 		Uploadid(uploadID).
 		Type_("tmpfile").
 		Partseq(fmt.Sprintf("%d", partSeq)).
-		File(tmpFile)
+		FileReader(fmt.Sprintf("part-%d", partSeq), progressFromContext(ctx).reader(c.limitUpload(ctx, r)), size)
 
-	_, response, err := client.FileuploadApi.Pcssuperfile2Execute(apiXpanfileuploadRequest)
+	_, response, err := c.api.FileuploadApi.Pcssuperfile2Execute(apiXpanfileuploadRequest)
 	if err != nil {
-		Error(fmt.Sprintf("Failed to upload part %d: %v, status: %d", partSeq, err, response.StatusCode))
+		status := 0
+		if response != nil {
+			status = response.StatusCode
+		}
+		c.logger.Error("failed to upload part", logKeyOp, "upload", logKeyPath, remotePath,
+			logKeyPartSeq, partSeq, logKeyStatus, status, logKeyError, err)
 		return err
 	}
 
-	Info(fmt.Sprintf("Successfully uploaded part %d", partSeq))
+	c.logger.Debug("uploaded part", logKeyOp, "upload", logKeyPath, remotePath, logKeyPartSeq, partSeq, logKeySize, size)
 	return nil
 }
 
 // CreateFile 合并分片创建文件
 func CreateFile(accessToken string, remotePath string, uploadID string, fileSize int64, md5List []string) error {
+	_, err := packageClient(Config{AccessToken: accessToken}).create(ctx, remotePath, uploadID, fileSize, md5List, conflictServerDefault)
+	return err
+}
+
+// create 调用创建文件接口合并分片，返回实际创建的文件
+func (c *Client) create(ctx context.Context, remotePath string, uploadID string, fileSize int64, md5List []string, policy ConflictPolicy) (*FileInfo, error) {
 	md5ListByte, _ := json.Marshal(md5List)
 	md5ListStr := string(md5ListByte)
 
-	apiXpanfilecreateRequest := client.FileuploadApi.Xpanfilecreate(ctx).
-		AccessToken(accessToken).
+	apiXpanfilecreateRequest := c.api.FileuploadApi.Xpanfilecreate(ctx).
+		AccessToken(c.cfg.AccessToken).
 		Path(remotePath).
-		Isdir(isdir).
-		Size(int32(fileSize)).
+		Isdir(isdirFile).
+		Size(fileSize).
 		Uploadid(uploadID).
 		BlockList(md5ListStr)
+	if policy != conflictServerDefault {
+		apiXpanfilecreateRequest = apiXpanfilecreateRequest.Rtype(int32(policy))
+	}
 
-	filecreateresponse, _, err := client.FileuploadApi.XpanfilecreateExecute(apiXpanfilecreateRequest)
+	filecreateresponse, _, err := c.api.FileuploadApi.XpanfilecreateExecute(apiXpanfilecreateRequest)
 	if err != nil {
-		Error(fmt.Sprintf("Failed to execute Xpanfilecreate: %v", err))
-		return err
+		c.logger.Error("failed to execute create", logKeyOp, "create", logKeyPath, remotePath, logKeyError, err)
+		return nil, err
 	}
 
 	if filecreateresponse.GetErrno() != 0 {
-		return fmt.Errorf("create file failed with errno: %d", filecreateresponse.GetErrno())
+		c.logger.Error("create file failed", logKeyOp, "create", logKeyPath, remotePath, logKeyErrno, filecreateresponse.GetErrno())
+		return nil, newErrnoError("create file", filecreateresponse.GetErrno())
 	}
 
-	Info(fmt.Sprintf("Successfully created file: %s", remotePath))
-	return nil
+	info := fileInfoFromCreate(filecreateresponse)
+	if info.Path == "" {
+		info.Path = remotePath
+		info.ServerFilename = path.Base(remotePath)
+	}
+	c.logger.Info("created file", logKeyOp, "create", logKeyPath, info.Path, logKeySize, fileSize)
+	return info, nil
 }
 
 // UploadFileWithConfig 完整上传流程封装
 func UploadFileWithConfig(uploadFileConfig UploadFileConfig) error {
-	if uploadFileConfig == (UploadFileConfig{}) {
-		Warn("UploadFileConfig is empty, Use defaultUploadFileConfig")
+	if uploadFileConfig.LocalPath == "" && uploadFileConfig.RemotePath == "" {
+		packageLogger().Warn("UploadFileConfig is empty, use defaultUploadFileConfig")
 		uploadFileConfig = defaultUploadFileConfig
 	}
-	accessToken := uploadFileConfig.AccessToken
-	remotePath := uploadFileConfig.RemotePath
-	localPath := uploadFileConfig.LocalPath
-	Info("未实现普通会员的情况")
-	var shardSize int64
-	if uploadFileConfig.IsSVIP {
-		shardSize = int64(4 * 1024 * 1024)
-		Info("授权用户为普通用户时，单个分片大小固定为4MB，单文件总大小上限为4GB")
-	} else {
-		shardSize = int64(32 * 1024 * 1024)
-		Info("授权用户为超级会员时，用户单个分片大小上限为32MB，单文件总大小上限为20GB")
-	}
-	// 1. 预上传
-	uploadID, md5List, err := PrecreateFile(accessToken, remotePath, localPath, shardSize)
+	uploadCtx := WithProgress(ctx, uploadFileConfig.Progress, uploadFileConfig.ProgressInterval)
+	return packageClient(uploadFileConfig.Config).UploadFile(uploadCtx, uploadFileConfig.LocalPath, uploadFileConfig.RemotePath)
+}
+
+// UploadFile 上传本地文件到 remotePath，依次执行 计算分片MD5 -> 预上传 -> 分片上传 -> 创建文件
+// 通过 WithProgress 设置的进度回调会收到各阶段的进度；请求不带 rtype，remotePath 已存在时按服务端的默认策略处理，
+// 需要指定策略时使用 UploadFileWithOptions
+func (c *Client) UploadFile(ctx context.Context, localPath string, remotePath string) error {
+	_, err := c.UploadFileWithOptions(ctx, localPath, remotePath, UploadOptions{OnConflict: conflictServerDefault})
+	return err
+}
+
+// UploadFileWithOptions 按 opts 上传本地文件到 remotePath，返回实际写入的文件
+func (c *Client) UploadFileWithOptions(ctx context.Context, localPath string, remotePath string, opts UploadOptions) (_ *UploadResult, err error) {
+	ctx, span := c.startSpan(ctx, "UploadFile", attrRemotePath.String(remotePath), attrLocalPath.String(localPath))
+	defer func() { endSpan(span, err) }()
+
+	defer c.trackTransfer(DirectionUpload)()
+	progress := progressFromContext(ctx)
+	fileSize, err := tools.GetFileSizeByPath(localPath)
+	if err != nil {
+		c.logger.Error("failed to get file size", logKeyOp, "upload", logKeyLocalPath, localPath, logKeyError, err)
+		return nil, err
+	}
+	span.SetAttributes(attrSize.Int64(fileSize))
+	limits := c.UploadLimits(ctx)
+	shardSize := limits.ChunkSize
+	if err := checkFileSize(limits, remotePath, fileSize); err != nil {
+		return nil, err
+	}
+	if opts.SkipIfSame {
+		if skipped, err := c.skipIfSameFile(ctx, localPath, fileSize, remotePath, progress); err != nil || skipped != nil {
+			return skipped, err
+		}
+	}
+	if err := c.checkQuota(ctx, remotePath, fileSize); err != nil {
+		return nil, err
+	}
+	c.logger.Info("start upload", logKeyOp, "upload", logKeyPath, remotePath, logKeyLocalPath, localPath, logKeySize, fileSize)
+
+	// 1. 计算分片MD5
+	progress.start(PhaseHashing, remotePath, fileSize)
+	var md5List []string
+	err = ProcessFileInShards(localPath, shardSize, func(index int, data []byte, isLast bool) error {
+		sum := md5.Sum(data)
+		md5List = append(md5List, hex.EncodeToString(sum[:]))
+		progress.add(int64(len(data)))
+		return ctx.Err()
+	})
+	if err != nil {
+		c.logger.Error("failed to calculate shard md5s", logKeyOp, "upload", logKeyLocalPath, localPath, logKeyError, err)
+		return nil, err
+	}
+	// 2. 预上传
+	progress.start(PhasePrecreate, remotePath, 0)
+	uploadID, err := c.precreate(ctx, remotePath, fileSize, md5List, opts.OnConflict)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// 2. 分片上传
+	// 3. 分片上传
+	progress.start(PhaseUploading, remotePath, fileSize)
 	err = ProcessFileInShards(localPath, shardSize, func(index int, data []byte, isLast bool) error {
-		return UploadPart(accessToken, remotePath, uploadID, index, data)
+		return c.uploadPart(ctx, remotePath, uploadID, index, bytes.NewReader(data), int64(len(data)))
 	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// 3. 创建文件
-	fileSize, _ := tools.GetFileSizeByPath(localPath)
-	return CreateFile(accessToken, remotePath, uploadID, fileSize, md5List)
+	// 4. 创建文件
+	progress.start(PhaseCreating, remotePath, 0)
+	info, err := c.create(ctx, remotePath, uploadID, fileSize, md5List, opts.OnConflict)
+	if err != nil {
+		return nil, err
+	}
+	progress.done()
+	return &UploadResult{FileInfo: *info}, nil
 }
 
-// =================================== 分片处理器 ===================================
-
 // ShardProcessor 分片处理器接口
 type ShardProcessor func(index int, data []byte, isLast bool) error
 
@@ -198,37 +269,48 @@ func ProcessFileInShards(filePath string, shardSize int64, processor ShardProces
 	defer func(file *os.File) {
 		err := file.Close()
 		if err != nil {
-			log.Printf("Failed to close file: %v", err)
+			packageLogger().Warn("failed to close file", logKeyLocalPath, filePath, logKeyError, err)
 		}
 	}(file)
 
-	fileInfo, err := file.Stat()
-	if err != nil {
-		return err
-	}
-	totalSize := fileInfo.Size()
+	return ProcessReaderInShards(file, shardSize, processor)
+}
 
-	shardCount := (totalSize + shardSize - 1) / shardSize
+// ProcessReaderInShards 从 io.Reader 中按 shardSize 逐片读取并交给 processor 处理
+// 同一时刻只在内存中缓冲一个分片；除最后一片外，每片长度都恰好为 shardSize
+// 由于无法预知流的总长度，isLast 在读到流末尾时才能确定，因此会额外预读一个字节
+func ProcessReaderInShards(r io.Reader, shardSize int64, processor ShardProcessor) error {
+	if shardSize <= 0 {
+		return fmt.Errorf("invalid shard size: %d", shardSize)
+	}
+	br := bufio.NewReader(r)
 	buffer := make([]byte, shardSize)
 	currentIndex := 0
 
 	for {
-		n, err := file.Read(buffer)
-		if n > 0 {
-			isLast := currentIndex == int(shardCount-1)
-			err := processor(currentIndex, buffer[:n], isLast)
-			if err != nil {
-				return err
+		n, err := io.ReadFull(br, buffer)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return err
+		}
+
+		isLast := err == io.ErrUnexpectedEOF
+		if !isLast {
+			if _, peekErr := br.Peek(1); peekErr == io.EOF {
+				isLast = true
+			} else if peekErr != nil {
+				return peekErr
 			}
-			currentIndex++
 		}
 
-		if err == io.EOF {
-			break
-		} else if err != nil {
+		if err := processor(currentIndex, buffer[:n], isLast); err != nil {
 			return err
 		}
+		if isLast {
+			return nil
+		}
+		currentIndex++
 	}
-
-	return nil
 }
@@ -0,0 +1,249 @@
+package baidupanplus
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/S-zhi/baidupansdk/baidupantest"
+)
+
+// writeTestFile 在临时目录中写入 data，返回文件路径
+func writeTestFile(t *testing.T, data []byte) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "local.bin")
+	if err := os.WriteFile(p, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestUploadFile(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+	}{
+		{"empty", 0},
+		{"single shard", 1000},
+		{"multiple shards", 9<<20 + 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, srv := newTestClient(t)
+			data := testData(tt.size)
+			if err := c.UploadFile(context.Background(), writeTestFile(t, data), "/apps/test/a.bin"); err != nil {
+				t.Fatalf("upload: %v", err)
+			}
+			got, ok := srv.ReadFile("/apps/test/a.bin")
+			if !ok || !bytes.Equal(got, data) {
+				t.Fatalf("remote content differs (exists=%v, %d bytes, want %d)", ok, len(got), len(data))
+			}
+			if n, want := srv.Requests(baidupantest.OpUpload), (tt.size+4<<20-1)/(4<<20); n != want {
+				t.Errorf("upload requests = %d, want %d", n, want)
+			}
+		})
+	}
+}
+
+func TestUploadFileFaults(t *testing.T) {
+	data := testData(5 << 20)
+	tests := []struct {
+		name  string
+		op    baidupantest.Op
+		fault baidupantest.Fault
+		errno int
+	}{
+		{"precreate errno", baidupantest.OpPrecreate, baidupantest.Fault{Errno: 2, Times: 1}, 2},
+		{"dropped part", baidupantest.OpUpload, baidupantest.Fault{DropConnection: true, Times: 1}, 0},
+		{"create block miss", baidupantest.OpCreate, baidupantest.Fault{Errno: 31363, Times: 1}, 31363},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, srv := newTestClient(t)
+			local := writeTestFile(t, data)
+			srv.InjectFault(tt.op, tt.fault)
+
+			err := c.UploadFile(context.Background(), local, "/apps/test/a.bin")
+			if err == nil {
+				t.Fatal("upload succeeded despite injected fault")
+			}
+			var errnoErr *ErrnoError
+			if tt.errno != 0 && (!errors.As(err, &errnoErr) || errnoErr.Errno != tt.errno) {
+				t.Errorf("error = %v, want errno %d", err, tt.errno)
+			}
+			if srv.Exists("/apps/test/a.bin") {
+				t.Fatal("failed upload created a remote file")
+			}
+
+			// 故障只生效一次，重新上传应当成功
+			if err := c.UploadFile(context.Background(), local, "/apps/test/a.bin"); err != nil {
+				t.Fatalf("upload after fault: %v", err)
+			}
+			if got, _ := srv.ReadFile("/apps/test/a.bin"); !bytes.Equal(got, data) {
+				t.Fatal("remote content differs after retry")
+			}
+		})
+	}
+}
+
+func TestUploadFileLatencyHonoursContext(t *testing.T) {
+	c, srv := newTestClient(t)
+	srv.InjectFault(baidupantest.OpPrecreate, baidupantest.Fault{Latency: 5 * time.Second})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := c.UploadFile(ctx, writeTestFile(t, testData(1000)), "/apps/test/a.bin")
+	if CategoryOf(err) != CategoryCanceled {
+		t.Fatalf("error = %v, want category %q", err, CategoryCanceled)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("upload returned after %v, want it to stop at the deadline", elapsed)
+	}
+}
+
+// rtypeRecorder 记录 precreate 与 create 请求提交的 rtype，未提交时记为 "-"
+type rtypeRecorder struct {
+	base http.RoundTripper
+
+	mu     sync.Mutex
+	rtypes []string
+}
+
+func (r *rtypeRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	if method := req.URL.Query().Get("method"); (method == "precreate" || method == "create") && req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		form, _ := url.ParseQuery(string(body))
+		rtype := "-"
+		if form.Has("rtype") {
+			rtype = form.Get("rtype")
+		}
+		r.mu.Lock()
+		r.rtypes = append(r.rtypes, method+"="+rtype)
+		r.mu.Unlock()
+	}
+	return r.base.RoundTrip(req)
+}
+
+func TestUploadConflictRtype(t *testing.T) {
+	tests := []struct {
+		name   string
+		upload func(c *Client, local string) error
+		want   string
+	}{
+		// 原有接口不提交 rtype，沿用服务端的默认处理
+		{"UploadFile", func(c *Client, local string) error {
+			return c.UploadFile(context.Background(), local, "/apps/test/a.bin")
+		}, "[precreate=- create=-]"},
+		{"UploadReader", func(c *Client, local string) error {
+			return c.UploadReader(context.Background(), bytes.NewReader(testData(10)), 10, "/apps/test/a.bin")
+		}, "[precreate=- create=-]"},
+		{"fail", func(c *Client, local string) error {
+			_, err := c.UploadFileWithOptions(context.Background(), local, "/apps/test/a.bin", UploadOptions{})
+			return err
+		}, "[precreate=0 create=0]"},
+		{"overwrite", func(c *Client, local string) error {
+			_, err := c.UploadFileWithOptions(context.Background(), local, "/apps/test/a.bin", UploadOptions{OnConflict: ConflictOverwrite})
+			return err
+		}, "[precreate=3 create=3]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, srv := newTestClient(t)
+			recorder := &rtypeRecorder{base: srv.Client().Transport}
+			cfg := newTestConfig(srv)
+			cfg.HTTPClient = &http.Client{Transport: recorder}
+			if err := tt.upload(NewClient(cfg), writeTestFile(t, testData(10))); err != nil {
+				t.Fatalf("upload: %v", err)
+			}
+			recorder.mu.Lock()
+			defer recorder.mu.Unlock()
+			if got := fmt.Sprint(recorder.rtypes); got != tt.want {
+				t.Errorf("rtype = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUploadSkipIfSame(t *testing.T) {
+	data := testData(3<<20 + 7)
+	changed := bytes.Clone(data)
+	changed[len(changed)-1] ^= 1
+	tests := []struct {
+		name        string
+		remote      []byte
+		wantSkipped bool
+		wantReads   bool // 是否需要读取远程文件比较
+	}{
+		{"identical", data, true, true},
+		{"same size, different content", changed, false, true},
+		{"different size", data[:100], false, false},
+		{"missing", nil, false, false},
+	}
+	uploads := map[string]func(c *Client) (*UploadResult, error){
+		"file": func(c *Client) (*UploadResult, error) {
+			return c.UploadFileWithOptions(context.Background(), writeTestFile(t, data), "/apps/test/a.bin", UploadOptions{OnConflict: ConflictOverwrite, SkipIfSame: true})
+		},
+		"reader": func(c *Client) (*UploadResult, error) {
+			return c.UploadReaderWithOptions(context.Background(), bytes.NewReader(data), int64(len(data)), "/apps/test/a.bin", UploadOptions{OnConflict: ConflictOverwrite, SkipIfSame: true})
+		},
+		"resumable": func(c *Client) (*UploadResult, error) {
+			var cp uploadCheckpoint
+			return c.uploadResumable(context.Background(), writeTestFile(t, data), "/apps/test/a.bin", UploadOptions{OnConflict: ConflictOverwrite, SkipIfSame: true}, &cp, func() error { return nil })
+		},
+	}
+	for kind, upload := range uploads {
+		for _, tt := range tests {
+			t.Run(kind+"/"+tt.name, func(t *testing.T) {
+				c, srv := newTestClient(t)
+				var fsID int64
+				if tt.remote != nil {
+					fsID = srv.AddFile("/apps/test/a.bin", tt.remote)
+				}
+				res, err := upload(c)
+				if err != nil {
+					t.Fatalf("upload: %v", err)
+				}
+				if res.Skipped != tt.wantSkipped {
+					t.Fatalf("Skipped = %v, want %v", res.Skipped, tt.wantSkipped)
+				}
+				if got, _ := srv.ReadFile("/apps/test/a.bin"); !bytes.Equal(got, data) {
+					t.Errorf("remote content differs: got %d bytes", len(got))
+				}
+				if n := srv.Requests(baidupantest.OpDownload); (n > 0) != tt.wantReads {
+					t.Errorf("download requests = %d, want reads = %v", n, tt.wantReads)
+				}
+				if tt.wantSkipped {
+					if n := srv.Requests(baidupantest.OpPrecreate); n != 0 {
+						t.Errorf("precreate requests = %d, want 0", n)
+					}
+					if res.FsId != fsID || res.Path != "/apps/test/a.bin" {
+						t.Errorf("result = %+v, want the existing file", res.FileInfo)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestUploadSkipIfSameRequiresSeeker(t *testing.T) {
+	c, srv := newTestClient(t)
+	srv.AddFile("/apps/test/a.bin", []byte("data"))
+	_, err := c.UploadReaderWithOptions(context.Background(), io.MultiReader(bytes.NewReader([]byte("data"))), 4, "/apps/test/a.bin", UploadOptions{SkipIfSame: true})
+	if err == nil {
+		t.Fatal("SkipIfSame with a non-seekable reader succeeded")
+	}
+}
@@ -0,0 +1,74 @@
+package baidupanplus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrFileTooLarge 文件超过当前会员类型允许的单文件大小上限
+var ErrFileTooLarge = errors.New("file too large")
+
+// UploadLimits 上传分片大小与单文件大小上限，单位为字节
+type UploadLimits struct {
+	ChunkSize   int64 `json:"chunk_size"`
+	MaxFileSize int64 `json:"max_file_size"`
+}
+
+const (
+	mb = int64(1024 * 1024)
+	gb = 1024 * mb
+)
+
+// uploadLimitsByVip 开放平台文档中各会员类型的上传限制
+var uploadLimitsByVip = map[VipType]UploadLimits{
+	VipTypeNormal: {ChunkSize: 4 * mb, MaxFileSize: 4 * gb},
+	VipTypeVIP:    {ChunkSize: 16 * mb, MaxFileSize: 10 * gb},
+	VipTypeSVIP:   {ChunkSize: 32 * mb, MaxFileSize: 20 * gb},
+}
+
+// UploadLimitsFor 返回会员类型对应的上传限制，未知类型按普通用户处理
+func UploadLimitsFor(vipType VipType) UploadLimits {
+	if limits, ok := uploadLimitsByVip[vipType]; ok {
+		return limits
+	}
+	return uploadLimitsByVip[VipTypeNormal]
+}
+
+// UploadLimits 返回当前用户的上传限制，会员类型的确定方式见 Config.VipType
+func (c *Client) UploadLimits(ctx context.Context) UploadLimits {
+	return UploadLimitsFor(c.vipType(ctx))
+}
+
+// vipType 返回当前用户的会员类型：优先使用 Config.VipType，否则查询用户信息并缓存在客户端上
+// 查询失败时按 Config.IsSVIP 估计，且不缓存，下次上传时重新查询
+func (c *Client) vipType(ctx context.Context) VipType {
+	if c.cfg.VipType != nil {
+		return *c.cfg.VipType
+	}
+	c.vipMu.Lock()
+	defer c.vipMu.Unlock()
+	if c.detectedVipType != nil {
+		return *c.detectedVipType
+	}
+	info, err := c.UserInfo(ctx)
+	if err != nil {
+		fallback := VipTypeNormal
+		if c.cfg.IsSVIP {
+			fallback = VipTypeSVIP
+		}
+		c.logger.Warn("failed to detect vip type, using fallback", logKeyOp, "upload", "vip_type", fallback, logKeyError, err)
+		return fallback
+	}
+	c.detectedVipType = &info.VipType
+	c.logger.Debug("detected vip type", logKeyOp, "upload", "vip_type", info.VipType)
+	return info.VipType
+}
+
+// checkFileSize 确认 size 不超过单文件大小上限
+func checkFileSize(limits UploadLimits, remotePath string, size int64) error {
+	if size > limits.MaxFileSize {
+		return fmt.Errorf("%w: %s is %d bytes, limit is %d bytes", ErrFileTooLarge, remotePath, size, limits.MaxFileSize)
+	}
+	return nil
+}
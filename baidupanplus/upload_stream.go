@@ -0,0 +1,164 @@
+package baidupanplus
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+// placeholderBlockMD5 无法回读的流在预上传阶段使用的占位分片 MD5，见 readerBlockList
+const placeholderBlockMD5 = "5910a591dd8fc18c32a8f3df4fdc1761"
+
+// emptyBlockMD5 空内容的 MD5，用于上传 0 字节文件
+const emptyBlockMD5 = "d41d8cd98f00b204e9800998ecf8427e"
+
+// UploadReader 从 io.Reader 流式上传数据到 remotePath
+// size 为数据总字节数（必须准确，用于预上传与分片划分）；
+// 数据按分片逐块读入内存后直接上传，不写本地临时文件，适用于管道、HTTP Body、压缩流等场景。
+// r 实现 io.Seeker 时会先读取一遍计算分片 MD5，预上传提交真实的 block_list
+// 请求不带 rtype，remotePath 已存在时按服务端的默认策略处理，需要指定策略时使用 UploadReaderWithOptions
+func (c *Client) UploadReader(ctx context.Context, r io.Reader, size int64, remotePath string) error {
+	_, err := c.UploadReaderWithOptions(ctx, r, size, remotePath, UploadOptions{OnConflict: conflictServerDefault})
+	return err
+}
+
+// UploadReaderWithOptions 按 opts 从 io.Reader 流式上传数据到 remotePath，返回实际写入的文件
+// opts.SkipIfSame 需要先读取数据与远程文件比较，因此要求 r 实现 io.Seeker
+func (c *Client) UploadReaderWithOptions(ctx context.Context, r io.Reader, size int64, remotePath string, opts UploadOptions) (_ *UploadResult, err error) {
+	ctx, span := c.startSpan(ctx, "UploadReader", attrRemotePath.String(remotePath), attrSize.Int64(size))
+	defer func() { endSpan(span, err) }()
+
+	if c.cfg.AccessToken == "" {
+		return nil, fmt.Errorf("access token is required")
+	}
+	if remotePath == "" {
+		return nil, fmt.Errorf("remote path is required")
+	}
+	if size < 0 {
+		return nil, fmt.Errorf("invalid size: %d", size)
+	}
+	limits := c.UploadLimits(ctx)
+	if err := checkFileSize(limits, remotePath, size); err != nil {
+		return nil, err
+	}
+	progress := progressFromContext(ctx)
+	if opts.SkipIfSame {
+		if skipped, err := c.skipIfSameReader(ctx, r, size, remotePath, progress); err != nil || skipped != nil {
+			return skipped, err
+		}
+	}
+	if err := c.checkQuota(ctx, remotePath, size); err != nil {
+		return nil, err
+	}
+
+	defer c.trackTransfer(DirectionUpload)()
+	shardSize := limits.ChunkSize
+	shardCount := int((size + shardSize - 1) / shardSize)
+	if shardCount == 0 {
+		shardCount = 1
+	}
+	c.logger.Info("开始流式上传", logKeyOp, "upload", logKeyPath, remotePath, logKeySize, size, "shards", shardCount)
+
+	// 1. 预上传
+	blockList, exact, err := readerBlockList(ctx, r, size, shardSize, shardCount, remotePath, progress)
+	if err != nil {
+		return nil, err
+	}
+	progress.start(PhasePrecreate, remotePath, 0)
+	uploadID, err := c.precreate(ctx, remotePath, size, blockList, opts.OnConflict)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. 逐片读取、计算 MD5 并上传
+	progress.start(PhaseUploading, remotePath, size)
+	if size == 0 {
+		if err := c.uploadPart(ctx, remotePath, uploadID, 0, bytes.NewReader(nil), 0); err != nil {
+			return nil, err
+		}
+		progress.start(PhaseCreating, remotePath, 0)
+		info, err := c.create(ctx, remotePath, uploadID, 0, []string{emptyBlockMD5}, opts.OnConflict)
+		if err != nil {
+			return nil, err
+		}
+		progress.done()
+		return &UploadResult{FileInfo: *info}, nil
+	}
+
+	md5List := make([]string, 0, shardCount)
+	var read int64
+	err = ProcessReaderInShards(io.LimitReader(r, size), shardSize, func(index int, data []byte, isLast bool) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		sum := md5.Sum(data)
+		blockMD5 := hex.EncodeToString(sum[:])
+		if exact && (index >= len(blockList) || blockList[index] != blockMD5) {
+			return fmt.Errorf("reader content changed after hashing: shard %d", index)
+		}
+		md5List = append(md5List, blockMD5)
+		read += int64(len(data))
+		return c.uploadPart(ctx, remotePath, uploadID, index, bytes.NewReader(data), int64(len(data)))
+	})
+	if err != nil {
+		return nil, err
+	}
+	if read != size {
+		return nil, fmt.Errorf("stream ended early: expected %d bytes, got %d", size, read)
+	}
+
+	// 3. 提交分片 MD5 列表创建文件
+	progress.start(PhaseCreating, remotePath, 0)
+	info, err := c.create(ctx, remotePath, uploadID, size, md5List, opts.OnConflict)
+	if err != nil {
+		return nil, err
+	}
+	progress.done()
+	return &UploadResult{FileInfo: *info}, nil
+}
+
+// readerBlockList 返回预上传使用的 block_list，exact 表示列表为真实的分片 MD5
+// r 实现 io.Seeker 时先读取一遍计算各分片的 MD5，再回到起始位置；
+// 否则上传前无法得到分片 MD5，只能提供数量正确的占位值，真实列表在分片上传完成后随 create 提交。
+// 后者依赖服务端以 create 提交的 block_list 校验并合并分片，开放平台文档没有写明这一点，
+// baidupantest 按此行为实现，需要避免这一依赖时传入可回读的 r（如 *os.File、*bytes.Reader）
+func readerBlockList(ctx context.Context, r io.Reader, size int64, shardSize int64, shardCount int, remotePath string, progress *progressTracker) (_ []string, exact bool, _ error) {
+	if size == 0 {
+		return []string{emptyBlockMD5}, true, nil
+	}
+	seeker, ok := r.(io.Seeker)
+	if !ok {
+		placeholders := make([]string, shardCount)
+		for i := range placeholders {
+			placeholders[i] = placeholderBlockMD5
+		}
+		return placeholders, false, nil
+	}
+	start, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, false, err
+	}
+	progress.start(PhaseHashing, remotePath, size)
+	blockList := make([]string, 0, shardCount)
+	var read int64
+	err = ProcessReaderInShards(io.LimitReader(r, size), shardSize, func(index int, data []byte, isLast bool) error {
+		sum := md5.Sum(data)
+		blockList = append(blockList, hex.EncodeToString(sum[:]))
+		read += int64(len(data))
+		progress.add(int64(len(data)))
+		return ctx.Err()
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if read != size {
+		return nil, false, fmt.Errorf("stream ended early: expected %d bytes, got %d", size, read)
+	}
+	if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+		return nil, false, err
+	}
+	return blockList, true, nil
+}
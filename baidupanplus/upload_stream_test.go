@@ -0,0 +1,118 @@
+package baidupanplus
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+	"testing/iotest"
+)
+
+// precreateRecorder 记录 precreate 请求提交的 block_list
+type precreateRecorder struct {
+	base http.RoundTripper
+
+	mu         sync.Mutex
+	blockLists [][]string
+}
+
+func (p *precreateRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Query().Get("method") == "precreate" && req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		form, _ := url.ParseQuery(string(body))
+		var list []string
+		_ = json.Unmarshal([]byte(form.Get("block_list")), &list)
+		p.mu.Lock()
+		p.blockLists = append(p.blockLists, list)
+		p.mu.Unlock()
+	}
+	return p.base.RoundTrip(req)
+}
+
+func shardMD5s(data []byte, shardSize int) []string {
+	var sums []string
+	for len(data) > 0 {
+		n := min(shardSize, len(data))
+		sum := md5.Sum(data[:n])
+		sums = append(sums, hex.EncodeToString(sum[:]))
+		data = data[n:]
+	}
+	return sums
+}
+
+func TestUploadReaderBlockList(t *testing.T) {
+	_, srv := newTestClient(t)
+	recorder := &precreateRecorder{base: srv.Client().Transport}
+	cfg := newTestConfig(srv)
+	cfg.HTTPClient = &http.Client{Transport: recorder}
+	c := NewClient(cfg)
+	data := testData(9<<20 + 123)
+	want := shardMD5s(data, 4<<20)
+
+	tests := []struct {
+		name   string
+		reader io.Reader
+		exact  bool
+	}{
+		// 可回读的 reader 在预上传时提交真实的分片 MD5
+		{"seeker", bytes.NewReader(data), true},
+		// 不可回读的流只能提交数量正确的占位值，create 时提交真实列表
+		{"stream", iotest.HalfReader(bytes.NewReader(data)), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			remote := "/apps/test/" + tt.name + ".bin"
+			if _, err := c.UploadReaderWithOptions(context.Background(), tt.reader, int64(len(data)), remote, UploadOptions{}); err != nil {
+				t.Fatalf("upload: %v", err)
+			}
+			got, ok := srv.ReadFile(remote)
+			if !ok || !bytes.Equal(got, data) {
+				t.Fatalf("remote content differs (exists=%v, %d bytes)", ok, len(got))
+			}
+
+			recorder.mu.Lock()
+			list := recorder.blockLists[len(recorder.blockLists)-1]
+			recorder.mu.Unlock()
+			if len(list) != len(want) {
+				t.Fatalf("precreate block_list has %d entries, want %d", len(list), len(want))
+			}
+			for i := range list {
+				if exact := list[i] == want[i]; exact != tt.exact {
+					t.Errorf("precreate block %d = %s, real md5 %s", i, list[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestUploadReaderEmpty(t *testing.T) {
+	c, srv := newTestClient(t)
+	if _, err := c.UploadReaderWithOptions(context.Background(), bytes.NewReader(nil), 0, "/apps/test/empty", UploadOptions{}); err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	if got, ok := srv.ReadFile("/apps/test/empty"); !ok || len(got) != 0 {
+		t.Fatalf("ReadFile = %q, %v", got, ok)
+	}
+}
+
+func TestUploadReaderShortStream(t *testing.T) {
+	c, srv := newTestClient(t)
+	data := testData(5 << 20)
+	_, err := c.UploadReaderWithOptions(context.Background(), iotest.HalfReader(bytes.NewReader(data[:1<<20])), int64(len(data)), "/apps/test/short", UploadOptions{})
+	if err == nil {
+		t.Fatal("upload of a short stream succeeded")
+	}
+	if srv.Exists("/apps/test/short") {
+		t.Fatal("short stream created a remote file")
+	}
+}
@@ -0,0 +1,107 @@
+package baidupanplus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrInsufficientQuota 网盘剩余空间不足以保存待上传的文件
+var ErrInsufficientQuota = errors.New("insufficient quota")
+
+// Quota 网盘容量信息，单位为字节
+type Quota struct {
+	Total int64 `json:"total"`
+	Used  int64 `json:"used"`
+	Free  int64 `json:"free"`
+	// ExpireSoon 是否有容量将在 7 天内到期
+	ExpireSoon bool `json:"expire_soon"`
+}
+
+// VipType 会员类型
+type VipType int
+
+const (
+	VipTypeNormal VipType = 0 // 普通用户
+	VipTypeVIP    VipType = 1 // 普通会员
+	VipTypeSVIP   VipType = 2 // 超级会员
+)
+
+// String 返回会员类型的中文名称
+func (v VipType) String() string {
+	switch v {
+	case VipTypeNormal:
+		return "普通用户"
+	case VipTypeVIP:
+		return "普通会员"
+	case VipTypeSVIP:
+		return "超级会员"
+	}
+	return fmt.Sprintf("未知会员类型(%d)", int(v))
+}
+
+// UserInfo 网盘用户信息
+type UserInfo struct {
+	Uk          int64   `json:"uk"`
+	BaiduName   string  `json:"baidu_name"`
+	NetdiskName string  `json:"netdisk_name"`
+	AvatarURL   string  `json:"avatar_url"`
+	VipType     VipType `json:"vip_type"`
+}
+
+// Quota 查询网盘容量
+func (c *Client) Quota(ctx context.Context) (*Quota, error) {
+	resp, _, err := c.api.UserinfoApi.Apiquota(ctx).
+		AccessToken(c.cfg.AccessToken).
+		Checkfree(1).
+		Checkexpire(1).
+		Execute()
+	if err != nil {
+		c.logger.Error("failed to execute quota", logKeyOp, "quota", logKeyError, err)
+		return nil, err
+	}
+	if resp.GetErrno() != 0 {
+		return nil, newErrnoError("quota", resp.GetErrno())
+	}
+	return &Quota{
+		Total:      resp.GetTotal(),
+		Used:       resp.GetUsed(),
+		Free:       resp.GetFree(),
+		ExpireSoon: resp.GetExpire(),
+	}, nil
+}
+
+// UserInfo 查询当前授权用户的信息
+func (c *Client) UserInfo(ctx context.Context) (*UserInfo, error) {
+	resp, _, err := c.api.UserinfoApi.Xpannasuinfo(ctx).
+		AccessToken(c.cfg.AccessToken).
+		Execute()
+	if err != nil {
+		c.logger.Error("failed to execute uinfo", logKeyOp, "uinfo", logKeyError, err)
+		return nil, err
+	}
+	if resp.GetErrno() != 0 {
+		return nil, newErrnoError("uinfo", resp.GetErrno())
+	}
+	return &UserInfo{
+		Uk:          resp.GetUk(),
+		BaiduName:   resp.GetBaiduName(),
+		NetdiskName: resp.GetNetdiskName(),
+		AvatarURL:   resp.GetAvatarUrl(),
+		VipType:     VipType(resp.GetVipType()),
+	}, nil
+}
+
+// checkQuota 上传前确认剩余空间足够保存 size 字节
+// 查询容量失败时只记录日志并放行，由上传接口本身返回空间不足等错误
+func (c *Client) checkQuota(ctx context.Context, remotePath string, size int64) error {
+	quota, err := c.Quota(ctx)
+	if err != nil {
+		c.logger.Warn("failed to check quota before upload", logKeyOp, "upload", logKeyPath, remotePath, logKeyError, err)
+		return nil
+	}
+	if size > quota.Free {
+		return fmt.Errorf("%w: %s needs %d bytes, %d bytes free", ErrInsufficientQuota, remotePath, size, quota.Free)
+	}
+	return nil
+}
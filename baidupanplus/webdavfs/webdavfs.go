@@ -0,0 +1,542 @@
+// Package webdavfs 基于 baidupanplus 实现 golang.org/x/net/webdav.FileSystem，用于在文件管理器中以 WebDAV 挂载网盘
+//
+// 读取通过 dlink 的 Range 请求完成；写入先缓存在本地临时文件中，有修改时 Close 以分片上传覆盖远程文件。
+// Mkdir、RemoveAll、Rename 分别对应网盘的创建目录、删除与重命名 / 移动
+//
+//	fs := webdavfs.New(client, webdavfs.Config{Root: "/apps/myapp"})
+//	http.Handle("/", &webdav.Handler{FileSystem: fs, LockSystem: webdav.NewMemLS()})
+package webdavfs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/webdav"
+
+	"github.com/S-zhi/baidupansdk/baidupanplus"
+)
+
+// defaultListCacheTTL 目录列表默认的缓存时长
+// PROPFIND 会对目录下的每一项分别 Stat 与 OpenFile，缓存使一次 PROPFIND 只需列出一次目录
+const defaultListCacheTTL = 5 * time.Second
+
+// Config 文件系统配置
+type Config struct {
+	// Root 映射为 WebDAV 根目录的网盘目录，默认为 "/"
+	Root string
+	// TempDir 写入文件时的本地缓存目录，默认为 os.TempDir()
+	TempDir string
+	// ListCacheTTL 目录列表的缓存时长，默认 5 秒，小于 0 时不缓存；通过本文件系统的修改会立即清空缓存
+	ListCacheTTL time.Duration
+}
+
+// FileSystem 实现 webdav.FileSystem
+type FileSystem struct {
+	client  *baidupanplus.Client
+	root    string
+	tempDir string
+	ttl     time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cachedDir // 按网盘目录缓存的列表
+}
+
+// cachedDir 缓存的目录列表
+type cachedDir struct {
+	entries map[string]baidupanplus.FileInfo
+	at      time.Time
+}
+
+var _ webdav.FileSystem = (*FileSystem)(nil)
+
+// New 创建文件系统，所有操作都通过 client 访问网盘
+func New(client *baidupanplus.Client, cfg Config) *FileSystem {
+	ttl := cfg.ListCacheTTL
+	if ttl == 0 {
+		ttl = defaultListCacheTTL
+	}
+	return &FileSystem{
+		client:  client,
+		root:    path.Clean("/" + cfg.Root),
+		tempDir: cfg.TempDir,
+		ttl:     ttl,
+		cache:   map[string]cachedDir{},
+	}
+}
+
+// remote 将 WebDAV 路径映射为网盘路径
+func (fsys *FileSystem) remote(name string) string {
+	return path.Join(fsys.root, path.Clean("/"+name))
+}
+
+// Stat 实现 webdav.FileSystem
+func (fsys *FileSystem) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	info, err := fsys.stat(ctx, fsys.remote(name))
+	if err != nil {
+		return nil, pathError("stat", name, err)
+	}
+	return newFileInfo(info), nil
+}
+
+// Mkdir 实现 webdav.FileSystem，父目录不存在或目标已存在时失败
+func (fsys *FileSystem) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	remotePath := fsys.remote(name)
+	// Client.Mkdir 对已存在的目录视为成功，WebDAV 则要求 MKCOL 已存在的路径时返回 405
+	if _, err := fsys.stat(ctx, remotePath); err == nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	} else if baidupanplus.CategoryOf(err) != baidupanplus.CategoryNotFound {
+		return pathError("mkdir", name, err)
+	}
+	defer fsys.invalidate()
+	if _, err := fsys.client.Mkdir(ctx, remotePath, baidupanplus.ConflictFail); err != nil {
+		return pathError("mkdir", name, err)
+	}
+	return nil
+}
+
+// RemoveAll 实现 webdav.FileSystem，目录会被递归删除，不存在时不报错
+func (fsys *FileSystem) RemoveAll(ctx context.Context, name string) error {
+	remotePath := fsys.remote(name)
+	if remotePath == fsys.root {
+		return &fs.PathError{Op: "removeall", Path: name, Err: fs.ErrInvalid}
+	}
+	defer fsys.invalidate()
+	err := fsys.client.Remove(ctx, remotePath)
+	if err != nil && baidupanplus.CategoryOf(err) != baidupanplus.CategoryNotFound {
+		return pathError("removeall", name, err)
+	}
+	return nil
+}
+
+// Rename 实现 webdav.FileSystem：同一目录下使用重命名，否则使用移动；目标已存在时失败
+func (fsys *FileSystem) Rename(ctx context.Context, oldName, newName string) error {
+	src, dst := fsys.remote(oldName), fsys.remote(newName)
+	if src == fsys.root || dst == fsys.root {
+		return &fs.PathError{Op: "rename", Path: oldName, Err: fs.ErrInvalid}
+	}
+	defer fsys.invalidate()
+	var err error
+	if path.Dir(src) == path.Dir(dst) {
+		err = fsys.client.Rename(ctx, src, path.Base(dst))
+	} else {
+		err = fsys.client.Move(ctx, src, path.Dir(dst), path.Base(dst), baidupanplus.OnDupFail)
+	}
+	if err != nil {
+		return pathError("rename", oldName, err)
+	}
+	return nil
+}
+
+// OpenFile 实现 webdav.FileSystem
+// 只读打开时返回基于 dlink 的读取器，数据在第一次读取时才请求；
+// 可写打开时数据写入本地临时文件，有修改时 Close 上传并覆盖远程文件
+func (fsys *FileSystem) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	remotePath := fsys.remote(name)
+	if flag&(os.O_WRONLY|os.O_RDWR) != 0 {
+		return fsys.openWriter(ctx, name, remotePath, flag)
+	}
+
+	info, err := fsys.stat(ctx, remotePath)
+	if err != nil {
+		return nil, pathError("open", name, err)
+	}
+	if info.IsDir() {
+		return &dirFile{ctx: ctx, fsys: fsys, info: info}, nil
+	}
+	r, err := fsys.client.OpenFileInfo(ctx, info)
+	if err != nil {
+		return nil, pathError("open", name, err)
+	}
+	return &readFile{ReadSeekCloser: r, info: info}, nil
+}
+
+// openWriter 按 flag 创建可写文件，已有内容在第一次读写或 Seek 时才下载
+// 目录返回只读的目录句柄：webdav 处理 PROPPATCH 时以 O_RDWR 打开目标，目录同样需要能打开
+func (fsys *FileSystem) openWriter(ctx context.Context, name string, remotePath string, flag int) (webdav.File, error) {
+	info, err := fsys.stat(ctx, remotePath)
+	switch {
+	case err == nil:
+		if flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0 {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+		}
+		if info.IsDir() {
+			return &dirFile{ctx: ctx, fsys: fsys, info: info}, nil
+		}
+	case baidupanplus.CategoryOf(err) == baidupanplus.CategoryNotFound:
+		if flag&os.O_CREATE == 0 {
+			return nil, pathError("open", name, err)
+		}
+		// 上传会自动创建父目录，这里先检查，使 WebDAV 对不存在的父目录返回 409
+		parent, err := fsys.stat(ctx, path.Dir(remotePath))
+		if err != nil {
+			return nil, pathError("open", name, err)
+		}
+		if !parent.IsDir() {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+		}
+		info = nil
+	default:
+		return nil, pathError("open", name, err)
+	}
+
+	tmp, err := os.CreateTemp(fsys.tempDir, "baidupan-webdav-*")
+	if err != nil {
+		return nil, err
+	}
+	f := &writeFile{File: tmp, ctx: ctx, fsys: fsys, name: name, remotePath: remotePath, info: info, appendMode: flag&os.O_APPEND != 0}
+	// 新建或截断的文件无需下载，且即使没有写入也要在 Close 时上传
+	if info == nil || flag&os.O_TRUNC != 0 {
+		f.loaded = true
+		f.dirty = true
+	}
+	return f, nil
+}
+
+// stat 查询网盘路径的信息，优先使用父目录的缓存列表
+func (fsys *FileSystem) stat(ctx context.Context, remotePath string) (*baidupanplus.FileInfo, error) {
+	if remotePath == "/" {
+		return fsys.client.Stat(ctx, remotePath)
+	}
+	entries, err := fsys.list(ctx, path.Dir(remotePath))
+	if err != nil {
+		return nil, err
+	}
+	info, ok := entries[path.Base(remotePath)]
+	if !ok {
+		return nil, baidupanplus.ErrNotFound
+	}
+	return &info, nil
+}
+
+// list 列出网盘目录，结果缓存 ttl
+func (fsys *FileSystem) list(ctx context.Context, dir string) (map[string]baidupanplus.FileInfo, error) {
+	fsys.mu.Lock()
+	cached, ok := fsys.cache[dir]
+	fsys.mu.Unlock()
+	if ok && time.Since(cached.at) < fsys.ttl {
+		return cached.entries, nil
+	}
+
+	files, err := fsys.client.List(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	entries := make(map[string]baidupanplus.FileInfo, len(files))
+	for _, f := range files {
+		entries[f.ServerFilename] = f
+	}
+	if fsys.ttl > 0 {
+		fsys.mu.Lock()
+		fsys.cache[dir] = cachedDir{entries: entries, at: time.Now()}
+		fsys.mu.Unlock()
+	}
+	return entries, nil
+}
+
+// invalidate 清空目录列表缓存
+func (fsys *FileSystem) invalidate() {
+	fsys.mu.Lock()
+	clear(fsys.cache)
+	fsys.mu.Unlock()
+}
+
+// pathError 将 SDK 错误转换为 webdav 能识别的 *fs.PathError：
+// webdav 通过 os.IsNotExist / os.IsExist 等判断状态码，这些函数不会展开 %w 包装的错误
+func pathError(op, name string, err error) error {
+	switch baidupanplus.CategoryOf(err) {
+	case baidupanplus.CategoryNotFound:
+		err = fs.ErrNotExist
+	case baidupanplus.CategoryExists:
+		err = fs.ErrExist
+	case baidupanplus.CategoryPermission:
+		err = fs.ErrPermission
+	}
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+// fileInfo 实现 os.FileInfo，同时实现 webdav.ETager 与 webdav.ContentTyper，
+// 避免 webdav 为计算 ETag 与内容类型而读取文件内容
+type fileInfo struct {
+	info baidupanplus.FileInfo
+}
+
+var (
+	_ webdav.ETager       = fileInfo{}
+	_ webdav.ContentTyper = fileInfo{}
+)
+
+func newFileInfo(info *baidupanplus.FileInfo) fileInfo {
+	return fileInfo{info: *info}
+}
+
+// Name 实现 os.FileInfo
+func (fi fileInfo) Name() string {
+	return fi.info.ServerFilename
+}
+
+// Size 实现 os.FileInfo
+func (fi fileInfo) Size() int64 {
+	return fi.info.Size
+}
+
+// Mode 实现 os.FileInfo
+func (fi fileInfo) Mode() os.FileMode {
+	if fi.info.IsDir() {
+		return os.ModeDir | 0755
+	}
+	return 0644
+}
+
+// ModTime 实现 os.FileInfo
+func (fi fileInfo) ModTime() time.Time {
+	return fi.info.ModTime()
+}
+
+// IsDir 实现 os.FileInfo
+func (fi fileInfo) IsDir() bool {
+	return fi.info.IsDir()
+}
+
+// Sys 返回 *baidupanplus.FileInfo
+func (fi fileInfo) Sys() any {
+	return &fi.info
+}
+
+// ETag 实现 webdav.ETager，使用文件 MD5
+func (fi fileInfo) ETag(ctx context.Context) (string, error) {
+	if fi.info.Md5 == "" {
+		return "", webdav.ErrNotImplemented
+	}
+	return `"` + fi.info.Md5 + `"`, nil
+}
+
+// ContentType 实现 webdav.ContentTyper，按扩展名判断，无法判断时不读取内容
+func (fi fileInfo) ContentType(ctx context.Context) (string, error) {
+	if ctype := mime.TypeByExtension(path.Ext(fi.info.ServerFilename)); ctype != "" {
+		return ctype, nil
+	}
+	return "application/octet-stream", nil
+}
+
+// errReadOnly 向只读打开的文件写入
+var errReadOnly = errors.New("file is opened read-only")
+
+// readFile 只读打开的远程文件
+type readFile struct {
+	io.ReadSeekCloser
+	info *baidupanplus.FileInfo
+}
+
+// Readdir 实现 webdav.File
+func (f *readFile) Readdir(count int) ([]os.FileInfo, error) {
+	return nil, &fs.PathError{Op: "readdir", Path: f.info.Path, Err: errors.New("not a directory")}
+}
+
+// Stat 实现 webdav.File
+func (f *readFile) Stat() (os.FileInfo, error) {
+	return newFileInfo(f.info), nil
+}
+
+// Write 实现 webdav.File
+func (f *readFile) Write(p []byte) (int, error) {
+	return 0, &fs.PathError{Op: "write", Path: f.info.Path, Err: errReadOnly}
+}
+
+// dirFile 打开的远程目录
+type dirFile struct {
+	ctx     context.Context
+	fsys    *FileSystem
+	info    *baidupanplus.FileInfo
+	entries []os.FileInfo // 第一次 Readdir 时列出
+	listed  bool
+}
+
+// Readdir 实现 webdav.File，语义与 os.File.Readdir 相同
+func (d *dirFile) Readdir(count int) ([]os.FileInfo, error) {
+	if !d.listed {
+		entries, err := d.fsys.list(d.ctx, d.info.Path)
+		if err != nil {
+			return nil, pathError("readdir", d.info.Path, err)
+		}
+		for _, e := range entries {
+			d.entries = append(d.entries, newFileInfo(&e))
+		}
+		slices.SortFunc(d.entries, func(a, b os.FileInfo) int { return strings.Compare(a.Name(), b.Name()) })
+		d.listed = true
+	}
+	if count <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	n := min(count, len(d.entries))
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
+
+// Stat 实现 webdav.File
+func (d *dirFile) Stat() (os.FileInfo, error) {
+	return newFileInfo(d.info), nil
+}
+
+// Read 实现 webdav.File
+func (d *dirFile) Read(p []byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.Path, Err: errors.New("is a directory")}
+}
+
+// Seek 实现 webdav.File
+func (d *dirFile) Seek(offset int64, whence int) (int64, error) {
+	return 0, &fs.PathError{Op: "seek", Path: d.info.Path, Err: errors.New("is a directory")}
+}
+
+// Write 实现 webdav.File
+func (d *dirFile) Write(p []byte) (int, error) {
+	return 0, &fs.PathError{Op: "write", Path: d.info.Path, Err: errors.New("is a directory")}
+}
+
+// Close 实现 webdav.File
+func (d *dirFile) Close() error {
+	return nil
+}
+
+// writeFile 可写打开的文件，数据缓存在本地临时文件中，Close 时上传
+// 打开已有文件时不下载内容，只在第一次 Read、Write 或 Seek 时下载；没有修改时 Close 不上传
+type writeFile struct {
+	*os.File
+	ctx        context.Context
+	fsys       *FileSystem
+	name       string
+	remotePath string
+	info       *baidupanplus.FileInfo // 打开时远程文件的信息，新建时为 nil
+	appendMode bool
+	loaded     bool // 本地缓存已包含远程文件的内容
+	dirty      bool // Close 时需要上传
+	closed     bool
+	uploaded   *baidupanplus.FileInfo // Close 上传成功后远程文件的信息
+}
+
+// load 下载远程文件的已有内容到本地缓存，O_APPEND 打开时定位到末尾
+func (f *writeFile) load() error {
+	if f.loaded {
+		return nil
+	}
+	if _, err := f.fsys.client.DownloadTo(f.ctx, f.remotePath, f.File); err != nil {
+		return pathError("open", f.name, err)
+	}
+	if !f.appendMode {
+		if _, err := f.File.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+	}
+	f.loaded = true
+	return nil
+}
+
+// Read 实现 webdav.File
+func (f *writeFile) Read(p []byte) (int, error) {
+	if err := f.load(); err != nil {
+		return 0, err
+	}
+	return f.File.Read(p)
+}
+
+// Write 实现 webdav.File
+func (f *writeFile) Write(p []byte) (int, error) {
+	if err := f.load(); err != nil {
+		return 0, err
+	}
+	f.dirty = true
+	return f.File.Write(p)
+}
+
+// Seek 实现 webdav.File
+func (f *writeFile) Seek(offset int64, whence int) (int64, error) {
+	if err := f.load(); err != nil {
+		return 0, err
+	}
+	return f.File.Seek(offset, whence)
+}
+
+// Readdir 实现 webdav.File
+func (f *writeFile) Readdir(count int) ([]os.FileInfo, error) {
+	return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: errors.New("not a directory")}
+}
+
+// Stat 实现 webdav.File，返回本地缓存的大小与修改时间，尚未下载时返回远程文件的信息
+// webdav 处理 PUT 时先 Stat 再 Close，最后才取 ETag，因此返回的 ETag 在上传完成后与之后 PROPFIND 得到的一致
+func (f *writeFile) Stat() (os.FileInfo, error) {
+	if !f.loaded {
+		return writeFileInfo{fileInfo: newFileInfo(f.info), file: f}, nil
+	}
+	st, err := f.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return writeFileInfo{fileInfo: newFileInfo(&baidupanplus.FileInfo{
+		Path:           f.remotePath,
+		ServerFilename: path.Base(f.remotePath),
+		Size:           st.Size(),
+		ServerMtime:    st.ModTime().Unix(),
+	}), file: f}, nil
+}
+
+// Close 实现 webdav.File，有修改时上传本地缓存并覆盖远程文件，无论成功与否都删除缓存
+// 上传不随打开文件的请求一起取消：客户端在数据写完后断开时，已写入的内容仍会保存到网盘
+func (f *writeFile) Close() error {
+	if f.closed {
+		return os.ErrClosed
+	}
+	f.closed = true
+	defer os.Remove(f.File.Name())
+	if err := f.File.Close(); err != nil {
+		return err
+	}
+	if !f.dirty {
+		return nil
+	}
+	ctx := context.WithoutCancel(f.ctx)
+	defer f.fsys.invalidate()
+	res, err := f.fsys.client.UploadFileWithOptions(ctx, f.File.Name(), f.remotePath, baidupanplus.UploadOptions{OnConflict: baidupanplus.ConflictOverwrite})
+	if err != nil {
+		return pathError("close", f.name, err)
+	}
+	// 重新列出目录，使 ETag 与之后 Stat / PROPFIND 使用的信息相同；失败时退回上传返回的信息
+	f.fsys.invalidate()
+	if info, err := f.fsys.stat(ctx, f.remotePath); err == nil {
+		f.uploaded = info
+	} else {
+		f.uploaded = &res.FileInfo
+	}
+	return nil
+}
+
+// writeFileInfo 可写文件的 os.FileInfo，上传完成后 ETag 使用远程文件的信息
+type writeFileInfo struct {
+	fileInfo
+	file *writeFile
+}
+
+// ETag 实现 webdav.ETager，未修改的文件使用打开时远程文件的信息
+func (fi writeFileInfo) ETag(ctx context.Context) (string, error) {
+	switch {
+	case fi.file.uploaded != nil:
+		return newFileInfo(fi.file.uploaded).ETag(ctx)
+	case !fi.file.dirty && fi.file.info != nil:
+		return newFileInfo(fi.file.info).ETag(ctx)
+	default:
+		return "", webdav.ErrNotImplemented
+	}
+}
@@ -0,0 +1,237 @@
+package webdavfs
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/webdav"
+
+	"github.com/S-zhi/baidupansdk/baidupanplus"
+	"github.com/S-zhi/baidupansdk/baidupantest"
+)
+
+// newTestFS 启动模拟网盘服务并创建映射到 /apps/dav 的文件系统
+func newTestFS(t *testing.T) (*FileSystem, *baidupantest.Server) {
+	t.Helper()
+	srv := baidupantest.NewServer()
+	t.Cleanup(srv.Close)
+	srv.SetAccessToken("token")
+	srv.AddDir("/apps/dav")
+	client := baidupanplus.NewClient(baidupanplus.Config{
+		AccessToken: "token",
+		HTTPClient:  srv.Client(),
+		Logger:      slog.New(slog.DiscardHandler),
+	})
+	return New(client, Config{Root: "/apps/dav", TempDir: t.TempDir()}), srv
+}
+
+// multistatus PROPFIND 响应中用到的字段
+type multistatus struct {
+	Responses []struct {
+		Href   string `xml:"href"`
+		Length string `xml:"propstat>prop>getcontentlength"`
+		ETag   string `xml:"propstat>prop>getetag"`
+	} `xml:"response"`
+}
+
+func TestWebDAV(t *testing.T) {
+	fsys, srv := newTestFS(t)
+	dav := httptest.NewServer(&webdav.Handler{FileSystem: fsys, LockSystem: webdav.NewMemLS()})
+	defer dav.Close()
+
+	do := func(method, p string, body string, header map[string]string, wantStatus int) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(method, dav.URL+p, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		if resp.StatusCode != wantStatus {
+			msg, _ := io.ReadAll(resp.Body)
+			t.Fatalf("%s %s: status %d, want %d: %s", method, p, resp.StatusCode, wantStatus, msg)
+		}
+		return resp
+	}
+	read := func(resp *http.Response) string {
+		t.Helper()
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	do("MKCOL", "/docs", "", nil, http.StatusCreated)
+	do("MKCOL", "/docs", "", nil, http.StatusMethodNotAllowed)
+	do("MKCOL", "/missing/docs", "", nil, http.StatusConflict)
+	if !srv.Exists("/apps/dav/docs") {
+		t.Fatal("MKCOL did not create the remote directory")
+	}
+
+	content := "hello, webdav"
+	put := do("PUT", "/docs/a.txt", content, nil, http.StatusCreated)
+	if got, ok := srv.ReadFile("/apps/dav/docs/a.txt"); !ok || string(got) != content {
+		t.Fatalf("remote content = %q, %v", got, ok)
+	}
+	do("PUT", "/missing/a.txt", content, nil, http.StatusConflict)
+
+	var ms multistatus
+	propfind := do("PROPFIND", "/docs", "", map[string]string{"Depth": "1"}, http.StatusMultiStatus)
+	if err := xml.Unmarshal([]byte(read(propfind)), &ms); err != nil {
+		t.Fatal(err)
+	}
+	if len(ms.Responses) != 2 {
+		t.Fatalf("PROPFIND returned %d entries, want 2", len(ms.Responses))
+	}
+	file := ms.Responses[1]
+	if file.Href != "/docs/a.txt" || file.Length != "13" {
+		t.Errorf("PROPFIND entry = %+v", file)
+	}
+	// PUT 返回的 ETag 与之后 PROPFIND 看到的一致
+	if etag := put.Header.Get("ETag"); etag == "" || etag != file.ETag {
+		t.Errorf("PUT ETag %q, PROPFIND ETag %q", etag, file.ETag)
+	}
+
+	get := do("GET", "/docs/a.txt", "", map[string]string{"Range": "bytes=7-11"}, http.StatusPartialContent)
+	if got := read(get); got != "webda" {
+		t.Errorf("ranged GET = %q, want %q", got, "webda")
+	}
+	if got := get.Header.Get("Content-Range"); got != "bytes 7-11/13" {
+		t.Errorf("Content-Range = %q", got)
+	}
+
+	// 同目录下为重命名，跨目录为移动
+	do("MOVE", "/docs/a.txt", "", map[string]string{"Destination": dav.URL + "/docs/b.txt"}, http.StatusCreated)
+	do("MKCOL", "/archive", "", nil, http.StatusCreated)
+	do("MOVE", "/docs/b.txt", "", map[string]string{"Destination": dav.URL + "/archive/b.txt"}, http.StatusCreated)
+	if srv.Exists("/apps/dav/docs/a.txt") || srv.Exists("/apps/dav/docs/b.txt") {
+		t.Error("MOVE left the source behind")
+	}
+	if got, _ := srv.ReadFile("/apps/dav/archive/b.txt"); string(got) != content {
+		t.Errorf("moved content = %q", got)
+	}
+	if got := read(do("GET", "/archive/b.txt", "", nil, http.StatusOK)); got != content {
+		t.Errorf("GET after MOVE = %q", got)
+	}
+
+	do("DELETE", "/archive", "", nil, http.StatusNoContent)
+	if srv.Exists("/apps/dav/archive") || srv.Exists("/apps/dav/archive/b.txt") {
+		t.Error("DELETE left the directory behind")
+	}
+	do("GET", "/archive/b.txt", "", nil, http.StatusNotFound)
+}
+
+func TestWriteFileCloseAfterCancel(t *testing.T) {
+	fsys, srv := newTestFS(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	f, err := fsys.OpenFile(ctx, "/a.txt", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("data")); err != nil {
+		t.Fatal(err)
+	}
+	// 打开文件的请求已结束，Close 仍应完成上传
+	cancel()
+	if err := f.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if got, ok := srv.ReadFile("/apps/dav/a.txt"); !ok || string(got) != "data" {
+		t.Fatalf("remote content = %q, %v", got, ok)
+	}
+}
+
+func TestProppatchDoesNotTransfer(t *testing.T) {
+	fsys, srv := newTestFS(t)
+	srv.AddFile("/apps/dav/a.bin", make([]byte, 1<<20))
+	srv.AddDir("/apps/dav/docs")
+	dav := httptest.NewServer(&webdav.Handler{FileSystem: fsys, LockSystem: webdav.NewMemLS()})
+	defer dav.Close()
+
+	body := `<?xml version="1.0" encoding="utf-8"?>
+<D:propertyupdate xmlns:D="DAV:" xmlns:Z="urn:example"><D:set><D:prop><Z:color>red</Z:color></D:prop></D:set></D:propertyupdate>`
+	for _, p := range []string{"/a.bin", "/docs"} {
+		req, err := http.NewRequest("PROPPATCH", dav.URL+p, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusMultiStatus {
+			t.Fatalf("PROPPATCH %s: status %d: %s", p, resp.StatusCode, msg)
+		}
+	}
+	for _, op := range []baidupantest.Op{baidupantest.OpDownload, baidupantest.OpPrecreate, baidupantest.OpUpload, baidupantest.OpCreate} {
+		if n := srv.Requests(op); n != 0 {
+			t.Errorf("%s requests = %d, want 0", op, n)
+		}
+	}
+}
+
+func TestWriteFileLazyLoad(t *testing.T) {
+	fsys, srv := newTestFS(t)
+	srv.AddFile("/apps/dav/a.txt", []byte("hello"))
+
+	// 未修改时关闭不上传
+	f, err := fsys.OpenFile(context.Background(), "/a.txt", os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st, err := f.Stat(); err != nil || st.Size() != 5 {
+		t.Fatalf("stat = %v, %v", st, err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := srv.Requests(baidupantest.OpDownload) + srv.Requests(baidupantest.OpPrecreate); n != 0 {
+		t.Fatalf("unmodified file caused %d transfer requests", n)
+	}
+
+	// 第一次 Seek 时下载已有内容，写入后 Close 上传
+	f, err = fsys.OpenFile(context.Background(), "/a.txt", os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Seek(0, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte(", world")); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := srv.ReadFile("/apps/dav/a.txt"); string(got) != "hello, world" {
+		t.Errorf("remote content = %q", got)
+	}
+
+	// O_TRUNC 即使没有写入也会上传空文件
+	f, err = fsys.OpenFile(context.Background(), "/a.txt", os.O_RDWR|os.O_TRUNC, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := srv.ReadFile("/apps/dav/a.txt"); !ok || len(got) != 0 {
+		t.Errorf("remote content after O_TRUNC = %q, %v", got, ok)
+	}
+}
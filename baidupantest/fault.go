@@ -0,0 +1,147 @@
+package baidupantest
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Op 模拟服务的接口，用于故障注入与请求计数
+type Op string
+
+const (
+	OpList      Op = "list"      // 目录列表
+	OpListAll   Op = "listall"   // 递归列表
+	OpSearch    Op = "search"    // 搜索
+	OpDocList   Op = "doclist"   // 文档列表
+	OpImageList Op = "imagelist" // 图片列表
+	OpFileMetas Op = "filemetas" // 文件信息
+	OpPrecreate Op = "precreate" // 预上传
+	OpUpload    Op = "upload"    // 分片上传 superfile2
+	OpCreate    Op = "create"    // 创建文件或目录
+	OpCopy      Op = "copy"      // 复制
+	OpMove      Op = "move"      // 移动
+	OpRename    Op = "rename"    // 重命名
+	OpDelete    Op = "delete"    // 删除
+	OpQuota     Op = "quota"     // 容量
+	OpUserInfo  Op = "uinfo"     // 用户信息
+	OpDownload  Op = "download"  // dlink 下载
+	OpThumbnail Op = "thumbnail" // 缩略图下载
+)
+
+// 模拟服务使用的 errno
+const (
+	errnoAuth      = -6    // 身份验证失败
+	errnoInvalid   = -7    // 文件或目录名错误
+	errnoExists    = -8    // 文件或目录已存在
+	errnoNotFound  = -9    // 文件或目录不存在
+	errnoQuota     = -10   // 空间不足
+	errnoParam     = 2     // 参数错误
+	errnoPartial   = 12    // 批量操作部分失败
+	errnoBlockMiss = 31363 // 分片缺失或 MD5 不符
+	errnoExpired   = 31360 // 下载链接已过期
+)
+
+// Fault 注入到某个接口的故障，同一接口的多个故障按注入顺序依次生效
+type Fault struct {
+	Errno          int           // 非 0 时返回该 errno，不执行实际操作
+	StatusCode     int           // 非 0 时使用该 HTTP 状态码
+	Latency        time.Duration // 处理请求前的延迟
+	DropConnection bool          // 不返回响应直接断开连接
+	DropAfter      int64         // 仅对下载生效：输出指定字节数的文件内容后断开连接
+	Times          int           // 生效次数，0 表示一直生效
+}
+
+// InjectFault 为接口 op 注入故障
+func (s *Server) InjectFault(op Op, f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults[op] = append(s.faults[op], &f)
+}
+
+// ClearFaults 清除全部已注入的故障
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = map[Op][]*Fault{}
+}
+
+// Requests 返回接口 op 收到的请求数，包括被故障拦截的请求
+func (s *Server) Requests(op Op) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[op]
+}
+
+// takeFault 取出接口 op 当前生效的故障并扣减次数，调用方需持有锁
+func (s *Server) takeFault(op Op) *Fault {
+	faults := s.faults[op]
+	if len(faults) == 0 {
+		return nil
+	}
+	f := faults[0]
+	if f.Times > 0 {
+		f.Times--
+		if f.Times == 0 {
+			s.faults[op] = faults[1:]
+		}
+	}
+	return f
+}
+
+// apply 执行故障，返回 true 表示请求已处理完毕
+func (f *Fault) apply(w http.ResponseWriter, r *http.Request, op Op) bool {
+	if f.Latency > 0 {
+		select {
+		case <-time.After(f.Latency):
+		case <-r.Context().Done():
+			return true
+		}
+	}
+	if f.DropConnection {
+		dropConnection(w)
+		return true
+	}
+	if f.Errno != 0 || f.StatusCode != 0 {
+		writeErrno(w, op, f.StatusCode, f.Errno)
+		return true
+	}
+	return false
+}
+
+// dropConnection 接管并直接关闭底层连接
+func dropConnection(w http.ResponseWriter) {
+	conn, _, err := http.NewResponseController(w).Hijack()
+	if err == nil {
+		conn.Close()
+	}
+}
+
+var errDropped = errors.New("baidupantest: connection dropped")
+
+// dropWriter 输出 remain 字节响应体后断开连接
+type dropWriter struct {
+	http.ResponseWriter
+	remain  int64
+	dropped bool
+}
+
+func (d *dropWriter) Write(p []byte) (int, error) {
+	if d.dropped {
+		return 0, errDropped
+	}
+	if int64(len(p)) < d.remain {
+		n, err := d.ResponseWriter.Write(p)
+		d.remain -= int64(n)
+		return n, err
+	}
+	n, _ := d.ResponseWriter.Write(p[:d.remain])
+	d.dropped = true
+	_ = http.NewResponseController(d.ResponseWriter).Flush()
+	dropConnection(d.ResponseWriter)
+	return n, errDropped
+}
+
+func (d *dropWriter) Unwrap() http.ResponseWriter {
+	return d.ResponseWriter
+}
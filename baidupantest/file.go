@@ -0,0 +1,416 @@
+package baidupantest
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+)
+
+// fileEntry 列表、搜索与文件信息接口中的单个文件
+type fileEntry struct {
+	FsId           int64  `json:"fs_id"`
+	Path           string `json:"path"`
+	ServerFilename string `json:"server_filename"`
+	Size           int64  `json:"size"`
+	Isdir          int    `json:"isdir"`
+	Md5            string `json:"md5,omitempty"`
+	Category       int    `json:"category"`
+	ServerCtime    int64  `json:"server_ctime"`
+	ServerMtime    int64  `json:"server_mtime"`
+	LocalCtime     int64  `json:"local_ctime"`
+	LocalMtime     int64  `json:"local_mtime"`
+	Dlink          string `json:"dlink,omitempty"`
+
+	Thumbs map[string]string `json:"thumbs,omitempty"`
+}
+
+// categories 扩展名与文件分类的对应关系：1 视频、2 音频、3 图片、4 文档、5 应用、7 种子
+var categories = map[string]int{
+	".mp4": 1, ".mkv": 1, ".avi": 1, ".mov": 1,
+	".mp3": 2, ".flac": 2, ".wav": 2,
+	".jpg": 3, ".jpeg": 3, ".png": 3, ".gif": 3, ".webp": 3,
+	".txt": 4, ".pdf": 4, ".doc": 4, ".docx": 4, ".xls": 4, ".xlsx": 4, ".ppt": 4, ".pptx": 4,
+	".exe": 5, ".apk": 5, ".dmg": 5,
+	".torrent": 7,
+}
+
+// 分类列表接口对应的分类
+const (
+	categoryVideo    = 1
+	categoryImage    = 3
+	categoryDocument = 4
+)
+
+// category 按扩展名推断文件分类，目录与未知类型为 6（其他）
+func (n *node) category() int {
+	if n.isDir {
+		return 6
+	}
+	if c, ok := categories[strings.ToLower(path.Ext(n.path))]; ok {
+		return c
+	}
+	return 6
+}
+
+func (n *node) entry() fileEntry {
+	e := fileEntry{
+		FsId:           n.fsid,
+		Path:           n.path,
+		ServerFilename: path.Base(n.path),
+		Size:           int64(len(n.data)),
+		Md5:            n.md5,
+		Category:       n.category(),
+		ServerCtime:    n.ctime,
+		ServerMtime:    n.mtime,
+		LocalCtime:     n.ctime,
+		LocalMtime:     n.mtime,
+	}
+	if n.isDir {
+		e.Isdir = 1
+	}
+	return e
+}
+
+// page 按 start/limit 截取分页，返回本页与是否还有更多
+func page(nodes []*node, start, limit int) ([]*node, bool) {
+	if start < 0 {
+		start = 0
+	}
+	if start >= len(nodes) {
+		return nil, false
+	}
+	end := len(nodes)
+	if limit > 0 && start+limit < end {
+		end = start + limit
+	}
+	return nodes[start:end], end < len(nodes)
+}
+
+func entries(nodes []*node) []fileEntry {
+	out := make([]fileEntry, 0, len(nodes))
+	for _, n := range nodes {
+		out = append(out, n.entry())
+	}
+	return out
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// handleList 目录列表：目录在前，支持 order=name|time|size、desc、folder=1 只返回目录
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dir := r.FormValue("dir")
+	if dir == "" {
+		dir = "/"
+	}
+	if n, ok := s.tree.nodes[dir]; !ok || !n.isDir {
+		writeErrno(w, OpList, 0, errnoNotFound)
+		return
+	}
+
+	var nodes []*node
+	for _, n := range s.tree.children(dir) {
+		if r.FormValue("folder") == "1" && !n.isDir {
+			continue
+		}
+		nodes = append(nodes, n)
+	}
+	less := func(a, b *node) bool { return a.path < b.path }
+	switch r.FormValue("order") {
+	case "time":
+		less = func(a, b *node) bool { return a.mtime < b.mtime }
+	case "size":
+		less = func(a, b *node) bool { return len(a.data) < len(b.data) }
+	}
+	desc := r.FormValue("desc") == "1"
+	sort.SliceStable(nodes, func(i, j int) bool {
+		if nodes[i].isDir != nodes[j].isDir {
+			return nodes[i].isDir
+		}
+		if desc {
+			return less(nodes[j], nodes[i])
+		}
+		return less(nodes[i], nodes[j])
+	})
+
+	list, _ := page(nodes, formInt(r, "start", 0), formInt(r, "limit", 1000))
+	writeJSON(w, map[string]interface{}{
+		"errno":      0,
+		"guid":       0,
+		"list":       entries(list),
+		"request_id": s.requestID(),
+	})
+}
+
+// handleListAll 递归列表，按路径排序，通过 cursor/has_more 分页
+func (s *Server) handleListAll(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dir := r.FormValue("path")
+	if n, ok := s.tree.nodes[dir]; !ok || !n.isDir {
+		writeErrno(w, OpListAll, 0, errnoNotFound)
+		return
+	}
+	nodes := s.tree.children(dir)
+	if r.FormValue("recursion") == "1" {
+		nodes = s.tree.descendants(dir)
+	}
+	start := formInt(r, "start", 0)
+	list, more := page(nodes, start, formInt(r, "limit", 1000))
+	writeJSON(w, map[string]interface{}{
+		"errno":      0,
+		"cursor":     start + len(list),
+		"has_more":   boolInt(more),
+		"list":       entries(list),
+		"request_id": s.requestID(),
+	})
+}
+
+// handleSearch 按文件名子串（不区分大小写）搜索，通过 page/num 分页
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := strings.ToLower(r.FormValue("key"))
+	if key == "" {
+		writeErrno(w, OpSearch, 0, errnoParam)
+		return
+	}
+	dir := r.FormValue("dir")
+	if dir == "" {
+		dir = "/"
+	}
+	candidates := s.tree.children(dir)
+	if r.FormValue("recursion") == "1" {
+		candidates = s.tree.descendants(dir)
+	}
+	var nodes []*node
+	for _, n := range candidates {
+		if strings.Contains(strings.ToLower(path.Base(n.path)), key) {
+			nodes = append(nodes, n)
+		}
+	}
+	num := formInt(r, "num", 500)
+	list, more := page(nodes, (formInt(r, "page", 1)-1)*num, num)
+	writeJSON(w, map[string]interface{}{
+		"errno":       0,
+		"has_more":    boolInt(more),
+		"list":        entries(list),
+		"contentlist": []interface{}{},
+		"request_id":  s.requestID(),
+	})
+}
+
+// handleCategoryList 按分类列出 parent_path 下的文件，结果放在 info 字段，通过 page/num 分页
+func (s *Server) handleCategoryList(w http.ResponseWriter, r *http.Request, op Op, category int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dir := r.FormValue("parent_path")
+	if dir == "" {
+		dir = "/"
+	}
+	if n, ok := s.tree.nodes[dir]; !ok || !n.isDir {
+		writeErrno(w, op, 0, errnoNotFound)
+		return
+	}
+	candidates := s.tree.children(dir)
+	if r.FormValue("recursion") == "1" {
+		candidates = s.tree.descendants(dir)
+	}
+	var nodes []*node
+	for _, n := range candidates {
+		if !n.isDir && n.category() == category {
+			nodes = append(nodes, n)
+		}
+	}
+	less := func(a, b *node) bool { return a.path < b.path }
+	switch r.FormValue("order") {
+	case "time":
+		less = func(a, b *node) bool { return a.mtime < b.mtime }
+	case "size":
+		less = func(a, b *node) bool { return len(a.data) < len(b.data) }
+	}
+	desc := r.FormValue("desc") == "1"
+	sort.SliceStable(nodes, func(i, j int) bool {
+		if desc {
+			return less(nodes[j], nodes[i])
+		}
+		return less(nodes[i], nodes[j])
+	})
+
+	num := formInt(r, "num", 1000)
+	list, _ := page(nodes, (formInt(r, "page", 1)-1)*num, num)
+	writeJSON(w, map[string]interface{}{
+		"errno":      0,
+		"info":       entries(list),
+		"request_id": s.requestID(),
+	})
+}
+
+// maxMetasIDs filemetas 单次请求的 fs_id 数量上限，超出时返回参数错误
+const maxMetasIDs = 100
+
+// handleFileMetas 按 fs_id 查询文件信息，dlink=1 时返回指向模拟服务的下载地址，
+// thumb=1 时为图片与视频返回缩略图地址，不存在的 fs_id 被忽略
+func (s *Server) handleFileMetas(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var fsids []int64
+	if err := json.Unmarshal([]byte(r.FormValue("fsids")), &fsids); err != nil || len(fsids) == 0 || len(fsids) > maxMetasIDs {
+		writeErrno(w, OpFileMetas, 0, errnoParam)
+		return
+	}
+	list := []fileEntry{}
+	for _, id := range fsids {
+		n, ok := s.tree.byID[id]
+		if !ok {
+			continue
+		}
+		e := n.entry()
+		if r.FormValue("dlink") == "1" && !n.isDir {
+			e.Dlink = s.dlink(n.fsid)
+		}
+		if r.FormValue("thumb") == "1" {
+			e.Thumbs = s.thumbs(n)
+		}
+		list = append(list, e)
+	}
+	writeJSON(w, map[string]interface{}{
+		"errno":      0,
+		"list":       list,
+		"names":      map[string]string{},
+		"request_id": s.requestID(),
+	})
+}
+
+// fileManagerItem 文件管理接口 filelist 中的单项，delete 时为路径字符串
+type fileManagerItem struct {
+	Path    string `json:"path"`
+	Dest    string `json:"dest"`
+	Newname string `json:"newname"`
+	Ondup   string `json:"ondup"`
+}
+
+// fileManagerInfo 文件管理接口单项的执行结果
+type fileManagerInfo struct {
+	Errno int    `json:"errno"`
+	Path  string `json:"path"`
+}
+
+// handleFileManager 复制、移动、重命名与删除，任一项失败时顶层 errno 为 12
+func (s *Server) handleFileManager(w http.ResponseWriter, r *http.Request, op Op) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var items []fileManagerItem
+	filelist := []byte(r.FormValue("filelist"))
+	if op == OpDelete {
+		var paths []string
+		if err := json.Unmarshal(filelist, &paths); err != nil {
+			writeErrno(w, op, 0, errnoParam)
+			return
+		}
+		for _, p := range paths {
+			items = append(items, fileManagerItem{Path: p})
+		}
+	} else if err := json.Unmarshal(filelist, &items); err != nil {
+		writeErrno(w, op, 0, errnoParam)
+		return
+	}
+	if len(items) == 0 {
+		writeErrno(w, op, 0, errnoParam)
+		return
+	}
+
+	errno := 0
+	info := make([]fileManagerInfo, 0, len(items))
+	for _, item := range items {
+		if item.Ondup == "" {
+			item.Ondup = r.FormValue("ondup")
+		}
+		e := s.applyFileManagerItem(op, item)
+		if e != 0 {
+			errno = errnoPartial
+		}
+		info = append(info, fileManagerInfo{Errno: e, Path: item.Path})
+	}
+	writeJSON(w, map[string]interface{}{
+		"errno":      errno,
+		"info":       info,
+		"request_id": s.requestID(),
+	})
+}
+
+// applyFileManagerItem 执行单项文件管理操作，调用方需持有锁
+func (s *Server) applyFileManagerItem(op Op, item fileManagerItem) int {
+	src := item.Path
+	if !validPath(src) {
+		return errnoInvalid
+	}
+	if _, ok := s.tree.nodes[src]; !ok {
+		return errnoNotFound
+	}
+	if op == OpDelete {
+		s.tree.remove(src)
+		return 0
+	}
+
+	name := item.Newname
+	if name == "" {
+		name = path.Base(src)
+	}
+	if strings.Contains(name, "/") {
+		return errnoInvalid
+	}
+	destDir := path.Dir(src)
+	if op != OpRename {
+		destDir = path.Clean(item.Dest)
+		if !strings.HasPrefix(destDir, "/") {
+			return errnoInvalid
+		}
+	}
+	dst := path.Join(destDir, name)
+	if dst == src {
+		return 0
+	}
+	if strings.HasPrefix(dst, src+"/") {
+		return errnoParam
+	}
+
+	if _, exists := s.tree.nodes[dst]; exists {
+		switch item.Ondup {
+		case "newcopy":
+			dst = s.tree.freeName(dst)
+		case "overwrite":
+			if strings.HasPrefix(src, dst+"/") {
+				return errnoParam
+			}
+			s.tree.remove(dst)
+		case "skip":
+			return 0
+		default:
+			return errnoExists
+		}
+	}
+	if _, errno := s.tree.mkdirAll(destDir); errno != 0 {
+		return errno
+	}
+	if op == OpCopy {
+		s.tree.copyTree(src, dst)
+	} else {
+		s.tree.moveTree(src, dst)
+	}
+	return 0
+}
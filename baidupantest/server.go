@@ -0,0 +1,337 @@
+// Package baidupantest 提供基于 httptest 的百度网盘开放平台模拟服务，
+// 在内存目录树上实现列表、搜索、文件信息、分片上传、文件管理、容量与用户信息接口，
+// 并支持按接口注入 errno、延迟与断开连接，便于在没有真实账号时离线测试。
+package baidupantest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	openapi "github.com/S-zhi/baidupansdk/openxpanapi"
+)
+
+// DefaultAccessToken 模拟服务默认接受的 access token
+const DefaultAccessToken = "baidupantest-access-token"
+
+// defaultQuota 默认网盘总容量 2TB
+const defaultQuota = 2 << 40
+
+// dlinkPath 模拟服务下发的 dlink 路径
+const dlinkPath = "/file/dlink"
+
+// dlinkUserAgent 下载 dlink 时必须携带的 User-Agent
+const dlinkUserAgent = "pan.baidu.com"
+
+// thumbnailPath 模拟服务下发的缩略图路径
+const thumbnailPath = "/file/thumbnail"
+
+// User 用户信息接口返回的账号信息
+type User struct {
+	Uk          int64
+	BaiduName   string
+	NetdiskName string
+	AvatarURL   string
+	VipType     int32 // 0 普通用户、1 会员、2 超级会员
+}
+
+// Server 模拟的百度网盘服务
+type Server struct {
+	// URL 模拟服务地址，形如 http://127.0.0.1:port
+	URL string
+
+	srv *httptest.Server
+
+	mu          sync.Mutex
+	accessToken string
+	quota       int64
+	user        User
+	tree        *tree
+	sessions    map[string]*uploadSession
+	faults      map[Op][]*Fault
+	requests    map[Op]int
+	nextID      int64
+
+	dlinkTTL      time.Duration
+	dlinkRedirect bool
+}
+
+// NewServer 启动模拟服务，使用完毕后需调用 Close
+func NewServer() *Server {
+	s := &Server{
+		accessToken: DefaultAccessToken,
+		quota:       defaultQuota,
+		user:        User{Uk: 1, BaiduName: "baidupantest", NetdiskName: "baidupantest"},
+		tree:        newTree(),
+		sessions:    map[string]*uploadSession{},
+		faults:      map[Op][]*Fault{},
+		requests:    map[Op]int{},
+	}
+	s.srv = httptest.NewServer(s)
+	s.URL = s.srv.URL
+	return s
+}
+
+// Close 关闭模拟服务
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// Client 返回访问模拟服务的 http.Client，请求无论原本发往哪个域名都会被转发到模拟服务，
+// 因此可以直接替换 SDK 中写死的 pan.baidu.com、d.pcs.baidu.com 等地址
+func (s *Server) Client() *http.Client {
+	target, _ := url.Parse(s.URL)
+	return &http.Client{Transport: &rewriteTransport{target: target, base: s.srv.Client().Transport}}
+}
+
+// Configuration 返回指向模拟服务的 openxpanapi 配置
+func (s *Server) Configuration() *openapi.Configuration {
+	cfg := openapi.NewConfiguration()
+	cfg.HTTPClient = s.Client()
+	return cfg
+}
+
+// SetAccessToken 设置模拟服务接受的 access token，为空时不校验
+func (s *Server) SetAccessToken(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.accessToken = token
+}
+
+// SetQuota 设置网盘总容量（字节）
+func (s *Server) SetQuota(total int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.quota = total
+}
+
+// SetUser 设置用户信息接口返回的账号信息
+func (s *Server) SetUser(u User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.user = u
+}
+
+// rewriteTransport 将请求改写到模拟服务地址
+type rewriteTransport struct {
+	target *url.URL
+	base   http.RoundTripper
+}
+
+func (t *rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := req.Clone(req.Context())
+	r.URL.Scheme = t.target.Scheme
+	r.URL.Host = t.target.Host
+	r.Host = ""
+	return t.base.RoundTrip(r)
+}
+
+// ServeHTTP 按路径与 method 参数分发请求
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	op := opOf(r)
+	if op == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	s.mu.Lock()
+	s.requests[op]++
+	fault := s.takeFault(op)
+	token := s.accessToken
+	s.mu.Unlock()
+
+	if fault != nil {
+		if done := fault.apply(w, r, op); done {
+			return
+		}
+		if fault.DropAfter > 0 && op == OpDownload {
+			w = &dropWriter{ResponseWriter: w, remain: fault.DropAfter}
+		}
+	}
+	// 缩略图地址自带签名，不需要 access_token
+	if token != "" && op != OpThumbnail && r.URL.Query().Get("access_token") != token {
+		writeErrno(w, op, 0, errnoAuth)
+		return
+	}
+
+	switch op {
+	case OpList:
+		s.handleList(w, r)
+	case OpListAll:
+		s.handleListAll(w, r)
+	case OpSearch:
+		s.handleSearch(w, r)
+	case OpDocList:
+		s.handleCategoryList(w, r, OpDocList, categoryDocument)
+	case OpImageList:
+		s.handleCategoryList(w, r, OpImageList, categoryImage)
+	case OpFileMetas:
+		s.handleFileMetas(w, r)
+	case OpPrecreate:
+		s.handlePrecreate(w, r)
+	case OpUpload:
+		s.handleUpload(w, r)
+	case OpCreate:
+		s.handleCreate(w, r)
+	case OpCopy, OpMove, OpRename, OpDelete:
+		s.handleFileManager(w, r, op)
+	case OpQuota:
+		s.handleQuota(w, r)
+	case OpUserInfo:
+		s.handleUserInfo(w, r)
+	case OpDownload:
+		s.handleDownload(w, r)
+	case OpThumbnail:
+		s.handleThumbnail(w, r)
+	}
+}
+
+// opOf 根据请求路径与 method/opera 参数识别接口
+func opOf(r *http.Request) Op {
+	q := r.URL.Query()
+	switch r.URL.Path {
+	case "/rest/2.0/xpan/file":
+		switch q.Get("method") {
+		case "list":
+			return OpList
+		case "search":
+			return OpSearch
+		case "doclist":
+			return OpDocList
+		case "imagelist":
+			return OpImageList
+		case "precreate":
+			return OpPrecreate
+		case "create":
+			return OpCreate
+		case "filemanager":
+			switch q.Get("opera") {
+			case "copy":
+				return OpCopy
+			case "move":
+				return OpMove
+			case "rename":
+				return OpRename
+			case "delete":
+				return OpDelete
+			}
+		}
+	case "/rest/2.0/xpan/multimedia":
+		switch q.Get("method") {
+		case "listall":
+			return OpListAll
+		case "filemetas":
+			return OpFileMetas
+		}
+	case "/rest/2.0/pcs/superfile2":
+		return OpUpload
+	case "/rest/2.0/xpan/nas":
+		if q.Get("method") == "uinfo" {
+			return OpUserInfo
+		}
+	case "/api/quota":
+		return OpQuota
+	case dlinkPath:
+		return OpDownload
+	case thumbnailPath:
+		return OpThumbnail
+	}
+	return ""
+}
+
+// requestID 生成响应中的 request_id，调用方需持有锁
+func (s *Server) requestID() int64 {
+	s.nextID++
+	return s.nextID
+}
+
+// writeJSON 以 200 状态码输出 JSON 响应
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeErrno 按接口的错误响应格式输出 errno：
+// 分片上传接口使用 error_code 且状态码为 400，下载链接返回 403，其余接口状态码为 200
+func writeErrno(w http.ResponseWriter, op Op, status int, errno int) {
+	var body interface{} = map[string]interface{}{"errno": errno, "request_id": 0}
+	switch op {
+	case OpUpload:
+		if status == 0 {
+			status = http.StatusBadRequest
+		}
+		body = map[string]interface{}{"error_code": errno, "error_msg": "errno " + strconv.Itoa(errno)}
+	case OpDownload:
+		if status == 0 {
+			status = http.StatusForbidden
+		}
+	}
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// formInt 读取整数参数，缺省或格式错误时返回 def
+func formInt(r *http.Request, key string, def int) int {
+	v, err := strconv.Atoi(r.FormValue(key))
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+// SetDlinkTTL 设置 dlink 的有效期，过期后下载返回 403；为 0 时 dlink 永不过期
+func (s *Server) SetDlinkTTL(ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dlinkTTL = ttl
+}
+
+// SetDlinkRedirect 设置 dlink 是否先以 302 跳转到实际的下载地址，与线上 d.pcs.baidu.com 跳转到 CDN 的行为一致
+func (s *Server) SetDlinkRedirect(enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dlinkRedirect = enabled
+}
+
+// dlink 文件的下载地址，设置了有效期时带有毫秒精度的过期时间，调用方需持有锁
+func (s *Server) dlink(fsid int64) string {
+	link := fmt.Sprintf("%s%s?fsid=%d", s.URL, dlinkPath, fsid)
+	if s.dlinkTTL > 0 {
+		link += fmt.Sprintf("&expires=%d", time.Now().Add(s.dlinkTTL).UnixMilli())
+	}
+	return link
+}
+
+// thumbSizes 缩略图字段与尺寸
+var thumbSizes = map[string]string{
+	"icon": "c60_u60",
+	"url1": "c140_u90",
+	"url2": "c360_u270",
+	"url3": "c850_u580",
+}
+
+// thumbs 返回图片与视频文件的缩略图地址，其它文件返回 nil
+func (s *Server) thumbs(n *node) map[string]string {
+	if category := n.category(); n.isDir || (category != categoryImage && category != categoryVideo) {
+		return nil
+	}
+	thumbs := make(map[string]string, len(thumbSizes))
+	for key, size := range thumbSizes {
+		thumbs[key] = fmt.Sprintf("%s%s?fsid=%d&size=%s", s.URL, thumbnailPath, n.fsid, size)
+	}
+	return thumbs
+}
+
+// ThumbnailData 模拟服务为 fs_id 与尺寸生成的缩略图内容，供测试比对
+func ThumbnailData(fsid int64, size string) []byte {
+	return []byte(fmt.Sprintf("thumbnail %d %s", fsid, size))
+}
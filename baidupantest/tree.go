@@ -0,0 +1,219 @@
+package baidupantest
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// node 内存目录树中的文件或目录
+type node struct {
+	fsid  int64
+	path  string
+	isDir bool
+	data  []byte
+	md5   string
+	ctime int64
+	mtime int64
+}
+
+// tree 以路径索引的内存目录树，根目录 / 始终存在
+type tree struct {
+	nodes    map[string]*node
+	byID     map[int64]*node
+	nextFsID int64
+}
+
+func newTree() *tree {
+	t := &tree{nodes: map[string]*node{}, byID: map[int64]*node{}}
+	t.put(&node{path: "/", isDir: true})
+	return t
+}
+
+// put 加入节点并分配 fs_id
+func (t *tree) put(n *node) *node {
+	t.nextFsID++
+	n.fsid = t.nextFsID
+	if n.ctime == 0 {
+		n.ctime = time.Now().Unix()
+		n.mtime = n.ctime
+	}
+	t.nodes[n.path] = n
+	t.byID[n.fsid] = n
+	return n
+}
+
+// mkdirAll 创建目录及其全部上级目录，路径上存在同名文件时返回 errnoExists
+func (t *tree) mkdirAll(p string) (*node, int) {
+	if n, ok := t.nodes[p]; ok {
+		if !n.isDir {
+			return nil, errnoExists
+		}
+		return n, 0
+	}
+	if _, errno := t.mkdirAll(path.Dir(p)); errno != 0 {
+		return nil, errno
+	}
+	return t.put(&node{path: p, isDir: true}), 0
+}
+
+// writeFile 写入文件，已存在的同名文件被替换，上级目录不存在时自动创建
+func (t *tree) writeFile(p string, data []byte) (*node, int) {
+	if _, errno := t.mkdirAll(path.Dir(p)); errno != 0 {
+		return nil, errno
+	}
+	if old, ok := t.nodes[p]; ok {
+		if old.isDir {
+			return nil, errnoExists
+		}
+		t.remove(p)
+	}
+	sum := md5.Sum(data)
+	return t.put(&node{path: p, data: data, md5: hex.EncodeToString(sum[:])}), 0
+}
+
+// children 返回目录的直接子节点，按路径排序
+func (t *tree) children(dir string) []*node {
+	var out []*node
+	for p, n := range t.nodes {
+		if p != "/" && path.Dir(p) == dir {
+			out = append(out, n)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].path < out[j].path })
+	return out
+}
+
+// descendants 返回目录下的全部节点（不含目录自身），按路径排序
+func (t *tree) descendants(dir string) []*node {
+	prefix := strings.TrimSuffix(dir, "/") + "/"
+	var out []*node
+	for p, n := range t.nodes {
+		if p != dir && strings.HasPrefix(p, prefix) {
+			out = append(out, n)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].path < out[j].path })
+	return out
+}
+
+// remove 删除节点及其子树
+func (t *tree) remove(p string) {
+	n, ok := t.nodes[p]
+	if !ok {
+		return
+	}
+	for _, child := range t.descendants(p) {
+		delete(t.nodes, child.path)
+		delete(t.byID, child.fsid)
+	}
+	delete(t.nodes, p)
+	delete(t.byID, n.fsid)
+}
+
+// copyTree 将 src 子树复制到 dst，复制出的节点使用新的 fs_id
+func (t *tree) copyTree(src, dst string) *node {
+	n := t.nodes[src]
+	subtree := t.descendants(src)
+	root := t.put(&node{path: dst, isDir: n.isDir, data: n.data, md5: n.md5})
+	for _, child := range subtree {
+		t.put(&node{path: dst + strings.TrimPrefix(child.path, src), isDir: child.isDir, data: child.data, md5: child.md5})
+	}
+	return root
+}
+
+// moveTree 将 src 子树移动到 dst，fs_id 保持不变
+func (t *tree) moveTree(src, dst string) *node {
+	subtree := append([]*node{t.nodes[src]}, t.descendants(src)...)
+	now := time.Now().Unix()
+	for _, n := range subtree {
+		delete(t.nodes, n.path)
+		n.path = dst + strings.TrimPrefix(n.path, src)
+		n.mtime = now
+		t.nodes[n.path] = n
+	}
+	return t.nodes[dst]
+}
+
+// used 已用空间
+func (t *tree) used() int64 {
+	var total int64
+	for _, n := range t.nodes {
+		total += int64(len(n.data))
+	}
+	return total
+}
+
+// freeName 为冲突的路径生成不重复的新路径，格式与网盘一致：name_YYYYMMDD_HHMMSS.ext
+func (t *tree) freeName(p string) string {
+	ext := path.Ext(p)
+	base := strings.TrimSuffix(p, ext) + "_" + time.Now().Format("20060102_150405")
+	candidate := base + ext
+	for i := 1; ; i++ {
+		if _, ok := t.nodes[candidate]; !ok {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s(%d)%s", base, i, ext)
+	}
+}
+
+// validPath 校验网盘路径：必须为规范化的绝对路径且不是根目录
+func validPath(p string) bool {
+	return strings.HasPrefix(p, "/") && p != "/" && path.Clean(p) == p
+}
+
+// AddFile 在网盘中写入文件，上级目录不存在时自动创建，返回文件的 fs_id
+func (s *Server) AddFile(p string, data []byte) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, errno := s.tree.writeFile(path.Clean(p), data)
+	if errno != 0 {
+		panic(fmt.Sprintf("baidupantest: AddFile %s: errno %d", p, errno))
+	}
+	return n.fsid
+}
+
+// AddDir 在网盘中创建目录及其上级目录，返回目录的 fs_id
+func (s *Server) AddDir(p string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, errno := s.tree.mkdirAll(path.Clean(p))
+	if errno != 0 {
+		panic(fmt.Sprintf("baidupantest: AddDir %s: errno %d", p, errno))
+	}
+	return n.fsid
+}
+
+// ReadFile 读取网盘中的文件内容，文件不存在或为目录时 ok 为 false
+func (s *Server) ReadFile(p string) (data []byte, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, ok := s.tree.nodes[path.Clean(p)]
+	if !ok || n.isDir {
+		return nil, false
+	}
+	return append([]byte(nil), n.data...), true
+}
+
+// Exists 判断网盘中是否存在该路径
+func (s *Server) Exists(p string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.tree.nodes[path.Clean(p)]
+	return ok
+}
+
+// Paths 返回网盘中全部文件与目录的路径（不含根目录），按路径排序
+func (s *Server) Paths() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []string
+	for _, n := range s.tree.descendants("/") {
+		out = append(out, n.path)
+	}
+	return out
+}
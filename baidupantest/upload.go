@@ -0,0 +1,199 @@
+package baidupantest
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+)
+
+// uploadSession precreate 创建的上传会话
+type uploadSession struct {
+	path   string
+	size   int64
+	blocks int
+	parts  map[int][]byte
+}
+
+// maxPartMemory 解析分片上传请求时保存在内存中的最大字节数
+const maxPartMemory = 32 << 20
+
+// handlePrecreate 预上传：创建上传会话，要求上传 block_list 中的全部分片
+func (s *Server) handlePrecreate(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := r.FormValue("path")
+	if !validPath(p) {
+		writeErrno(w, OpPrecreate, 0, errnoInvalid)
+		return
+	}
+	size, err := strconv.ParseInt(r.FormValue("size"), 10, 64)
+	var blocks []string
+	if err != nil || size < 0 || r.FormValue("isdir") == "1" || json.Unmarshal([]byte(r.FormValue("block_list")), &blocks) != nil {
+		writeErrno(w, OpPrecreate, 0, errnoParam)
+		return
+	}
+	if s.tree.used()+size > s.quota {
+		writeErrno(w, OpPrecreate, 0, errnoQuota)
+		return
+	}
+
+	id := fmt.Sprintf("N1-%d", s.requestID())
+	s.sessions[id] = &uploadSession{path: p, size: size, blocks: len(blocks), parts: map[int][]byte{}}
+	required := make([]int, len(blocks))
+	for i := range required {
+		required[i] = i
+	}
+	writeJSON(w, map[string]interface{}{
+		"errno":       0,
+		"path":        p,
+		"uploadid":    id,
+		"return_type": 1,
+		"block_list":  required,
+		"request_id":  s.requestID(),
+	})
+}
+
+// handleUpload 分片上传：multipart 表单字段 file 为分片内容
+func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
+	partSeq, err := strconv.Atoi(r.URL.Query().Get("partseq"))
+	if err != nil {
+		writeErrno(w, OpUpload, 0, errnoParam)
+		return
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		writeErrno(w, OpUpload, 0, errnoParam)
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		writeErrno(w, OpUpload, 0, errnoParam)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := r.URL.Query().Get("uploadid")
+	session, ok := s.sessions[id]
+	if !ok || partSeq < 0 || partSeq >= session.blocks {
+		writeErrno(w, OpUpload, 0, errnoParam)
+		return
+	}
+	session.parts[partSeq] = data
+	sum := md5.Sum(data)
+	writeJSON(w, map[string]interface{}{
+		"md5":        hex.EncodeToString(sum[:]),
+		"partseq":    strconv.Itoa(partSeq),
+		"uploadid":   id,
+		"request_id": s.requestID(),
+	})
+}
+
+// handleCreate 创建文件或目录：文件按 block_list 校验分片 MD5 与总大小后合并，
+// 同名冲突按 rtype 处理：0 返回错误、1 重命名、2 内容不同时重命名、3 覆盖，未指定时按 1 处理
+func (s *Server) handleCreate(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := r.FormValue("path")
+	if !validPath(p) {
+		writeErrno(w, OpCreate, 0, errnoInvalid)
+		return
+	}
+	rtype := r.FormValue("rtype")
+	if rtype == "" {
+		rtype = "1"
+	}
+
+	if r.FormValue("isdir") == "1" {
+		if _, ok := s.tree.nodes[p]; ok {
+			if rtype != "1" && rtype != "2" {
+				writeErrno(w, OpCreate, 0, errnoExists)
+				return
+			}
+			p = s.tree.freeName(p)
+		}
+		n, errno := s.tree.mkdirAll(p)
+		if errno != 0 {
+			writeErrno(w, OpCreate, 0, errno)
+			return
+		}
+		s.writeCreated(w, n)
+		return
+	}
+
+	session, ok := s.sessions[r.FormValue("uploadid")]
+	var blocks []string
+	if !ok || json.Unmarshal([]byte(r.FormValue("block_list")), &blocks) != nil {
+		writeErrno(w, OpCreate, 0, errnoParam)
+		return
+	}
+	var buf bytes.Buffer
+	for i, want := range blocks {
+		part, ok := session.parts[i]
+		sum := md5.Sum(part)
+		if !ok || hex.EncodeToString(sum[:]) != want {
+			writeErrno(w, OpCreate, 0, errnoBlockMiss)
+			return
+		}
+		buf.Write(part)
+	}
+	size, err := strconv.ParseInt(r.FormValue("size"), 10, 64)
+	if err != nil || size != int64(buf.Len()) {
+		writeErrno(w, OpCreate, 0, errnoParam)
+		return
+	}
+	if s.tree.used()+size > s.quota {
+		writeErrno(w, OpCreate, 0, errnoQuota)
+		return
+	}
+
+	sum := md5.Sum(buf.Bytes())
+	if existing, ok := s.tree.nodes[p]; ok {
+		switch {
+		case rtype == "0" || (rtype == "3" && existing.isDir):
+			writeErrno(w, OpCreate, 0, errnoExists)
+			return
+		case rtype == "2" && existing.md5 == hex.EncodeToString(sum[:]):
+			delete(s.sessions, r.FormValue("uploadid"))
+			s.writeCreated(w, existing)
+			return
+		case rtype == "1" || rtype == "2":
+			p = s.tree.freeName(p)
+		}
+	}
+	n, errno := s.tree.writeFile(p, buf.Bytes())
+	if errno != 0 {
+		writeErrno(w, OpCreate, 0, errno)
+		return
+	}
+	delete(s.sessions, r.FormValue("uploadid"))
+	s.writeCreated(w, n)
+}
+
+// writeCreated 输出 create 接口的成功响应
+func (s *Server) writeCreated(w http.ResponseWriter, n *node) {
+	writeJSON(w, map[string]interface{}{
+		"errno":           0,
+		"fs_id":           n.fsid,
+		"path":            n.path,
+		"server_filename": path.Base(n.path),
+		"name":            n.path,
+		"size":            len(n.data),
+		"isdir":           boolInt(n.isDir),
+		"md5":             n.md5,
+		"category":        n.category(),
+		"ctime":           n.ctime,
+		"mtime":           n.mtime,
+		"from_type":       1,
+		"request_id":      s.requestID(),
+	})
+}
@@ -0,0 +1,96 @@
+package baidupantest
+
+import (
+	"bytes"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+)
+
+// handleQuota 网盘容量，已用空间为全部文件大小之和
+func (s *Server) handleQuota(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	used := s.tree.used()
+	writeJSON(w, map[string]interface{}{
+		"errno":      0,
+		"total":      s.quota,
+		"used":       used,
+		"free":       s.quota - used,
+		"expire":     false,
+		"request_id": s.requestID(),
+	})
+}
+
+// handleUserInfo 用户信息
+func (s *Server) handleUserInfo(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	writeJSON(w, map[string]interface{}{
+		"errno":        0,
+		"errmsg":       "succ",
+		"uk":           s.user.Uk,
+		"baidu_name":   s.user.BaiduName,
+		"netdisk_name": s.user.NetdiskName,
+		"avatar_url":   s.user.AvatarURL,
+		"vip_type":     s.user.VipType,
+		"request_id":   strconv.FormatInt(s.requestID(), 10),
+	})
+}
+
+// handleThumbnail 返回 ThumbnailData 生成的缩略图内容
+func (s *Server) handleThumbnail(w http.ResponseWriter, r *http.Request) {
+	fsid, err := strconv.ParseInt(r.URL.Query().Get("fsid"), 10, 64)
+	s.mu.Lock()
+	n, ok := s.tree.byID[fsid]
+	ok = ok && s.thumbs(n) != nil
+	s.mu.Unlock()
+	if err != nil || !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Write(ThumbnailData(fsid, r.URL.Query().Get("size")))
+}
+
+// handleDownload 通过 dlink 下载文件内容，支持 Range 请求
+// 与线上一致，要求 User-Agent 为 pan.baidu.com，过期的 dlink 返回 403
+func (s *Server) handleDownload(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if r.UserAgent() != dlinkUserAgent {
+		writeErrno(w, OpDownload, http.StatusForbidden, errnoAuth)
+		return
+	}
+	if expires, err := strconv.ParseInt(q.Get("expires"), 10, 64); err == nil && time.Now().UnixMilli() > expires {
+		writeErrno(w, OpDownload, http.StatusForbidden, errnoExpired)
+		return
+	}
+	s.mu.Lock()
+	redirect := s.dlinkRedirect
+	s.mu.Unlock()
+	if redirect && q.Get("redirected") == "" {
+		q.Set("redirected", "1")
+		http.Redirect(w, r, dlinkPath+"?"+q.Encode(), http.StatusFound)
+		return
+	}
+
+	fsid, err := strconv.ParseInt(q.Get("fsid"), 10, 64)
+	s.mu.Lock()
+	n, ok := s.tree.byID[fsid]
+	ok = ok && !n.isDir
+	var name string
+	var data []byte
+	var mtime int64
+	if ok {
+		name, data, mtime = path.Base(n.path), n.data, n.mtime
+	}
+	s.mu.Unlock()
+	if err != nil || !ok {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeContent(w, r, name, time.Unix(mtime, 0), bytes.NewReader(data))
+}
@@ -0,0 +1,405 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/S-zhi/baidupansdk/baidupanplus"
+)
+
+func init() {
+	register(&command{name: "ls", usage: "[-r] [远程目录]", summary: "列出网盘目录内容", run: runLs})
+	register(&command{name: "stat", usage: "<远程路径>", summary: "查看文件或目录信息", run: runStat})
+	register(&command{name: "upload", usage: "[-on-conflict 策略] [-skip-same] <本地文件> <远程路径>", summary: "上传本地文件，远程路径以 / 结尾时视为目录", run: runUpload})
+	register(&command{name: "download", usage: "[-on-conflict 策略] [-skip-same] [-fsync] <远程文件> [本地路径]", summary: "下载网盘文件，本地路径为目录时保存到该目录下", run: runDownload})
+	register(&command{name: "cp", usage: "[-ondup fail|newcopy|overwrite|skip] <源路径> <目标路径>", summary: "复制网盘文件或目录", run: runCp})
+	register(&command{name: "mv", usage: "[-ondup fail|newcopy|overwrite|skip] <源路径> <目标路径>", summary: "移动或重命名网盘文件或目录", run: runMv})
+	register(&command{name: "rm", usage: "<远程路径>...", summary: "删除网盘文件或目录", run: runRm})
+	register(&command{name: "mkdir", usage: "[-p] <远程目录>...", summary: "创建网盘目录", run: runMkdir})
+	register(&command{name: "search", usage: "[-dir 目录] [-r] <关键字>", summary: "按文件名搜索", run: runSearch})
+	register(&command{name: "quota", usage: "", summary: "查看网盘容量", run: runQuota})
+	register(&command{name: "whoami", usage: "", summary: "查看当前登录用户", run: runWhoami})
+}
+
+func runLs(e *env, args []string) error {
+	fs := newFlagSet(e, "ls")
+	recursive := fs.Bool("r", false, "递归列出子目录")
+	rest, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 1 {
+		return usagef("最多指定一个目录")
+	}
+	dir := "/"
+	if len(rest) == 1 {
+		dir = remoteArg(rest[0])
+	}
+	c, err := e.getClient()
+	if err != nil {
+		return err
+	}
+
+	var files []baidupanplus.FileInfo
+	if *recursive {
+		err = c.Walk(e.ctx, dir, func(info baidupanplus.FileInfo) error {
+			files = append(files, info)
+			return nil
+		})
+	} else {
+		files, err = c.List(e.ctx, dir)
+	}
+	if err != nil {
+		return err
+	}
+	if files == nil {
+		files = []baidupanplus.FileInfo{}
+	}
+	return e.output(files, func(w io.Writer) {
+		printFileTable(w, files, *recursive)
+	})
+}
+
+func runStat(e *env, args []string) error {
+	rest, err := parseFlags(newFlagSet(e, "stat"), args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 1 {
+		return usagef("需要指定一个远程路径")
+	}
+	c, err := e.getClient()
+	if err != nil {
+		return err
+	}
+	info, err := c.Stat(e.ctx, remoteArg(rest[0]))
+	if err != nil {
+		return err
+	}
+	return e.output(info, func(w io.Writer) {
+		kind := "file"
+		if info.IsDir() {
+			kind = "directory"
+		}
+		fmt.Fprintf(w, "path:   %s\n", info.Path)
+		fmt.Fprintf(w, "type:   %s\n", kind)
+		fmt.Fprintf(w, "fs_id:  %d\n", info.FsId)
+		if !info.IsDir() {
+			fmt.Fprintf(w, "size:   %d (%s)\n", info.Size, humanSize(info.Size))
+			fmt.Fprintf(w, "md5:    %s\n", info.Md5)
+		}
+		fmt.Fprintf(w, "mtime:  %s\n", info.ModTime().Format(time.RFC3339))
+	})
+}
+
+// transferResult upload / download 的 JSON 输出
+type transferResult struct {
+	Local   string `json:"local"`
+	Remote  string `json:"remote"`
+	Size    int64  `json:"size"`
+	Skipped bool   `json:"skipped,omitempty"`
+}
+
+// conflictPolicies upload -on-conflict 的取值
+var conflictPolicies = map[string]baidupanplus.ConflictPolicy{
+	"fail":      baidupanplus.ConflictFail,
+	"rename":    baidupanplus.ConflictRename,
+	"overwrite": baidupanplus.ConflictOverwrite,
+}
+
+func runUpload(e *env, args []string) error {
+	fs := newFlagSet(e, "upload")
+	onConflict := fs.String("on-conflict", "rename", "远程文件已存在时的处理方式: fail, rename, overwrite")
+	skipSame := fs.Bool("skip-same", false, "远程已存在内容相同的文件时跳过，大小相同时会读取远程文件比较")
+	rest, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	policy, ok := conflictPolicies[*onConflict]
+	if !ok {
+		return usagef("无效的 -on-conflict: %s", *onConflict)
+	}
+	if len(rest) != 2 {
+		return usagef("需要指定本地文件与远程路径")
+	}
+	local, remote := rest[0], remoteArg(rest[1])
+	st, err := os.Stat(local)
+	if err != nil {
+		return err
+	}
+	if st.IsDir() {
+		return usagef("%s 是目录，请使用 sync 上传目录", local)
+	}
+	if strings.HasSuffix(remote, "/") {
+		remote = path.Join(remote, filepath.Base(local))
+	}
+
+	c, err := e.getClient()
+	if err != nil {
+		return err
+	}
+	ctx := baidupanplus.WithProgress(e.ctx, e.progressPrinter(), 0)
+	uploaded, err := c.UploadFileWithOptions(ctx, local, remote, baidupanplus.UploadOptions{OnConflict: policy, SkipIfSame: *skipSame})
+	if err != nil {
+		return err
+	}
+	result := transferResult{Local: local, Remote: uploaded.Path, Size: st.Size(), Skipped: uploaded.Skipped}
+	return e.output(result, func(w io.Writer) {
+		if result.Skipped {
+			fmt.Fprintf(w, "skipped %s, %s is identical\n", local, result.Remote)
+			return
+		}
+		fmt.Fprintf(w, "%s -> %s (%s)\n", local, result.Remote, humanSize(st.Size()))
+	})
+}
+
+// localConflictPolicies download -on-conflict 的取值
+var localConflictPolicies = map[string]baidupanplus.LocalConflictPolicy{
+	"fail":      baidupanplus.LocalConflictFail,
+	"overwrite": baidupanplus.LocalConflictOverwrite,
+	"skip":      baidupanplus.LocalConflictSkip,
+	"rename":    baidupanplus.LocalConflictRename,
+}
+
+func runDownload(e *env, args []string) error {
+	fs := newFlagSet(e, "download")
+	onConflict := fs.String("on-conflict", "overwrite", "本地文件已存在时的处理方式: fail, overwrite, skip, rename")
+	skipSame := fs.Bool("skip-same", false, "本地文件大小与修改时间与远程一致时跳过")
+	fsync := fs.Bool("fsync", false, "写入完成后同步到磁盘")
+	rest, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	policy, ok := localConflictPolicies[*onConflict]
+	if !ok {
+		return usagef("无效的 -on-conflict: %s", *onConflict)
+	}
+	if len(rest) < 1 || len(rest) > 2 {
+		return usagef("需要指定远程文件，以及可选的本地路径")
+	}
+	remote := remoteArg(rest[0])
+	local := path.Base(remote)
+	if len(rest) == 2 {
+		local = rest[1]
+		if st, err := os.Stat(local); err == nil && st.IsDir() {
+			local = filepath.Join(local, path.Base(remote))
+		}
+	}
+
+	c, err := e.getClient()
+	if err != nil {
+		return err
+	}
+	ctx := baidupanplus.WithProgress(e.ctx, e.progressPrinter(), 0)
+	downloaded, err := c.DownloadFileWithOptions(ctx, remote, local, baidupanplus.DownloadOptions{
+		OnConflict: policy,
+		SkipIfSame: *skipSame,
+		Fsync:      *fsync,
+	})
+	if err != nil {
+		return err
+	}
+	result := transferResult{Local: downloaded.LocalPath, Remote: remote, Size: downloaded.Size, Skipped: downloaded.Skipped}
+	return e.output(result, func(w io.Writer) {
+		if result.Skipped {
+			fmt.Fprintf(w, "skipped %s, %s already exists\n", remote, result.Local)
+			return
+		}
+		fmt.Fprintf(w, "%s -> %s (%s)\n", remote, result.Local, humanSize(result.Size))
+	})
+}
+
+func runCp(e *env, args []string) error {
+	return runCopyOrMove(e, "cp", args)
+}
+
+func runMv(e *env, args []string) error {
+	return runCopyOrMove(e, "mv", args)
+}
+
+// runCopyOrMove cp / mv 的公共实现
+// 目标路径以 / 结尾或是已存在的目录时，保持原文件名放入该目录；否则目标路径即新的完整路径
+func runCopyOrMove(e *env, name string, args []string) error {
+	fs := newFlagSet(e, name)
+	ondup := fs.String("ondup", string(baidupanplus.OnDupFail), "目标已存在时的处理方式: fail, newcopy, overwrite, skip")
+	rest, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 2 {
+		return usagef("需要指定源路径与目标路径")
+	}
+	switch baidupanplus.OnDup(*ondup) {
+	case baidupanplus.OnDupFail, baidupanplus.OnDupNewCopy, baidupanplus.OnDupOverwrite, baidupanplus.OnDupSkip:
+	default:
+		return usagef("无效的 -ondup: %s", *ondup)
+	}
+	c, err := e.getClient()
+	if err != nil {
+		return err
+	}
+
+	src, dst := remoteArg(rest[0]), remoteArg(rest[1])
+	destDir, newName := path.Dir(dst), path.Base(dst)
+	if strings.HasSuffix(rest[1], "/") {
+		destDir, newName = path.Clean(dst), path.Base(src)
+	} else if info, err := c.Stat(e.ctx, dst); err == nil && info.IsDir() {
+		destDir, newName = dst, path.Base(src)
+	} else if err != nil && !errors.Is(err, baidupanplus.ErrNotFound) {
+		return err
+	}
+
+	if name == "cp" {
+		err = c.Copy(e.ctx, src, destDir, newName, baidupanplus.OnDup(*ondup))
+	} else {
+		err = c.Move(e.ctx, src, destDir, newName, baidupanplus.OnDup(*ondup))
+	}
+	if err != nil {
+		return err
+	}
+	target := path.Join(destDir, newName)
+	return e.output(map[string]string{"source": src, "target": target}, func(w io.Writer) {
+		fmt.Fprintf(w, "%s -> %s\n", src, target)
+	})
+}
+
+func runRm(e *env, args []string) error {
+	rest, err := parseFlags(newFlagSet(e, "rm"), args)
+	if err != nil {
+		return err
+	}
+	if len(rest) == 0 {
+		return usagef("需要指定至少一个远程路径")
+	}
+	paths := make([]string, len(rest))
+	for i, p := range rest {
+		paths[i] = remoteArg(p)
+		if path.Clean(paths[i]) == "/" {
+			return usagef("不能删除根目录")
+		}
+	}
+	c, err := e.getClient()
+	if err != nil {
+		return err
+	}
+	if err := c.Remove(e.ctx, paths...); err != nil {
+		return err
+	}
+	return e.output(map[string][]string{"removed": paths}, func(w io.Writer) {
+		for _, p := range paths {
+			fmt.Fprintf(w, "removed %s\n", p)
+		}
+	})
+}
+
+func runMkdir(e *env, args []string) error {
+	fs := newFlagSet(e, "mkdir")
+	parents := fs.Bool("p", false, "同时创建不存在的父目录")
+	rest, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) == 0 {
+		return usagef("需要指定至少一个远程目录")
+	}
+	c, err := e.getClient()
+	if err != nil {
+		return err
+	}
+	var created []string
+	for _, p := range rest {
+		dir := remoteArg(p)
+		var info *baidupanplus.FileInfo
+		if *parents {
+			info, err = c.MkdirAll(e.ctx, dir)
+		} else {
+			info, err = c.Mkdir(e.ctx, dir, baidupanplus.ConflictFail)
+		}
+		if err != nil {
+			return err
+		}
+		created = append(created, info.Path)
+	}
+	return e.output(map[string][]string{"created": created}, func(w io.Writer) {
+		for _, p := range created {
+			fmt.Fprintf(w, "created %s\n", p)
+		}
+	})
+}
+
+func runSearch(e *env, args []string) error {
+	fs := newFlagSet(e, "search")
+	dir := fs.String("dir", "/", "搜索目录")
+	recursive := fs.Bool("r", false, "包含子目录")
+	rest, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 1 {
+		return usagef("需要指定一个关键字")
+	}
+	c, err := e.getClient()
+	if err != nil {
+		return err
+	}
+	files, err := c.Search(e.ctx, rest[0], remoteArg(*dir), *recursive)
+	if err != nil {
+		return err
+	}
+	if files == nil {
+		files = []baidupanplus.FileInfo{}
+	}
+	return e.output(files, func(w io.Writer) {
+		printFileTable(w, files, true)
+	})
+}
+
+func runQuota(e *env, args []string) error {
+	if _, err := parseFlags(newFlagSet(e, "quota"), args); err != nil {
+		return err
+	}
+	c, err := e.getClient()
+	if err != nil {
+		return err
+	}
+	q, err := c.Quota(e.ctx)
+	if err != nil {
+		return err
+	}
+	return e.output(q, func(w io.Writer) {
+		fmt.Fprintf(w, "total: %s\nused:  %s\nfree:  %s\n", humanSize(q.Total), humanSize(q.Used), humanSize(q.Free))
+		if q.ExpireSoon {
+			fmt.Fprintln(w, "部分容量将在 7 天内到期")
+		}
+	})
+}
+
+// whoamiResult whoami 的输出
+type whoamiResult struct {
+	*baidupanplus.UserInfo
+	Profile string `json:"profile"`
+}
+
+func runWhoami(e *env, args []string) error {
+	if _, err := parseFlags(newFlagSet(e, "whoami"), args); err != nil {
+		return err
+	}
+	c, err := e.getClient()
+	if err != nil {
+		return err
+	}
+	info, err := c.UserInfo(e.ctx)
+	if err != nil {
+		return err
+	}
+	u := whoamiResult{UserInfo: info, Profile: e.currentProfileName()}
+	return e.output(u, func(w io.Writer) {
+		fmt.Fprintf(w, "%s (uk %d, %s) [profile %s]\n", u.BaiduName, u.Uk, u.VipType, u.Profile)
+	})
+}
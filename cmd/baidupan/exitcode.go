@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"flag"
+
+	"github.com/S-zhi/baidupansdk/baidupanplus"
+)
+
+// 进程退出码，按 errno 分类映射，便于脚本判断失败原因
+const (
+	exitOK          = 0
+	exitError       = 1   // 未分类错误
+	exitUsage       = 2   // 命令行参数错误
+	exitAuth        = 3   // 未登录、令牌无效或过期
+	exitNotFound    = 4   // 文件或目录不存在
+	exitExists      = 5   // 文件或目录已存在
+	exitPermission  = 6   // 无访问权限
+	exitQuota       = 7   // 网盘空间不足
+	exitRateLimited = 8   // 命中接口频控
+	exitTooLarge    = 9   // 文件超过单文件大小上限
+	exitInterrupted = 130 // 被信号中断
+)
+
+// categoryExitCodes errno 分类与退出码的对应关系
+var categoryExitCodes = map[baidupanplus.ErrorCategory]int{
+	baidupanplus.CategoryNone:        exitOK,
+	baidupanplus.CategoryAuth:        exitAuth,
+	baidupanplus.CategoryNotFound:    exitNotFound,
+	baidupanplus.CategoryExists:      exitExists,
+	baidupanplus.CategoryPermission:  exitPermission,
+	baidupanplus.CategoryInvalid:     exitUsage,
+	baidupanplus.CategoryQuota:       exitQuota,
+	baidupanplus.CategoryRateLimited: exitRateLimited,
+	baidupanplus.CategoryCanceled:    exitInterrupted,
+}
+
+// exitCode 将错误映射为进程退出码
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	var uerr *usageError
+	if errors.As(err, &uerr) {
+		return exitUsage
+	}
+	if errors.Is(err, errNotLoggedIn) {
+		return exitAuth
+	}
+	// 文件过大不是参数写错，重试也不会成功，单独给出退出码
+	if errors.Is(err, baidupanplus.ErrFileTooLarge) {
+		return exitTooLarge
+	}
+	if code, ok := categoryExitCodes[baidupanplus.CategoryOf(err)]; ok {
+		return code
+	}
+	return exitError
+}
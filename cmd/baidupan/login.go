@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/S-zhi/baidupansdk/baidupanplus"
+	openapi "github.com/S-zhi/baidupansdk/openxpanapi"
+)
+
+func init() {
+	register(&command{
+		name:    "login",
+		usage:   "[-token 令牌] | [-app-key AppKey -secret-key SecretKey] [-svip]",
+		summary: "登录并保存令牌到配置档案：直接指定 access token，或通过设备码授权",
+		run:     runLogin,
+	})
+}
+
+// deviceScope 设备码授权申请的权限
+const deviceScope = "basic,netdisk"
+
+func runLogin(e *env, args []string) error {
+	fs := newFlagSet(e, "login")
+	token := fs.String("token", "", "直接保存已有的 access token")
+	appKey := fs.String("app-key", "", "应用 AppKey，用于设备码授权与刷新令牌")
+	secretKey := fs.String("secret-key", "", "应用 SecretKey，用于设备码授权与刷新令牌")
+	svip := fs.Bool("svip", false, "账号为超级会员，仅在无法查询会员类型时使用")
+	rest, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 {
+		return usagef("login 不接受位置参数")
+	}
+	if *token == "" && (*appKey == "" || *secretKey == "") {
+		return usagef("需要指定 -token，或同时指定 -app-key 与 -secret-key")
+	}
+
+	store, err := e.loadStore()
+	if err != nil {
+		return err
+	}
+	p := &profile{AccessToken: *token, AppKey: *appKey, SecretKey: *secretKey, IsSVIP: *svip}
+	if old, ok := store.Profiles[e.currentProfileName()]; ok {
+		// 重新登录时保留档案中手动配置的服务地址
+		p.PanURL, p.PCSURL, p.AuthURL, p.DlinkURL = old.PanURL, old.PCSURL, old.AuthURL, old.DlinkURL
+	}
+	if *token == "" {
+		if err := deviceLogin(e, p); err != nil {
+			return err
+		}
+	}
+
+	name := e.currentProfileName()
+	store.Profiles[name] = p
+	if store.Current == "" {
+		store.Current = name
+	}
+	if err := store.save(e.configPath); err != nil {
+		return err
+	}
+	return e.output(map[string]string{"profile": name, "config": e.configPath}, func(w io.Writer) {
+		fmt.Fprintf(w, "已保存到档案 %s (%s)\n", name, e.configPath)
+	})
+}
+
+// deviceLogin 设备码授权：输出用户码与授权地址，轮询直到用户在浏览器中完成授权
+func deviceLogin(e *env, p *profile) error {
+	api := baidupanplus.NewClient(p.sdkConfig()).API()
+	code, _, err := api.AuthApi.OauthTokenDeviceCode(e.ctx).
+		ClientId(p.AppKey).
+		Scope(deviceScope).
+		Execute()
+	if err != nil {
+		return fmt.Errorf("request device code failed: %w", err)
+	}
+	fmt.Fprintf(e.stderr, "请在浏览器中打开 %s 并输入用户码 %s\n", code.GetVerificationUrl(), code.GetUserCode())
+	if qr := code.GetQrcodeUrl(); qr != "" {
+		fmt.Fprintf(e.stderr, "或使用百度网盘 App 扫描二维码: %s\n", qr)
+	}
+
+	interval := time.Duration(code.GetInterval()) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	deadline := time.Now().Add(time.Duration(code.GetExpiresIn()) * time.Second)
+	for time.Now().Before(deadline) {
+		select {
+		case <-time.After(interval):
+		case <-e.ctx.Done():
+			return e.ctx.Err()
+		}
+
+		tok, _, err := api.AuthApi.OauthTokenDeviceToken(e.ctx).
+			Code(code.GetDeviceCode()).
+			ClientId(p.AppKey).
+			ClientSecret(p.SecretKey).
+			Execute()
+		if err == nil && tok.GetAccessToken() != "" {
+			p.AccessToken = tok.GetAccessToken()
+			p.RefreshToken = tok.GetRefreshToken()
+			p.ExpiresAt = time.Now().Unix() + int64(tok.GetExpiresIn())
+			return nil
+		}
+		if err == nil {
+			return errors.New("request device token failed: empty access token")
+		}
+		switch oauthErrorCode(err) {
+		case "authorization_pending":
+		case "slow_down":
+			interval += 5 * time.Second
+		default:
+			return fmt.Errorf("request device token failed: %w", err)
+		}
+	}
+	return errors.New("device code expired before authorization completed")
+}
+
+// oauthErrorCode 提取 OAuth 错误响应中的 error 字段
+func oauthErrorCode(err error) string {
+	var apiErr openapi.GenericOpenAPIError
+	if !errors.As(err, &apiErr) {
+		return ""
+	}
+	var body struct {
+		Error string `json:"error"`
+	}
+	_ = json.Unmarshal(apiErr.Body(), &body)
+	return body.Error
+}
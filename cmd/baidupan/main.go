@@ -0,0 +1,165 @@
+// baidupan 基于 baidupanplus 的百度网盘命令行工具
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/S-zhi/baidupansdk/baidupanplus"
+	"github.com/S-zhi/baidupansdk/internal/redact"
+)
+
+// command 子命令定义
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(env *env, args []string) error
+}
+
+// commands 全部子命令，按名称索引
+var commands = map[string]*command{}
+
+func register(cmd *command) {
+	commands[cmd.name] = cmd
+}
+
+// env 子命令的运行环境
+type env struct {
+	ctx         context.Context
+	stdout      io.Writer
+	stderr      io.Writer
+	jsonOutput  bool
+	verbose     bool
+	configPath  string
+	profileName string
+
+	store  *profileStore
+	client *baidupanplus.Client
+}
+
+// usageError 参数错误，退出码为 exitUsage
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+func usagef(format string, args ...interface{}) error {
+	return &usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+// run 解析全局参数并执行子命令，返回进程退出码
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	global := flag.NewFlagSet("baidupan", flag.ContinueOnError)
+	global.SetOutput(stderr)
+	e := &env{ctx: ctx, stdout: stdout, stderr: stderr}
+	global.StringVar(&e.configPath, "config", defaultConfigPath(), "配置文件路径")
+	global.StringVar(&e.profileName, "profile", "", "使用的配置档案，默认为配置文件中的 current")
+	global.BoolVar(&e.jsonOutput, "json", false, "以 JSON 格式输出")
+	global.BoolVar(&e.verbose, "v", false, "在 stderr 输出 SDK 调试日志")
+	global.Usage = func() { printUsage(stderr, global) }
+	if err := global.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	rest := global.Args()
+	if len(rest) == 0 {
+		printUsage(stderr, global)
+		return exitUsage
+	}
+	cmd, ok := commands[rest[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "未知命令: %s\n\n", rest[0])
+		printUsage(stderr, global)
+		return exitUsage
+	}
+
+	err := cmd.run(e, rest[1:])
+	if err != nil {
+		var uerr *usageError
+		if errors.As(err, &uerr) {
+			fmt.Fprintf(stderr, "%s\n用法: baidupan %s %s\n", uerr.msg, cmd.name, cmd.usage)
+		} else if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintf(stderr, "baidupan %s: %s\n", cmd.name, redact.String(err.Error()))
+		}
+	}
+	return exitCode(err)
+}
+
+func printUsage(w io.Writer, global *flag.FlagSet) {
+	fmt.Fprintln(w, "用法: baidupan [全局参数] <命令> [参数]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "命令:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-10s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "全局参数:")
+	global.SetOutput(w)
+	global.PrintDefaults()
+}
+
+// newFlagSet 创建子命令的参数解析器
+func newFlagSet(e *env, cmd string) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	fs.Usage = func() {
+		c := commands[cmd]
+		fmt.Fprintf(e.stderr, "用法: baidupan %s %s\n\n%s\n", c.name, c.usage, c.summary)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags 解析子命令参数，允许参数与位置参数交错出现
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		if args[0] == "--" {
+			return append(positional, args[1:]...), nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// remoteArg 规范化远程路径参数，补全前导 /
+func remoteArg(p string) string {
+	if !strings.HasPrefix(p, "/") {
+		return "/" + p
+	}
+	return p
+}
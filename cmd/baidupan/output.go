@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/S-zhi/baidupansdk/baidupanplus"
+)
+
+// output 按 --json 选择输出格式：JSON 模式下编码 v，否则调用 human 输出可读文本
+func (e *env) output(v interface{}, human func(w io.Writer)) error {
+	if e.jsonOutput {
+		enc := json.NewEncoder(e.stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	human(e.stdout)
+	return nil
+}
+
+// humanSize 以 1024 进制格式化字节数
+func humanSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%c", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// printFileTable 以表格形式输出文件列表
+func printFileTable(w io.Writer, files []baidupanplus.FileInfo, fullPath bool) {
+	for _, f := range files {
+		kind, size := "-", humanSize(f.Size)
+		if f.IsDir() {
+			kind, size = "d", "-"
+		}
+		name := f.ServerFilename
+		if fullPath {
+			name = f.Path
+		}
+		fmt.Fprintf(w, "%s %8s  %s  %s\n", kind, size, f.ModTime().Format("2006-01-02 15:04"), name)
+	}
+}
+
+// progressPrinter 在 stderr 输出单行刷新的传输进度，JSON 模式下不输出
+func (e *env) progressPrinter() baidupanplus.ProgressFunc {
+	if e.jsonOutput {
+		return nil
+	}
+	return func(p baidupanplus.Progress) {
+		if p.Phase == baidupanplus.PhaseDone {
+			fmt.Fprintf(e.stderr, "\r\033[K")
+			return
+		}
+		percent := ""
+		if p.Total > 0 {
+			percent = fmt.Sprintf(" %5.1f%%", float64(p.Transferred)*100/float64(p.Total))
+		}
+		eta := ""
+		if p.ETA > 0 {
+			eta = " ETA " + p.ETA.Round(time.Second).String()
+		}
+		fmt.Fprintf(e.stderr, "\r\033[K%-11s %s/%s%s %s/s%s",
+			p.Phase, humanSize(p.Transferred), humanSize(p.Total), percent, humanSize(int64(p.AverageSpeed)), eta)
+	}
+}
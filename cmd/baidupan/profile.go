@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/S-zhi/baidupansdk/baidupanplus"
+)
+
+// defaultProfileName 未指定档案时使用的档案名
+const defaultProfileName = "default"
+
+// accessTokenEnv 设置后优先于配置文件中的 access token
+const accessTokenEnv = "BAIDUPAN_ACCESS_TOKEN"
+
+// tokenRefreshMargin access token 过期前多久主动刷新
+const tokenRefreshMargin = 5 * time.Minute
+
+var errNotLoggedIn = errors.New("not logged in, run `baidupan login` first")
+
+// profile 单个账号的配置档案
+type profile struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresAt    int64  `json:"expires_at,omitempty"` // access token 过期时间（Unix 秒），0 表示未知
+	AppKey       string `json:"app_key,omitempty"`    // 刷新 token 所需的应用 AppKey
+	SecretKey    string `json:"secret_key,omitempty"` // 刷新 token 所需的应用 SecretKey
+	IsSVIP       bool   `json:"is_svip,omitempty"`
+	LogPath      string `json:"log_path,omitempty"`
+
+	// 服务地址，为空时使用官方地址
+	PanURL   string `json:"pan_url,omitempty"`
+	PCSURL   string `json:"pcs_url,omitempty"`
+	AuthURL  string `json:"auth_url,omitempty"`
+	DlinkURL string `json:"dlink_url,omitempty"`
+}
+
+// sdkConfig 档案对应的 SDK 配置
+func (p *profile) sdkConfig() baidupanplus.Config {
+	return baidupanplus.Config{
+		AccessToken: p.AccessToken,
+		IsSVIP:      p.IsSVIP,
+		LogPath:     p.LogPath,
+		PanURL:      p.PanURL,
+		PCSURL:      p.PCSURL,
+		AuthURL:     p.AuthURL,
+		DlinkURL:    p.DlinkURL,
+	}
+}
+
+// profileStore 配置文件内容
+type profileStore struct {
+	Current  string              `json:"current"`
+	Profiles map[string]*profile `json:"profiles"`
+}
+
+// defaultConfigPath 默认配置文件路径: $XDG_CONFIG_HOME/baidupan/config.json
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "baidupan.json"
+	}
+	return filepath.Join(dir, "baidupan", "config.json")
+}
+
+// loadProfileStore 读取配置文件，文件不存在时返回空配置
+func loadProfileStore(path string) (*profileStore, error) {
+	store := &profileStore{Profiles: map[string]*profile{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	if err := json.Unmarshal(data, store); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	if store.Profiles == nil {
+		store.Profiles = map[string]*profile{}
+	}
+	return store, nil
+}
+
+// save 写回配置文件，文件中包含令牌，权限设为仅当前用户可读写
+func (s *profileStore) save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// store 按需加载配置文件
+func (e *env) loadStore() (*profileStore, error) {
+	if e.store != nil {
+		return e.store, nil
+	}
+	store, err := loadProfileStore(e.configPath)
+	if err != nil {
+		return nil, err
+	}
+	e.store = store
+	return store, nil
+}
+
+// currentProfileName 当前使用的档案名：--profile > 配置文件 current > default
+func (e *env) currentProfileName() string {
+	if e.profileName != "" {
+		return e.profileName
+	}
+	if e.store != nil && e.store.Current != "" {
+		return e.store.Current
+	}
+	return defaultProfileName
+}
+
+// getClient 根据当前档案创建 SDK 客户端，access token 即将过期且可刷新时自动刷新
+func (e *env) getClient() (*baidupanplus.Client, error) {
+	if e.client != nil {
+		return e.client, nil
+	}
+	store, err := e.loadStore()
+	if err != nil {
+		return nil, err
+	}
+
+	var p profile
+	if stored, ok := store.Profiles[e.currentProfileName()]; ok {
+		p = *stored
+	}
+	if token := os.Getenv(accessTokenEnv); token != "" {
+		p.AccessToken = token
+	} else if err := e.refreshIfNeeded(store, &p); err != nil {
+		return nil, err
+	}
+	if p.AccessToken == "" {
+		return nil, errNotLoggedIn
+	}
+
+	cfg := p.sdkConfig()
+	if cfg.Logger, err = e.logger(p.LogPath); err != nil {
+		return nil, err
+	}
+	e.client = baidupanplus.NewClient(cfg)
+	return e.client, nil
+}
+
+// logger 按 -v 与档案中的 log_path 创建 SDK 日志：-v 时输出调试日志到 stderr，
+// 否则配置了 log_path 时写入轮转日志文件，都未配置时不输出
+func (e *env) logger(logPath string) (*slog.Logger, error) {
+	if e.verbose {
+		return slog.New(slog.NewTextHandler(e.stderr, &slog.HandlerOptions{Level: slog.LevelDebug})), nil
+	}
+	if logPath == "" {
+		return nil, nil
+	}
+	return baidupanplus.NewFileLogger(logPath, slog.LevelInfo)
+}
+
+// refreshIfNeeded access token 即将过期时使用 refresh token 刷新并写回配置文件
+func (e *env) refreshIfNeeded(store *profileStore, p *profile) error {
+	if p.ExpiresAt == 0 || p.RefreshToken == "" || p.AppKey == "" || p.SecretKey == "" {
+		return nil
+	}
+	if time.Until(time.Unix(p.ExpiresAt, 0)) > tokenRefreshMargin {
+		return nil
+	}
+
+	api := baidupanplus.NewClient(p.sdkConfig()).API()
+	resp, _, err := api.AuthApi.OauthTokenRefreshToken(e.ctx).
+		RefreshToken(p.RefreshToken).
+		ClientId(p.AppKey).
+		ClientSecret(p.SecretKey).
+		Execute()
+	if err != nil {
+		return fmt.Errorf("refresh access token failed: %w", err)
+	}
+	p.AccessToken = resp.GetAccessToken()
+	p.RefreshToken = resp.GetRefreshToken()
+	p.ExpiresAt = time.Now().Unix() + int64(resp.GetExpiresIn())
+
+	stored := *p
+	store.Profiles[e.currentProfileName()] = &stored
+	return store.save(e.configPath)
+}
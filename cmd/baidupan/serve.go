@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"golang.org/x/net/webdav"
+
+	"github.com/S-zhi/baidupansdk/baidupanplus/httpgw"
+	"github.com/S-zhi/baidupansdk/baidupanplus/s3gw"
+	"github.com/S-zhi/baidupansdk/baidupanplus/webdavfs"
+	"github.com/S-zhi/baidupansdk/internal/redact"
+)
+
+// serveShutdownTimeout 收到中断信号后等待进行中请求结束的时长
+const serveShutdownTimeout = 10 * time.Second
+
+// s3 网关的签名密钥从环境变量读取，避免出现在命令行参数中
+const (
+	s3AccessKeyEnv = "BAIDUPAN_S3_ACCESS_KEY"
+	s3SecretKeyEnv = "BAIDUPAN_S3_SECRET_KEY"
+)
+
+func init() {
+	register(&command{
+		name:    "serve",
+		usage:   "[-addr 地址] [-root 远程目录] [-bucket 桶名] webdav|http|s3",
+		summary: "以 WebDAV、只读 HTTP 或 S3 API 提供网盘文件，直到收到中断信号",
+		run:     runServe,
+	})
+}
+
+func runServe(e *env, args []string) error {
+	flags := newFlagSet(e, "serve")
+	addr := flags.String("addr", "127.0.0.1:8080", "监听地址")
+	root := flags.String("root", "/", "映射为服务根路径的网盘目录，s3 时为桶对应的目录")
+	bucket := flags.String("bucket", "baidupan", "s3 服务的桶名")
+	rest, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 1 {
+		return usagef("需要指定服务类型 webdav、http 或 s3")
+	}
+	kind := rest[0]
+	if kind != "webdav" && kind != "http" && kind != "s3" {
+		return usagef("未知服务类型: %s", kind)
+	}
+	accessKey, secretKey := os.Getenv(s3AccessKeyEnv), os.Getenv(s3SecretKeyEnv)
+	if kind == "s3" && (accessKey == "") != (secretKey == "") {
+		return usagef("%s 与 %s 需要同时设置", s3AccessKeyEnv, s3SecretKeyEnv)
+	}
+	c, err := e.getClient()
+	if err != nil {
+		return err
+	}
+
+	var handler http.Handler
+	switch kind {
+	case "webdav":
+		handler = &webdav.Handler{
+			FileSystem: webdavfs.New(c, webdavfs.Config{Root: remoteArg(*root)}),
+			LockSystem: webdav.NewMemLS(),
+			Logger: func(r *http.Request, err error) {
+				if err != nil && e.verbose {
+					fmt.Fprintf(e.stderr, "%s %s: %s\n", r.Method, r.URL.Path, redact.String(err.Error()))
+				}
+			},
+		}
+	case "http":
+		handler = httpgw.New(c, httpgw.Config{Root: remoteArg(*root)})
+	case "s3":
+		gw := s3gw.New(c, s3gw.Config{
+			Buckets:   map[string]string{*bucket: remoteArg(*root)},
+			AccessKey: accessKey,
+			SecretKey: secretKey,
+		})
+		defer gw.Close()
+		handler = gw
+	}
+
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: handler}
+	go func() {
+		<-e.ctx.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), serveShutdownTimeout)
+		defer cancel()
+		_ = srv.Shutdown(ctx)
+	}()
+	fmt.Fprintf(e.stderr, "%s 服务已启动: http://%s/\n", kind, ln.Addr())
+	if kind == "s3" && accessKey == "" {
+		fmt.Fprintf(e.stderr, "未设置 %s，不校验请求签名\n", s3AccessKeyEnv)
+	}
+	if err := srv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/S-zhi/baidupansdk/baidupanplus"
+)
+
+func init() {
+	register(&command{
+		name:    "sync",
+		usage:   "[-download] [-dry-run] <本地目录> <远程目录>",
+		summary: "同步目录：默认将本地目录上传到网盘，-download 时反向下载；大小相同的文件会被跳过",
+		run:     runSync,
+	})
+}
+
+// syncAction sync 对单个文件执行的动作
+type syncAction struct {
+	Action string `json:"action"` // upload / download / skip
+	Local  string `json:"local"`
+	Remote string `json:"remote"`
+	Size   int64  `json:"size"`
+}
+
+func runSync(e *env, args []string) error {
+	flags := newFlagSet(e, "sync")
+	download := flags.Bool("download", false, "从网盘下载到本地")
+	dryRun := flags.Bool("dry-run", false, "只输出将要执行的动作")
+	rest, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 2 {
+		return usagef("需要指定本地目录与远程目录")
+	}
+	localRoot, remoteRoot := rest[0], path.Clean(remoteArg(rest[1]))
+	c, err := e.getClient()
+	if err != nil {
+		return err
+	}
+
+	var actions []syncAction
+	if *download {
+		actions, err = planDownload(e, c, localRoot, remoteRoot)
+	} else {
+		actions, err = planUpload(e, c, localRoot, remoteRoot)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !*dryRun {
+		ctx := baidupanplus.WithProgress(e.ctx, e.progressPrinter(), 0)
+		for _, a := range actions {
+			switch a.Action {
+			case "upload":
+				// 大小变化的文件覆盖远程旧版本，而不是另存一份重命名的副本
+				_, err = c.UploadFileWithOptions(ctx, a.Local, a.Remote, baidupanplus.UploadOptions{OnConflict: baidupanplus.ConflictOverwrite})
+			case "download":
+				if err = os.MkdirAll(filepath.Dir(a.Local), 0755); err == nil {
+					err = c.DownloadFile(ctx, a.Remote, a.Local)
+				}
+			}
+			if err != nil {
+				return fmt.Errorf("%s %s: %w", a.Action, a.Remote, err)
+			}
+			if !e.jsonOutput && a.Action != "skip" {
+				fmt.Fprintf(e.stdout, "%-8s %s\n", a.Action, a.Remote)
+			}
+		}
+	}
+
+	if actions == nil {
+		actions = []syncAction{}
+	}
+	if e.jsonOutput {
+		return e.output(actions, nil)
+	}
+	if *dryRun {
+		for _, a := range actions {
+			fmt.Fprintf(e.stdout, "%-8s %s\n", a.Action, a.Remote)
+		}
+	}
+	return nil
+}
+
+// remoteFiles 递归列出远程目录下的文件，目录不存在时返回空表
+func remoteFiles(e *env, c *baidupanplus.Client, remoteRoot string) (map[string]baidupanplus.FileInfo, error) {
+	files := map[string]baidupanplus.FileInfo{}
+	err := c.Walk(e.ctx, remoteRoot, func(info baidupanplus.FileInfo) error {
+		if !info.IsDir() {
+			files[info.Path] = info
+		}
+		return nil
+	})
+	if err != nil && baidupanplus.CategoryOf(err) != baidupanplus.CategoryNotFound {
+		return nil, err
+	}
+	return files, nil
+}
+
+// planUpload 对比本地与远程文件，生成上传计划
+func planUpload(e *env, c *baidupanplus.Client, localRoot, remoteRoot string) ([]syncAction, error) {
+	existing, err := remoteFiles(e, c, remoteRoot)
+	if err != nil {
+		return nil, err
+	}
+	var actions []syncAction
+	err = filepath.WalkDir(localRoot, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(localRoot, p)
+		if err != nil {
+			return err
+		}
+		remote := path.Join(remoteRoot, filepath.ToSlash(rel))
+		action := "upload"
+		if r, ok := existing[remote]; ok && r.Size == info.Size() {
+			action = "skip"
+		}
+		actions = append(actions, syncAction{Action: action, Local: p, Remote: remote, Size: info.Size()})
+		return nil
+	})
+	return actions, err
+}
+
+// planDownload 对比远程与本地文件，生成下载计划
+func planDownload(e *env, c *baidupanplus.Client, localRoot, remoteRoot string) ([]syncAction, error) {
+	files, err := remoteFiles(e, c, remoteRoot)
+	if err != nil {
+		return nil, err
+	}
+	var actions []syncAction
+	for remote, info := range files {
+		rel := remote[len(remoteRoot):]
+		local := filepath.Join(localRoot, filepath.FromSlash(rel))
+		action := "download"
+		if st, err := os.Stat(local); err == nil && st.Size() == info.Size {
+			action = "skip"
+		} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		actions = append(actions, syncAction{Action: action, Local: local, Remote: remote, Size: info.Size})
+	}
+	// files 是 map，按路径排序使计划和执行顺序稳定
+	sort.Slice(actions, func(i, j int) bool { return actions[i].Remote < actions[j].Remote })
+	return actions, nil
+}
@@ -23,7 +23,7 @@ func QueryDirExample() {
 			fileName = *file.ServerFilename
 		}
 
-		fileSize := int32(0)
+		fileSize := int64(0)
 		if file.Size != nil {
 			fileSize = *file.Size
 		}
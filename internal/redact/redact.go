@@ -0,0 +1,67 @@
+// Package redact 屏蔽日志、错误信息与调试输出中的令牌、密钥与签名
+package redact
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Mask 替换敏感值的占位符
+const Mask = "***"
+
+var (
+	// queryPattern 匹配 URL 查询串与表单中的敏感参数，如 access_token=xxx
+	queryPattern = regexp.MustCompile(`(?i)(^|[?&;\s"'(])(access_token|refresh_token|client_secret|sign|secret_key)=[^&\s"'#)]+`)
+	// jsonPattern 匹配 JSON 中的敏感字段，如 "access_token":"xxx"
+	jsonPattern = regexp.MustCompile(`(?i)("(?:access_token|refresh_token|client_secret|sign|secret_key)"\s*:\s*")[^"]*`)
+)
+
+// sensitiveKeys 需要整体屏蔽的字段名
+var sensitiveKeys = map[string]bool{
+	"access_token":  true,
+	"refresh_token": true,
+	"client_secret": true,
+	"sign":          true,
+	"secret_key":    true,
+}
+
+// Key 判断字段名是否为敏感字段，敏感字段的值应整体屏蔽
+func Key(name string) bool {
+	return sensitiveKeys[strings.ToLower(name)]
+}
+
+// String 屏蔽 s 中 access_token、refresh_token、client_secret、sign 与 secret_key 的值
+func String(s string) string {
+	s = queryPattern.ReplaceAllString(s, "${1}${2}="+Mask)
+	return jsonPattern.ReplaceAllString(s, "${1}"+Mask)
+}
+
+// Error 返回错误信息已屏蔽敏感值的错误，errors.Is / errors.As 仍可匹配原错误
+func Error(err error) error {
+	if err == nil {
+		return nil
+	}
+	if urlErr, ok := err.(*url.Error); ok {
+		return &url.Error{Op: urlErr.Op, URL: String(urlErr.URL), Err: Error(urlErr.Err)}
+	}
+	msg := err.Error()
+	if redacted := String(msg); redacted != msg {
+		return &redactedError{msg: redacted, err: err}
+	}
+	return err
+}
+
+// redactedError 错误信息已屏蔽的包装错误
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
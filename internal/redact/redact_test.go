@@ -0,0 +1,79 @@
+package redact
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"access_token query", "https://pan.baidu.com/rest/2.0/xpan/file?method=list&access_token=abc.123", "https://pan.baidu.com/rest/2.0/xpan/file?method=list&access_token=***"},
+		{"first query param", "/oauth/2.0/token?refresh_token=r1&client_secret=s1&grant_type=refresh_token", "/oauth/2.0/token?refresh_token=***&client_secret=***&grant_type=refresh_token"},
+		{"sign in dlink", "https://d.pcs.baidu.com/file/x?fid=1&sign=FDtAER-DCb740ccc5511e5e8fedcff06b081203-abc&expires=8h", "https://d.pcs.baidu.com/file/x?fid=1&sign=***&expires=8h"},
+		{"secret_key form", "client_id=id&secret_key=sk1&code=c", "client_id=id&secret_key=***&code=c"},
+		{"quoted in error", `Get "https://x/?access_token=abc": EOF`, `Get "https://x/?access_token=***": EOF`},
+		{"upper case", "?ACCESS_TOKEN=abc", "?ACCESS_TOKEN=***"},
+		{"fragment kept", "?sign=abc#frag", "?sign=***#frag"},
+		{"json fields", `{"access_token":"a","refresh_token": "r","expires_in":2592000}`, `{"access_token":"***","refresh_token": "***","expires_in":2592000}`},
+		{"json secrets", `{"client_secret":"c","secret_key":"s","sign":"x"}`, `{"client_secret":"***","secret_key":"***","sign":"***"}`},
+		{"similar names untouched", "?signature=abc&my_access_token_id=1&design=2", "?signature=abc&my_access_token_id=1&design=2"},
+		{"nothing sensitive", "list /apps/test failed with errno: -9", "list /apps/test failed with errno: -9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := String(tt.in); got != tt.want {
+				t.Errorf("String(%q)\n got %q\nwant %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKey(t *testing.T) {
+	for _, key := range []string{"access_token", "refresh_token", "client_secret", "sign", "secret_key", "Access_Token"} {
+		if !Key(key) {
+			t.Errorf("Key(%q) = false, want true", key)
+		}
+	}
+	for _, key := range []string{"path", "errno", "signature", "token_type"} {
+		if Key(key) {
+			t.Errorf("Key(%q) = true, want false", key)
+		}
+	}
+}
+
+func TestError(t *testing.T) {
+	sentinel := errors.New("connection reset")
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"url error", &url.Error{Op: "Get", URL: "https://x/?access_token=abc&sign=s", Err: sentinel}, `Get "https://x/?access_token=***&sign=***": connection reset`},
+		{"wrapped message", errors.Join(sentinel, errors.New("refresh_token=r1 rejected")), "connection reset\nrefresh_token=*** rejected"},
+		{"json body", errors.Join(sentinel, errors.New(`bad response {"secret_key":"sk"}`)), "connection reset\nbad response {\"secret_key\":\"***\"}"},
+		{"clean", sentinel, "connection reset"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Error(tt.err)
+			if got.Error() != tt.want {
+				t.Errorf("Error() = %q, want %q", got.Error(), tt.want)
+			}
+			if !errors.Is(got, sentinel) {
+				t.Error("redacted error no longer matches the original")
+			}
+			if strings.Contains(got.Error(), "abc") {
+				t.Error("token leaked")
+			}
+		})
+	}
+	if Error(nil) != nil {
+		t.Error("Error(nil) != nil")
+	}
+}
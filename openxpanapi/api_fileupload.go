@@ -13,6 +13,7 @@ package openapi
 import (
 	"bytes"
 	_context "context"
+	_io "io"
 	_ioutil "io/ioutil"
 	_nethttp "net/http"
 	_neturl "net/url"
@@ -36,6 +37,7 @@ type ApiPcssuperfile2Request struct {
 	uploadid    *string
 	type_       *string
 	file        **os.File
+	fileReader  *multipartFileReader
 }
 
 func (r ApiPcssuperfile2Request) AccessToken(accessToken string) ApiPcssuperfile2Request {
@@ -65,6 +67,13 @@ func (r ApiPcssuperfile2Request) File(file *os.File) ApiPcssuperfile2Request {
 	return r
 }
 
+// FileReader 以流的方式传送分片内容，不再需要先落地为临时文件
+// size 为分片字节数，未知时传 -1（请求将以 chunked 方式发送）；设置后将忽略 File
+func (r ApiPcssuperfile2Request) FileReader(fileName string, reader _io.Reader, size int64) ApiPcssuperfile2Request {
+	r.fileReader = &multipartFileReader{fileName: fileName, reader: reader, size: size}
+	return r
+}
+
 func (r ApiPcssuperfile2Request) Execute() (string, *_nethttp.Response, error) {
 	return r.ApiService.Pcssuperfile2Execute(r)
 }
@@ -150,6 +159,13 @@ func (a *FileuploadApiService) Pcssuperfile2Execute(r ApiPcssuperfile2Request) (
 	fileLocalVarFormFileName = "file"
 
 	var fileLocalVarFile *os.File
+	if r.fileReader != nil {
+		req, err := a.client.prepareMultipartStreamRequest(r.ctx, localVarPath, localVarHTTPMethod, localVarHeaderParams, localVarQueryParams, fileLocalVarFormFileName, *r.fileReader)
+		if err != nil {
+			return localVarReturnValue, nil, err
+		}
+		return a.pcssuperfile2Do(req)
+	}
 	if r.file != nil {
 		fileLocalVarFile = *r.file
 	}
@@ -164,6 +180,12 @@ func (a *FileuploadApiService) Pcssuperfile2Execute(r ApiPcssuperfile2Request) (
 	if err != nil {
 		return localVarReturnValue, nil, err
 	}
+	return a.pcssuperfile2Do(req)
+}
+
+// pcssuperfile2Do 发送分片上传请求并解析响应
+func (a *FileuploadApiService) pcssuperfile2Do(req *_nethttp.Request) (string, *_nethttp.Response, error) {
+	var localVarReturnValue string
 
 	localVarHTTPResponse, err := a.client.callAPI(req)
 	if err != nil || localVarHTTPResponse == nil {
@@ -203,7 +225,7 @@ type ApiXpanfilecreateRequest struct {
 	accessToken *string
 	path        *string
 	isdir       *int32
-	size        *int32
+	size        *int64
 	uploadid    *string
 	blockList   *string
 	rtype       *int32
@@ -227,7 +249,7 @@ func (r ApiXpanfilecreateRequest) Isdir(isdir int32) ApiXpanfilecreateRequest {
 }
 
 // 与precreate的size值保持一致
-func (r ApiXpanfilecreateRequest) Size(size int32) ApiXpanfilecreateRequest {
+func (r ApiXpanfilecreateRequest) Size(size int64) ApiXpanfilecreateRequest {
 	r.size = &size
 	return r
 }
@@ -378,7 +400,7 @@ type ApiXpanfileprecreateRequest struct {
 	accessToken *string
 	path        *string
 	isdir       *int32
-	size        *int32
+	size        *int64
 	autoinit    *int32
 	blockList   *string
 	rtype       *int32
@@ -402,7 +424,7 @@ func (r ApiXpanfileprecreateRequest) Isdir(isdir int32) ApiXpanfileprecreateRequ
 }
 
 // size
-func (r ApiXpanfileprecreateRequest) Size(size int32) ApiXpanfileprecreateRequest {
+func (r ApiXpanfileprecreateRequest) Size(size int64) ApiXpanfileprecreateRequest {
 	r.size = &size
 	return r
 }
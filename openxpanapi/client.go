@@ -32,6 +32,8 @@ import (
 	"strings"
 	"time"
 	"unicode/utf8"
+
+	"github.com/S-zhi/baidupansdk/internal/redact"
 )
 
 var (
@@ -178,12 +180,16 @@ func (c *APIClient) callAPI(request *http.Request) (*http.Response, error) {
 		if err != nil {
 			return nil, err
 		}
-		log.Printf("\n%s\n", string(dump))
+		log.Printf("\n%s\n", redact.String(string(dump)))
 	}
 
+	start := time.Now()
 	resp, err := c.cfg.HTTPClient.Do(request)
+	if c.cfg.CallObserver != nil {
+		c.cfg.CallObserver.ObserveCall(request, resp, err, time.Since(start))
+	}
 	if err != nil {
-		return resp, err
+		return resp, redact.Error(err)
 	}
 
 	if c.cfg.Debug {
@@ -191,7 +197,7 @@ func (c *APIClient) callAPI(request *http.Request) (*http.Response, error) {
 		if err != nil {
 			return resp, err
 		}
-		log.Printf("\n%s\n", string(dump))
+		log.Printf("\n%s\n", redact.String(string(dump)))
 	}
 	return resp, err
 }
@@ -15,6 +15,7 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 )
 
 // contextKeys are used to identify the type of value in the context.
@@ -95,6 +96,13 @@ type Configuration struct {
 	Servers          ServerConfigurations
 	OperationServers map[string]ServerConfigurations
 	HTTPClient       *http.Client
+	// CallObserver 非空时在每次 API 调用结束后被调用，可用于采集指标
+	CallObserver CallObserver
+}
+
+// CallObserver 观察每次 API 调用的请求、响应与耗时，err 非空时 resp 可能为 nil
+type CallObserver interface {
+	ObserveCall(req *http.Request, resp *http.Response, err error, elapsed time.Duration)
 }
 
 // NewConfiguration returns a new Configuration object
@@ -35,7 +35,7 @@ type Filecreateresponse struct {
 	// ServerFilename 服务端存储的文件名，可选字段。
 	ServerFilename *string `json:"server_filename,omitempty"`
 	// Size 文件大小（字节），可选字段。
-	Size *int32 `json:"size,omitempty"`
+	Size *int64 `json:"size,omitempty"`
 	// Errno 操作错误码，0 表示成功，非 0 表示失败，可选字段。
 	Errno *int32 `json:"errno,omitempty"`
 	// Name 文件名，可选字段。
@@ -348,9 +348,9 @@ func (o *Filecreateresponse) SetServerFilename(v string) {
 }
 
 // GetSize returns the Size field value if set, zero value otherwise.
-func (o *Filecreateresponse) GetSize() int32 {
+func (o *Filecreateresponse) GetSize() int64 {
 	if o == nil || o.Size == nil {
-		var ret int32
+		var ret int64
 		return ret
 	}
 	return *o.Size
@@ -358,7 +358,7 @@ func (o *Filecreateresponse) GetSize() int32 {
 
 // GetSizeOk returns a tuple with the Size field value if set, nil otherwise
 // and a boolean to check if the value has been set.
-func (o *Filecreateresponse) GetSizeOk() (*int32, bool) {
+func (o *Filecreateresponse) GetSizeOk() (*int64, bool) {
 	if o == nil || o.Size == nil {
 		return nil, false
 	}
@@ -374,8 +374,8 @@ func (o *Filecreateresponse) HasSize() bool {
 	return false
 }
 
-// SetSize gets a reference to the given int32 and assigns it to the Size field.
-func (o *Filecreateresponse) SetSize(v int32) {
+// SetSize gets a reference to the given int64 and assigns it to the Size field.
+func (o *Filecreateresponse) SetSize(v int64) {
 	o.Size = &v
 }
 
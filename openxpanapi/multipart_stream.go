@@ -0,0 +1,67 @@
+/*
+xpan
+
+xpanapi
+
+API version: 0.1
+*/
+
+package openapi
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path/filepath"
+)
+
+// multipartFileReader 以流方式写入 multipart 表单的文件字段
+type multipartFileReader struct {
+	fileName string
+	reader   io.Reader
+	size     int64
+}
+
+// prepareMultipartStreamRequest 构造文件内容来自 io.Reader 的 multipart/form-data 请求
+// 请求体由 表单头 + 文件内容 + 结尾边界 三段拼接而成，文件内容不会整体读入内存；
+// 已知 size 时会设置准确的 Content-Length，否则使用 chunked 编码
+func (c *APIClient) prepareMultipartStreamRequest(
+	ctx context.Context,
+	path string, method string,
+	headerParams map[string]string,
+	queryParams url.Values,
+	formFileName string,
+	file multipartFileReader) (*http.Request, error) {
+
+	var head bytes.Buffer
+	w := multipart.NewWriter(&head)
+	fileName := file.fileName
+	if fileName == "" {
+		fileName = formFileName
+	}
+	if _, err := w.CreateFormFile(formFileName, filepath.Base(fileName)); err != nil {
+		return nil, err
+	}
+	tail := "\r\n--" + w.Boundary() + "--\r\n"
+
+	// 复用 prepareRequest 处理 URL、Query、Header 与认证信息，请求体在此之后替换
+	headerParams["Content-Type"] = w.FormDataContentType()
+	req, err := c.prepareRequest(ctx, path, method, nil, headerParams, queryParams, url.Values{}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	body := io.MultiReader(bytes.NewReader(head.Bytes()), file.reader, bytes.NewReader([]byte(tail)))
+	req.Body = io.NopCloser(body)
+	req.GetBody = nil
+	if file.size >= 0 {
+		req.ContentLength = int64(head.Len()) + file.size + int64(len(tail))
+	} else {
+		req.ContentLength = -1
+	}
+	req.Header.Del("Content-Length")
+	return req, nil
+}